@@ -0,0 +1,49 @@
+// Package config holds the settings shared by the programs of the service.
+// They are read from the same environment variables, so the admin tool works
+// against the database and the keys of the retail-api.
+package config
+
+import (
+	"fmt"
+
+	"github.com/dimashiro/service/business/database"
+)
+
+// DB holds the settings of the database connection.
+type DB struct {
+	User         string `env:"DBUSER" env-default:"postgres"`
+	Password     string `env:"DBPASSWORD" env-default:"postgres"`
+	Host         string `env:"DBHOST" env-default:"localhost"`
+	Name         string `env:"DBNAME" env-default:"postgres"`
+	MaxIdleConns int    `env:"DBMAXIDLECONNS" env-default:"0"`
+	MaxOpenConns int    `env:"DBMAXOPENCONNS" env-default:"0"`
+	DisableTLS   bool   `env:"DBDISABLETLS" env-default:"true"`
+}
+
+// Database returns the configuration to open the database with.
+func (c DB) Database() database.Config {
+	return database.Config{
+		User:         c.User,
+		Password:     c.Password,
+		Host:         c.Host,
+		Name:         c.Name,
+		MaxIdleConns: c.MaxIdleConns,
+		MaxOpenConns: c.MaxOpenConns,
+		DisableTLS:   c.DisableTLS,
+	}
+}
+
+// String masks the password so the settings can be logged.
+func (c DB) String() string {
+	return fmt.Sprintf("{User:%s Password:xxxxxx Host:%s Name:%s MaxIdleConns:%d MaxOpenConns:%d DisableTLS:%t}",
+		c.User, c.Host, c.Name, c.MaxIdleConns, c.MaxOpenConns, c.DisableTLS)
+}
+
+// Keys holds the settings of the signing keys. The issuer is the iss claim of
+// the signed tokens and must be the external URL of the retail-api, the
+// discovery clients check it matches where they found the keys.
+type Keys struct {
+	Folder    string `env:"AUTHKEYSFOLDER" env-default:"deploy/keys/"`
+	ActiveKID string `env:"AUTHACTIVEKID" env-default:"developmentkeyid"`
+	Issuer    string `env:"AUTHISSUER" env-default:"http://localhost:3000"`
+}
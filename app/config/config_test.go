@@ -0,0 +1,47 @@
+package config_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dimashiro/service/app/config"
+	"github.com/ilyakaznacheev/cleanenv"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestDB(t *testing.T) {
+	t.Setenv("DBPASSWORD", "s3cr3t")
+	t.Setenv("DBHOST", "db.example.com")
+
+	t.Log("Given the need to share the database settings between the programs.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen reading them from the environment.", testID)
+		{
+			cfg := struct {
+				DB config.DB
+			}{}
+
+			if err := cleanenv.ReadEnv(&cfg); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to read the settings : %s.", failed, testID, err)
+			}
+
+			dbCfg := cfg.DB.Database()
+			if dbCfg.Password != "s3cr3t" || dbCfg.Host != "db.example.com" || dbCfg.User != "postgres" || !dbCfg.DisableTLS {
+				t.Fatalf("\t%s\tTest %d:\tShould read the variables and the defaults : got %+v.", failed, testID, dbCfg)
+			}
+			t.Logf("\t%s\tTest %d:\tShould read the variables and the defaults.", success, testID)
+
+			if s := fmt.Sprintf("%+v", cfg); strings.Contains(s, "s3cr3t") || !strings.Contains(s, "db.example.com") {
+				t.Fatalf("\t%s\tTest %d:\tShould mask the password when printed : got %s.", failed, testID, s)
+			}
+			t.Logf("\t%s\tTest %d:\tShould mask the password when printed.", success, testID)
+		}
+	}
+}
@@ -2,42 +2,34 @@
 package check
 
 import (
-	"context"
-	"encoding/json"
 	"net/http"
 	"os"
-	"time"
 
-	"github.com/dimashiro/service/business/database"
+	"github.com/dimashiro/service/app/services/retail-api/handlers/debug"
+	"github.com/dimashiro/service/foundation/health"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
 
 // Handlers manages the set of check enpoints.
 type Handlers struct {
-	Build string
-	Log   *zap.SugaredLogger
-	DB    *sqlx.DB
+	Build  string
+	Log    *zap.SugaredLogger
+	DB     *sqlx.DB
+	Health *health.Registry
 }
 
+// Readiness runs the registered health checks and reports the status of every
+// component. The service is ready when all of them are.
 func (h Handlers) Readiness(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), time.Second)
-	defer cancel()
+	report := h.Health.Run(r.Context())
 
-	status := "ok"
 	statusCode := http.StatusOK
-	if err := database.StatusCheck(ctx, h.DB); err != nil {
-		status = "db not ready"
+	if !report.Ready() {
 		statusCode = http.StatusInternalServerError
 	}
 
-	data := struct {
-		Status string `json:"status"`
-	}{
-		Status: status,
-	}
-
-	if err := response(w, statusCode, data); err != nil {
+	if err := debug.Respond(w, statusCode, report); err != nil {
 		h.Log.Errorw("readiness", "ERROR", err)
 	}
 
@@ -69,27 +61,40 @@ func (h Handlers) Liveness(w http.ResponseWriter, r *http.Request) {
 	}
 
 	statusCode := http.StatusOK
-	if err := response(w, statusCode, data); err != nil {
+	if err := debug.Respond(w, statusCode, data); err != nil {
 		h.Log.Errorw("liveness", "ERROR", err)
 	}
 
 	h.Log.Infow("liveness", "statusCode", statusCode, "method", r.Method, "path", r.URL.Path, "remoteaddr", r.RemoteAddr)
 }
 
-func response(w http.ResponseWriter, statusCode int, data interface{}) error {
+// DBStats returns the connection pool statistics of the database.
+func (h Handlers) DBStats(w http.ResponseWriter, r *http.Request) {
+	s := h.DB.Stats()
 
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return err
+	data := struct {
+		MaxOpenConnections int    `json:"max_open_connections"`
+		OpenConnections    int    `json:"open_connections"`
+		InUse              int    `json:"in_use"`
+		Idle               int    `json:"idle"`
+		WaitCount          int64  `json:"wait_count"`
+		WaitDuration       string `json:"wait_duration"`
+		MaxIdleClosed      int64  `json:"max_idle_closed"`
+		MaxIdleTimeClosed  int64  `json:"max_idle_time_closed"`
+		MaxLifetimeClosed  int64  `json:"max_lifetime_closed"`
+	}{
+		MaxOpenConnections: s.MaxOpenConnections,
+		OpenConnections:    s.OpenConnections,
+		InUse:              s.InUse,
+		Idle:               s.Idle,
+		WaitCount:          s.WaitCount,
+		WaitDuration:       s.WaitDuration.String(),
+		MaxIdleClosed:      s.MaxIdleClosed,
+		MaxIdleTimeClosed:  s.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  s.MaxLifetimeClosed,
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-
-	w.WriteHeader(statusCode)
-
-	if _, err := w.Write(jsonData); err != nil {
-		return err
+	if err := debug.Respond(w, http.StatusOK, data); err != nil {
+		h.Log.Errorw("dbstats", "ERROR", err)
 	}
-
-	return nil
 }
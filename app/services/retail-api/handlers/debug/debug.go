@@ -0,0 +1,27 @@
+// Package debug contains the support shared by the groups of handlers of the
+// debug mux.
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Respond converts a Go value to JSON and sends it to the client.
+func Respond(w http.ResponseWriter, statusCode int, data interface{}) error {
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	w.WriteHeader(statusCode)
+
+	if _, err := w.Write(jsonData); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+// Package keys maintains the group of handlers for inspecting the signing
+// keys at runtime.
+package keys
+
+import (
+	"net/http"
+
+	"github.com/dimashiro/service/app/services/retail-api/handlers/debug"
+	"github.com/dimashiro/service/business/auth"
+	"go.uber.org/zap"
+)
+
+// Handlers manages the set of key enpoints.
+type Handlers struct {
+	Log  *zap.SugaredLogger
+	Auth *auth.Auth
+}
+
+// ActiveKID reports the key id used to sign tokens. The active key is
+// switched through the active.kid file of the keys folder, which every
+// replica watches, not through this endpoint.
+// Example: curl localhost:4000/debug/auth/activekid
+func (h Handlers) ActiveKID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+
+		data := struct {
+			Error string `json:"error"`
+		}{
+			Error: http.StatusText(http.StatusMethodNotAllowed),
+		}
+		h.respond(w, r, http.StatusMethodNotAllowed, data)
+		return
+	}
+
+	data := struct {
+		KID string `json:"kid"`
+	}{
+		KID: h.Auth.ActiveKID(),
+	}
+
+	h.respond(w, r, http.StatusOK, data)
+}
+
+func (h Handlers) respond(w http.ResponseWriter, r *http.Request, statusCode int, data interface{}) {
+	if err := debug.Respond(w, statusCode, data); err != nil {
+		h.Log.Errorw("keys", "ERROR", err)
+	}
+
+	h.Log.Infow("keys", "statusCode", statusCode, "method", r.Method, "path", r.URL.Path, "remoteaddr", r.RemoteAddr)
+}
@@ -0,0 +1,88 @@
+package keys_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dimashiro/service/app/services/retail-api/handlers/debug/keys"
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/foundation/keystore"
+	"go.uber.org/zap"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestActiveKID(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Should be able to create a private key: %v", err)
+	}
+
+	const kid = "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"
+	a, err := auth.New("http://localhost:3000", kid, keystore.NewMap(map[string]crypto.Signer{kid: privateKey}), nil, nil)
+	if err != nil {
+		t.Fatalf("Should be able to construct auth: %v", err)
+	}
+
+	h := keys.Handlers{
+		Log:  zap.NewNop().Sugar(),
+		Auth: a,
+	}
+
+	t.Log("Given the need to inspect the active signing key.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen requesting the active kid.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/debug/auth/activekid", nil)
+			w := httptest.NewRecorder()
+			h.ActiveKID(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 : got %d.", failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200.", success, testID)
+
+			var got struct {
+				KID string `json:"kid"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the response : %v.", failed, testID, err)
+			}
+
+			if got.KID != kid {
+				t.Fatalf("\t%s\tTest %d:\tShould report the active kid : got %q.", failed, testID, got.KID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report the active kid.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen trying to switch the active kid.", testID)
+		{
+			r := httptest.NewRequest(http.MethodPut, "/debug/auth/activekid", strings.NewReader(`{"kid":"other"}`))
+			w := httptest.NewRecorder()
+			h.ActiveKID(w, r)
+
+			if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 405 : got %d.", failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 405.", success, testID)
+
+			if a.ActiveKID() != kid {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the active kid : got %q.", failed, testID, a.ActiveKID())
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the active kid.", success, testID)
+		}
+	}
+}
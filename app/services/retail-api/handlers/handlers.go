@@ -7,13 +7,28 @@ import (
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"time"
 
 	"github.com/dimashiro/service/app/services/retail-api/handlers/debug/check"
+	"github.com/dimashiro/service/app/services/retail-api/handlers/debug/keys"
 	v1_test "github.com/dimashiro/service/app/services/retail-api/handlers/v1"
+	"github.com/dimashiro/service/app/services/retail-api/handlers/v1/apikeygrp"
+	"github.com/dimashiro/service/app/services/retail-api/handlers/v1/auditgrp"
+	"github.com/dimashiro/service/app/services/retail-api/handlers/v1/productgrp"
+	"github.com/dimashiro/service/app/services/retail-api/handlers/v1/salegrp"
 	"github.com/dimashiro/service/app/services/retail-api/handlers/v1/usergrp"
+	"github.com/dimashiro/service/app/services/retail-api/handlers/wellknown"
 	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/core/apikey"
+	"github.com/dimashiro/service/business/core/audit"
+	"github.com/dimashiro/service/business/core/product"
+	"github.com/dimashiro/service/business/core/sale"
 	"github.com/dimashiro/service/business/core/user"
+	userStorage "github.com/dimashiro/service/business/data/store/user"
+	"github.com/dimashiro/service/business/mailer"
+	"github.com/dimashiro/service/business/metrics"
 	"github.com/dimashiro/service/business/middleware"
+	"github.com/dimashiro/service/foundation/health"
 	"github.com/dimashiro/service/foundation/webapp"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
@@ -37,27 +52,66 @@ func DebugStandardLibraryMux() *http.ServeMux {
 	return mux
 }
 
-func DebugMux(build string, log *zap.SugaredLogger, db *sqlx.DB) http.Handler {
+// DebugMux registers all the debug routes, the readiness endpoint reports the
+// checks of the health registry.
+func DebugMux(build string, log *zap.SugaredLogger, db *sqlx.DB, a *auth.Auth, hr *health.Registry) http.Handler {
 	mux := DebugStandardLibraryMux()
 
 	// Register debug check endpoints.
 	cgh := check.Handlers{
-		Build: build,
-		Log:   log,
-		DB:    db,
+		Build:  build,
+		Log:    log,
+		DB:     db,
+		Health: hr,
 	}
 	mux.HandleFunc("/debug/readiness", cgh.Readiness)
 	mux.HandleFunc("/debug/liveness", cgh.Liveness)
+	mux.HandleFunc("/debug/db/stats", cgh.DBStats)
+
+	// Register key rotation endpoints.
+	kgh := keys.Handlers{
+		Log:  log,
+		Auth: a,
+	}
+	mux.HandleFunc("/debug/auth/activekid", kgh.ActiveKID)
+
+	// Register the Prometheus scrape endpoint.
+	mux.Handle("/metrics", metrics.Handler(db.DB))
 
 	return mux
 }
 
 // APIMuxConfig contains all the mandatory systems required by handlers.
 type APIMuxConfig struct {
-	Shutdown chan os.Signal
-	Log      *zap.SugaredLogger
-	Auth     *auth.Auth
-	DB       *sqlx.DB
+	Shutdown   chan os.Signal
+	Log        *zap.SugaredLogger
+	Auth       *auth.Auth
+	DB         *sqlx.DB
+	Keys       wellknown.KeySet
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+
+	// External URL of the service, the discovery document points at it.
+	BaseURL string
+
+	// Login protection of the token endpoint. The rate limit applies to
+	// each replica, the client address is the remote address when ClientIP
+	// is nil.
+	Lockout        userStorage.Lockout
+	TokenRateLimit int
+	TokenRatePer   time.Duration
+	ClientIP       middleware.ClientIP
+
+	// Password reset and email verification mails.
+	Mailer    mailer.Mailer
+	ResetTTL  time.Duration
+	VerifyTTL time.Duration
+
+	// Multi-factor authentication, the TOTP secrets are encrypted with the
+	// MFASecrets box.
+	MFAIssuer       string
+	MFAChallengeTTL time.Duration
+	MFASecrets      *auth.SecretBox
 }
 
 // APIMux constructs an http.Handler with all application routes defined.
@@ -65,31 +119,102 @@ func APIMux(cfg APIMuxConfig) *webapp.App {
 	app := webapp.NewApp(
 		cfg.Shutdown,
 		middleware.Logger(cfg.Log),
-		middleware.Errors(cfg.Log),
 		middleware.Metrics(),
+		middleware.Errors(cfg.Log),
 		middleware.Panics(),
 	)
 
+	// register discovery handlers for token verification by other services
+	wgh := wellknown.Handlers{
+		Keys:    cfg.Keys,
+		Issuer:  cfg.Auth.Issuer(),
+		BaseURL: cfg.BaseURL,
+	}
+
+	app.Handle(http.MethodGet, "", "/.well-known/jwks.json", wgh.JWKS)
+	app.Handle(http.MethodGet, "", "/.well-known/openid-configuration", wgh.OpenIDConfiguration)
+
 	// test handler for development
 	tV1 := v1_test.Handlers{
 		Log: cfg.Log,
 	}
 
 	app.Handle(http.MethodGet, "v1", "/test", tV1.Test)
-	app.Handle(http.MethodGet, "v1", "/testauth", tV1.Test, middleware.Authenticate(cfg.Auth), middleware.Authorize("ADMIN"))
+	app.Handle(http.MethodGet, "v1", "/testauth", tV1.Test, middleware.Authenticate(cfg.Auth), middleware.RequirePermission(auth.PermUsersRead))
 
 	//register user handlers
 	ugh := usergrp.Handlers{
-		User: user.NewCore(cfg.Log, cfg.DB),
-		Auth: cfg.Auth,
+		User:       user.NewCore(cfg.Log, cfg.DB, cfg.Mailer, cfg.MFASecrets),
+		Auth:       cfg.Auth,
+		AccessTTL:  cfg.AccessTTL,
+		RefreshTTL: cfg.RefreshTTL,
+		Lockout:    cfg.Lockout,
+		ResetTTL:   cfg.ResetTTL,
+		VerifyTTL:  cfg.VerifyTTL,
+
+		MFAIssuer:    cfg.MFAIssuer,
+		ChallengeTTL: cfg.MFAChallengeTTL,
 	}
 
-	app.Handle(http.MethodGet, "v1", "/users/token", ugh.Token)
-	app.Handle(http.MethodGet, "v1", "/users/:page/:rows", ugh.GetAll, middleware.Authenticate(cfg.Auth), middleware.Authorize(auth.RoleAdmin))
+	clientIP := cfg.ClientIP
+	if clientIP == nil {
+		clientIP = middleware.RemoteAddr
+	}
+
+	app.Handle(http.MethodGet, "v1", "/users/token", ugh.Token, middleware.Throttle(cfg.Log, cfg.TokenRateLimit, cfg.TokenRatePer, clientIP))
+	app.Handle(http.MethodPost, "v1", "/users/token/mfa", ugh.TokenMFA, middleware.Throttle(cfg.Log, cfg.TokenRateLimit, cfg.TokenRatePer, clientIP))
+	app.Handle(http.MethodPost, "v1", "/users/token/mfa/enroll", ugh.EnrollChallenge, middleware.Throttle(cfg.Log, cfg.TokenRateLimit, cfg.TokenRatePer, clientIP))
+	app.Handle(http.MethodPost, "v1", "/users/token/refresh", ugh.Refresh)
+	app.Handle(http.MethodPost, "v1", "/users/token/revoke", ugh.Revoke, middleware.Authenticate(cfg.Auth))
+	app.Handle(http.MethodPost, "v1", "/users/password/forgot", ugh.ForgotPassword, middleware.Throttle(cfg.Log, cfg.TokenRateLimit, cfg.TokenRatePer, clientIP))
+	app.Handle(http.MethodPost, "v1", "/users/password/reset", ugh.ResetPassword, middleware.Throttle(cfg.Log, cfg.TokenRateLimit, cfg.TokenRatePer, clientIP))
+	app.Handle(http.MethodPost, "v1", "/users/email/verify/request", ugh.RequestVerification, middleware.Authenticate(cfg.Auth))
+	app.Handle(http.MethodPost, "v1", "/users/email/verify", ugh.VerifyEmail)
+	app.Handle(http.MethodPost, "v1", "/users/mfa/enroll", ugh.EnrollMFA, middleware.Authenticate(cfg.Auth))
+	app.Handle(http.MethodPost, "v1", "/users/mfa/confirm", ugh.ConfirmMFA, middleware.Authenticate(cfg.Auth))
+	app.Handle(http.MethodPost, "v1", "/users/mfa/disable", ugh.DisableMFA, middleware.Authenticate(cfg.Auth))
+	app.Handle(http.MethodGet, "v1", "/users", ugh.GetAll, middleware.Authenticate(cfg.Auth), middleware.RequirePermission(auth.PermUsersRead))
 	app.Handle(http.MethodGet, "v1", "/users/:id", ugh.GetByID, middleware.Authenticate(cfg.Auth))
-	app.Handle(http.MethodPost, "v1", "/users", ugh.Create, middleware.Authenticate(cfg.Auth), middleware.Authorize(auth.RoleAdmin))
-	app.Handle(http.MethodPut, "v1", "/users/:id", ugh.Update, middleware.Authenticate(cfg.Auth), middleware.Authorize(auth.RoleAdmin))
-	app.Handle(http.MethodDelete, "v1", "/users/:id", ugh.Delete, middleware.Authenticate(cfg.Auth), middleware.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPost, "v1", "/users", ugh.Create, middleware.Authenticate(cfg.Auth), middleware.RequirePermission(auth.PermUsersWrite))
+	app.Handle(http.MethodPut, "v1", "/users/:id", ugh.Update, middleware.Authenticate(cfg.Auth), middleware.RequirePermission(auth.PermUsersWrite))
+	app.Handle(http.MethodDelete, "v1", "/users/:id", ugh.Delete, middleware.Authenticate(cfg.Auth), middleware.RequirePermission(auth.PermUsersWrite))
+
+	//register product handlers
+	pgh := productgrp.Handlers{
+		Product: product.NewCore(cfg.Log, cfg.DB),
+	}
+
+	app.Handle(http.MethodGet, "v1", "/products/:page/:rows", pgh.GetAll, middleware.Authenticate(cfg.Auth), middleware.RequirePermission(auth.PermProductsRead))
+	app.Handle(http.MethodGet, "v1", "/products/:id", pgh.GetByID, middleware.Authenticate(cfg.Auth), middleware.RequirePermission(auth.PermProductsRead))
+	app.Handle(http.MethodPost, "v1", "/products", pgh.Create, middleware.Authenticate(cfg.Auth), middleware.RequirePermission(auth.PermProductsWrite))
+	app.Handle(http.MethodPut, "v1", "/products/:id", pgh.Update, middleware.Authenticate(cfg.Auth), middleware.RequirePermission(auth.PermProductsWrite))
+	app.Handle(http.MethodDelete, "v1", "/products/:id", pgh.Delete, middleware.Authenticate(cfg.Auth), middleware.RequirePermission(auth.PermProductsWrite))
+
+	//register sale handlers
+	sgh := salegrp.Handlers{
+		Sale: sale.NewCore(cfg.Log, cfg.DB),
+	}
+
+	app.Handle(http.MethodPost, "v1", "/products/:id/sales", sgh.Create, middleware.Authenticate(cfg.Auth), middleware.RequirePermission(auth.PermSalesCreate))
+	app.Handle(http.MethodGet, "v1", "/products/:id/sales", sgh.GetByProductID, middleware.Authenticate(cfg.Auth), middleware.RequirePermission(auth.PermSalesRead))
+
+	//register api key handlers
+	agh := apikeygrp.Handlers{
+		APIKey: apikey.NewCore(cfg.Log, cfg.DB),
+	}
+
+	app.Handle(http.MethodGet, "v1", "/apikeys", agh.GetAll, middleware.Authenticate(cfg.Auth))
+	app.Handle(http.MethodGet, "v1", "/apikeys/:id", agh.GetByID, middleware.Authenticate(cfg.Auth))
+	app.Handle(http.MethodPost, "v1", "/apikeys", agh.Create, middleware.Authenticate(cfg.Auth))
+	app.Handle(http.MethodPut, "v1", "/apikeys/:id", agh.Update, middleware.Authenticate(cfg.Auth))
+	app.Handle(http.MethodDelete, "v1", "/apikeys/:id", agh.Delete, middleware.Authenticate(cfg.Auth))
+
+	//register audit handlers
+	audgh := auditgrp.Handlers{
+		Audit: audit.NewCore(cfg.Log, cfg.DB),
+	}
+
+	app.Handle(http.MethodGet, "v1", "/audit", audgh.GetAll, middleware.Authenticate(cfg.Auth), middleware.RequirePermission(auth.PermAuditRead))
 
 	return app
 }
@@ -0,0 +1,135 @@
+package apikeygrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/core/apikey"
+	apikeyStorage "github.com/dimashiro/service/business/data/store/apikey"
+	"github.com/dimashiro/service/foundation/webapp"
+)
+
+// Handlers manages the set of API key endpoints.
+type Handlers struct {
+	APIKey apikey.Core
+}
+
+// newKeyResponse is a new API key along with the key itself, which is only
+// ever returned once.
+type newKeyResponse struct {
+	apikeyStorage.APIKey
+	Key string `json:"key"`
+}
+
+// GetAll returns the API keys of the caller, or every key for callers allowed
+// to manage them.
+func (h Handlers) GetAll(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("no claims in context")
+	}
+
+	keys, err := h.APIKey.GetAll(ctx, claims)
+	if err != nil {
+		return fmt.Errorf("unable to query for api keys: %w", err)
+	}
+	if keys == nil {
+		keys = []apikeyStorage.APIKey{}
+	}
+
+	return webapp.Respond(ctx, w, keys, http.StatusOK)
+}
+
+// GetByID returns an API key by its ID.
+func (h Handlers) GetByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("no claims in context")
+	}
+
+	keyID := webapp.Param(r, "id")
+
+	key, err := h.APIKey.GetByID(ctx, claims, keyID)
+	if err != nil {
+		return fmt.Errorf("ID[%s]: %w", keyID, err)
+	}
+
+	return webapp.Respond(ctx, w, key, http.StatusOK)
+}
+
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := webapp.GetValues(ctx)
+	if err != nil {
+		return webapp.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("no claims in context")
+	}
+
+	var nk apikeyStorage.NewAPIKeyDTO
+	if err := webapp.Decode(r, &nk); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	key, value, err := h.APIKey.Create(ctx, claims, nk, v.Now)
+	if err != nil {
+		return fmt.Errorf("creating new api key, nk[%+v]: %w", nk, err)
+	}
+
+	resp := newKeyResponse{
+		APIKey: key,
+		Key:    value,
+	}
+
+	return webapp.Respond(ctx, w, resp, http.StatusCreated)
+}
+
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := webapp.GetValues(ctx)
+	if err != nil {
+		return webapp.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("no claims in context")
+	}
+
+	var upd apikeyStorage.UpdateAPIKeyDTO
+	if err := webapp.Decode(r, &upd); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	keyID := webapp.Param(r, "id")
+
+	if err := h.APIKey.Update(ctx, claims, keyID, upd, v.Now); err != nil {
+		return fmt.Errorf("ID[%s] APIKey[%+v]: %w", keyID, &upd, err)
+	}
+
+	return webapp.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := webapp.GetValues(ctx)
+	if err != nil {
+		return webapp.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("no claims in context")
+	}
+
+	keyID := webapp.Param(r, "id")
+
+	if err := h.APIKey.Delete(ctx, claims, keyID, v.Now); err != nil {
+		return fmt.Errorf("ID[%s]: %w", keyID, err)
+	}
+
+	return webapp.Respond(ctx, w, nil, http.StatusNoContent)
+}
@@ -0,0 +1,79 @@
+package auditgrp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dimashiro/service/business/core/audit"
+	auditStorage "github.com/dimashiro/service/business/data/store/audit"
+	"github.com/dimashiro/service/business/validate"
+	"github.com/dimashiro/service/foundation/webapp"
+)
+
+// Handlers manages the set of audit endpoints.
+type Handlers struct {
+	Audit audit.Core
+}
+
+// eventsResponse is a page of events with the cursor of the next page.
+type eventsResponse struct {
+	Items      []auditStorage.Event `json:"items"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// GetAll returns a page of audit events, the most recent first, filtered by
+// the query string:
+// ?actor=&action=&target_type=&target_id=&since=&until=&cursor=&limit=
+func (h Handlers) GetAll(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	values := r.URL.Query()
+
+	qf := auditStorage.QueryFilter{
+		Actor:      values.Get("actor"),
+		Action:     values.Get("action"),
+		TargetType: values.Get("target_type"),
+		TargetID:   values.Get("target_id"),
+		Cursor:     values.Get("cursor"),
+	}
+
+	for _, p := range []struct {
+		name string
+		dest **time.Time
+	}{
+		{"since", &qf.Since},
+		{"until", &qf.Until},
+	} {
+		if s := values.Get(p.name); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return validate.NewRequestError(fmt.Errorf("invalid %s format [%s]", p.name, s), http.StatusBadRequest)
+			}
+			*p.dest = &t
+		}
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return validate.NewRequestError(fmt.Errorf("invalid limit format [%s]", limit), http.StatusBadRequest)
+		}
+		qf.Limit = n
+	}
+
+	evts, next, err := h.Audit.GetAll(ctx, qf)
+	if err != nil {
+		return fmt.Errorf("unable to query for audit events: %w", err)
+	}
+
+	resp := eventsResponse{
+		Items:      evts,
+		NextCursor: next,
+	}
+	if resp.Items == nil {
+		resp.Items = []auditStorage.Event{}
+	}
+
+	return webapp.Respond(ctx, w, resp, http.StatusOK)
+}
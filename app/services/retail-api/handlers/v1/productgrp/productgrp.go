@@ -0,0 +1,130 @@
+package productgrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/core/product"
+	productStorage "github.com/dimashiro/service/business/data/store/product"
+	"github.com/dimashiro/service/business/validate"
+	"github.com/dimashiro/service/foundation/webapp"
+)
+
+// Handlers manages the set of product enpoints.
+type Handlers struct {
+	Product product.Core
+}
+
+// maxRowsPerPage is the largest page of products that can be requested.
+const maxRowsPerPage = 1000
+
+// GetAll returns a page of products.
+func (h Handlers) GetAll(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	page := webapp.Param(r, "page")
+	pageNumber, err := strconv.Atoi(page)
+	if err != nil || pageNumber < 1 {
+		return validate.NewRequestError(fmt.Errorf("invalid page format [%s]", page), http.StatusBadRequest)
+	}
+	rows := webapp.Param(r, "rows")
+	rowsPerPage, err := strconv.Atoi(rows)
+	if err != nil || rowsPerPage < 1 || rowsPerPage > maxRowsPerPage {
+		return validate.NewRequestError(fmt.Errorf("invalid rows format [%s]", rows), http.StatusBadRequest)
+	}
+
+	prds, err := h.Product.GetAll(ctx, pageNumber, rowsPerPage)
+	if err != nil {
+		return fmt.Errorf("unable to query for products: %w", err)
+	}
+
+	return webapp.Respond(ctx, w, prds, http.StatusOK)
+}
+
+// GetByID returns a product by its ID.
+func (h Handlers) GetByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	productID := webapp.Param(r, "id")
+
+	prd, err := h.Product.GetByID(ctx, productID)
+	if err != nil {
+		return fmt.Errorf("ID[%s]: %w", productID, err)
+	}
+
+	return webapp.Respond(ctx, w, prd, http.StatusOK)
+}
+
+// Create adds a new product owned by the caller.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := webapp.GetValues(ctx)
+	if err != nil {
+		return webapp.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("no claims in context")
+	}
+
+	var np productStorage.NewProductDTO
+	if err := webapp.Decode(r, &np); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	prd, err := h.Product.Create(ctx, claims, np, v.Now)
+	if err != nil {
+		return fmt.Errorf("creating new product, np[%+v]: %w", np, err)
+	}
+
+	return webapp.Respond(ctx, w, prd, http.StatusCreated)
+}
+
+// Update updates a product the caller owns, or any product with the manage
+// permission.
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := webapp.GetValues(ctx)
+	if err != nil {
+		return webapp.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("no claims in context")
+	}
+
+	var upd productStorage.UpdateProductDTO
+	if err := webapp.Decode(r, &upd); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	productID := webapp.Param(r, "id")
+
+	if err := h.Product.Update(ctx, claims, productID, upd, v.Now); err != nil {
+		return fmt.Errorf("ID[%s] Product[%+v]: %w", productID, &upd, err)
+	}
+
+	return webapp.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Delete removes a product the caller owns, or any product with the manage
+// permission.
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := webapp.GetValues(ctx)
+	if err != nil {
+		return webapp.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("no claims in context")
+	}
+
+	productID := webapp.Param(r, "id")
+
+	if err := h.Product.Delete(ctx, claims, productID, v.Now); err != nil {
+		return fmt.Errorf("ID[%s]: %w", productID, err)
+	}
+
+	return webapp.Respond(ctx, w, nil, http.StatusNoContent)
+}
@@ -0,0 +1,57 @@
+package salegrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/core/sale"
+	saleStorage "github.com/dimashiro/service/business/data/store/sale"
+	"github.com/dimashiro/service/foundation/webapp"
+)
+
+// Handlers manages the set of sale enpoints.
+type Handlers struct {
+	Sale sale.Core
+}
+
+// Create records a sale of the product and decrements its stock.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := webapp.GetValues(ctx)
+	if err != nil {
+		return webapp.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("no claims in context")
+	}
+
+	var ns saleStorage.NewSaleDTO
+	if err := webapp.Decode(r, &ns); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	productID := webapp.Param(r, "id")
+
+	sl, err := h.Sale.Create(ctx, claims, productID, ns, v.Now)
+	if err != nil {
+		return fmt.Errorf("ID[%s] Sale[%+v]: %w", productID, &ns, err)
+	}
+
+	return webapp.Respond(ctx, w, sl, http.StatusCreated)
+}
+
+// GetByProductID returns the sales recorded for a product.
+func (h Handlers) GetByProductID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	productID := webapp.Param(r, "id")
+
+	sls, err := h.Sale.GetByProductID(ctx, productID)
+	if err != nil {
+		return fmt.Errorf("ID[%s]: %w", productID, err)
+	}
+
+	return webapp.Respond(ctx, w, sls, http.StatusOK)
+}
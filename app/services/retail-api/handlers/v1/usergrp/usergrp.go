@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/dimashiro/service/business/auth"
 	"github.com/dimashiro/service/business/core/user"
@@ -17,28 +19,92 @@ import (
 
 // Handlers manages the set of user enpoints.
 type Handlers struct {
-	User user.Core
-	Auth *auth.Auth
+	User       user.Core
+	Auth       *auth.Auth
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	Lockout    userStorage.Lockout
+	ResetTTL   time.Duration
+	VerifyTTL  time.Duration
+
+	// MFA settings: the issuer shown by authenticator apps and how long a
+	// login challenge can be completed.
+	MFAIssuer    string
+	ChallengeTTL time.Duration
+}
+
+// tokenResponse is the set of tokens handed to an authenticated user.
+type tokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresAt    int64  `json:"expires_at"`
+
+	// RecoveryCodes are only set when the login completed an MFA enrollment.
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// challengeResponse is handed to a user who must provide a one-time password
+// to complete the login.
+type challengeResponse struct {
+	Challenge string `json:"mfa_challenge"`
+	Enroll    bool   `json:"mfa_enroll"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// recoveryCodesResponse is the set of recovery codes of a user.
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
 }
 
+// usersResponse is a page of users with the cursor of the next page.
+type usersResponse struct {
+	Items      []userStorage.User `json:"items"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+// GetAll returns a page of users filtered by the query string:
+// ?name=&email=&role=&created_after=&order_by=&cursor=&limit=
 func (h Handlers) GetAll(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	page := webapp.Param(r, "page")
-	pageNumber, err := strconv.Atoi(page)
-	if err != nil {
-		return validate.NewRequestError(fmt.Errorf("invalid page format [%s]", page), http.StatusBadRequest)
+	values := r.URL.Query()
+
+	qf := userStorage.QueryFilter{
+		Name:    values.Get("name"),
+		Email:   values.Get("email"),
+		Role:    values.Get("role"),
+		OrderBy: values.Get("order_by"),
+		Cursor:  values.Get("cursor"),
 	}
-	rows := webapp.Param(r, "rows")
-	rowsPerPage, err := strconv.Atoi(rows)
-	if err != nil {
-		return validate.NewRequestError(fmt.Errorf("invalid rows format [%s]", rows), http.StatusBadRequest)
+
+	if after := values.Get("created_after"); after != "" {
+		t, err := time.Parse(time.RFC3339, after)
+		if err != nil {
+			return validate.NewRequestError(fmt.Errorf("invalid created_after format [%s]", after), http.StatusBadRequest)
+		}
+		qf.CreatedAfter = &t
 	}
 
-	users, err := h.User.GetAll(ctx, pageNumber, rowsPerPage)
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return validate.NewRequestError(fmt.Errorf("invalid limit format [%s]", limit), http.StatusBadRequest)
+		}
+		qf.Limit = n
+	}
+
+	users, next, err := h.User.GetAll(ctx, qf)
 	if err != nil {
 		return fmt.Errorf("unable to query for users: %w", err)
 	}
 
-	return webapp.Respond(ctx, w, users, http.StatusOK)
+	resp := usersResponse{
+		Items:      users,
+		NextCursor: next,
+	}
+	if resp.Items == nil {
+		resp.Items = []userStorage.User{}
+	}
+
+	return webapp.Respond(ctx, w, resp, http.StatusOK)
 }
 
 // QueryByID returns a user by its ID.
@@ -52,14 +118,7 @@ func (h Handlers) GetByID(ctx context.Context, w http.ResponseWriter, r *http.Re
 
 	usr, err := h.User.GetByID(ctx, claims, userID)
 	if err != nil {
-		switch {
-		case errors.Is(err, database.ErrInvalidID):
-			return validate.NewRequestError(err, http.StatusBadRequest)
-		case errors.Is(err, database.ErrDBNotFound):
-			return validate.NewRequestError(err, http.StatusNotFound)
-		default:
-			return fmt.Errorf("ID[%s]: %w", userID, err)
-		}
+		return fmt.Errorf("ID[%s]: %w", userID, err)
 	}
 
 	return webapp.Respond(ctx, w, usr, http.StatusOK)
@@ -71,12 +130,17 @@ func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Req
 		return webapp.NewShutdownError("web value missing from context")
 	}
 
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("no claims in context")
+	}
+
 	var nu userStorage.NewUserDTO
 	if err := webapp.Decode(r, &nu); err != nil {
 		return fmt.Errorf("unable to decode payload: %w", err)
 	}
 
-	usr, err := h.User.Create(ctx, nu, v.Now)
+	usr, err := h.User.Create(ctx, claims, nu, v.Now)
 	if err != nil {
 		return fmt.Errorf("user[%+v]: %w", &usr, err)
 	}
@@ -103,35 +167,26 @@ func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Req
 	userID := webapp.Param(r, "id")
 
 	if err := h.User.Update(ctx, claims, userID, upd, v.Now); err != nil {
-		switch {
-		case errors.Is(err, database.ErrInvalidID):
-			return validate.NewRequestError(err, http.StatusBadRequest)
-		case errors.Is(err, database.ErrDBNotFound):
-			return validate.NewRequestError(err, http.StatusNotFound)
-		default:
-			return fmt.Errorf("ID[%s] User[%+v]: %w", userID, &upd, err)
-		}
+		return fmt.Errorf("ID[%s] User[%+v]: %w", userID, &upd, err)
 	}
 
 	return webapp.Respond(ctx, w, nil, http.StatusNoContent)
 }
 
 func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := webapp.GetValues(ctx)
+	if err != nil {
+		return webapp.NewShutdownError("web value missing from context")
+	}
+
 	claims, err := auth.GetClaims(ctx)
 	if err != nil {
 		return errors.New("no claims in context")
 	}
 
 	userID := webapp.Param(r, "id")
-	if err := h.User.Delete(ctx, claims, userID); err != nil {
-		switch {
-		case errors.Is(err, database.ErrInvalidID):
-			return validate.NewRequestError(err, http.StatusBadRequest)
-		case errors.Is(err, database.ErrDBNotFound):
-			return validate.NewRequestError(err, http.StatusNotFound)
-		default:
-			return fmt.Errorf("ID[%s]: %w", userID, err)
-		}
+	if err := h.User.Delete(ctx, claims, userID, v.Now); err != nil {
+		return fmt.Errorf("ID[%s]: %w", userID, err)
 	}
 
 	return webapp.Respond(ctx, w, nil, http.StatusNoContent)
@@ -149,25 +204,351 @@ func (h Handlers) Token(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		return validate.NewRequestError(err, http.StatusUnauthorized)
 	}
 
-	claims, err := h.User.Authenticate(ctx, v.Now, email, pass)
+	usr, challenge, err := h.User.Authenticate(ctx, v.Now, email, pass, h.Lockout, h.ChallengeTTL)
+	if err != nil {
+		return fmt.Errorf("authenticating: %w", err)
+	}
+
+	if challenge.Token != "" {
+		resp := challengeResponse{
+			Challenge: challenge.Token,
+			Enroll:    challenge.Enroll,
+			ExpiresAt: challenge.ExpiresAt.Unix(),
+		}
+		return webapp.Respond(ctx, w, resp, http.StatusAccepted)
+	}
+
+	refreshToken, err := h.User.IssueRefreshToken(ctx, usr.ID, v.Now, h.RefreshTTL)
+	if err != nil {
+		return fmt.Errorf("issuing refresh token: %w", err)
+	}
+
+	tkn, err := h.tokens(ctx, usr.ID, usr.Roles, refreshToken, v.Now)
+	if err != nil {
+		return err
+	}
+
+	return webapp.Respond(ctx, w, tkn, http.StatusOK)
+}
+
+// TokenMFA completes a login challenge with a one-time password, or a
+// recovery code, and issues the tokens.
+func (h Handlers) TokenMFA(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := webapp.GetValues(ctx)
+	if err != nil {
+		return webapp.NewShutdownError("web value missing from context")
+	}
+
+	var req struct {
+		Challenge string `json:"mfa_challenge" validate:"required"`
+		Code      string `json:"code" validate:"required"`
+	}
+	if err := webapp.Decode(r, &req); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+	if err := validate.Check(req); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	usr, codes, err := h.User.CompleteChallenge(ctx, req.Challenge, req.Code, v.Now)
+	if err != nil {
+		return fmt.Errorf("completing challenge: %w", err)
+	}
+
+	refreshToken, err := h.User.IssueRefreshToken(ctx, usr.ID, v.Now, h.RefreshTTL)
+	if err != nil {
+		return fmt.Errorf("issuing refresh token: %w", err)
+	}
+
+	tkn, err := h.tokens(ctx, usr.ID, usr.Roles, refreshToken, v.Now)
+	if err != nil {
+		return err
+	}
+	tkn.RecoveryCodes = codes
+
+	return webapp.Respond(ctx, w, tkn, http.StatusOK)
+}
+
+// EnrollChallenge returns a new TOTP secret to a user who must enroll one to
+// complete their login challenge.
+func (h Handlers) EnrollChallenge(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := webapp.GetValues(ctx)
+	if err != nil {
+		return webapp.NewShutdownError("web value missing from context")
+	}
+
+	var req struct {
+		Challenge string `json:"mfa_challenge" validate:"required"`
+	}
+	if err := webapp.Decode(r, &req); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+	if err := validate.Check(req); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	enr, err := h.User.EnrollChallenge(ctx, req.Challenge, h.MFAIssuer, v.Now)
+	if err != nil {
+		return fmt.Errorf("enrolling: %w", err)
+	}
+
+	return webapp.Respond(ctx, w, enr, http.StatusOK)
+}
+
+// EnrollMFA returns a new TOTP secret to the authenticated user. MFA is
+// enabled once confirmed with ConfirmMFA.
+func (h Handlers) EnrollMFA(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := webapp.GetValues(ctx)
+	if err != nil {
+		return webapp.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("no claims in context")
+	}
+
+	enr, err := h.User.EnrollMFA(ctx, claims, h.MFAIssuer, v.Now)
+	if err != nil {
+		return fmt.Errorf("enrolling: %w", err)
+	}
+
+	return webapp.Respond(ctx, w, enr, http.StatusOK)
+}
+
+// ConfirmMFA enables MFA for the authenticated user and returns their
+// recovery codes.
+func (h Handlers) ConfirmMFA(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := webapp.GetValues(ctx)
+	if err != nil {
+		return webapp.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("no claims in context")
+	}
+
+	var req struct {
+		Code string `json:"code" validate:"required"`
+	}
+	if err := webapp.Decode(r, &req); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+	if err := validate.Check(req); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	codes, err := h.User.ConfirmMFA(ctx, claims, req.Code, v.Now)
+	if err != nil {
+		return fmt.Errorf("confirming: %w", err)
+	}
+
+	return webapp.Respond(ctx, w, recoveryCodesResponse{RecoveryCodes: codes}, http.StatusOK)
+}
+
+// DisableMFA turns MFA off for the authenticated user.
+func (h Handlers) DisableMFA(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := webapp.GetValues(ctx)
+	if err != nil {
+		return webapp.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("no claims in context")
+	}
+
+	var req struct {
+		Code string `json:"code" validate:"required"`
+	}
+	if err := webapp.Decode(r, &req); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+	if err := validate.Check(req); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	if err := h.User.DisableMFA(ctx, claims, req.Code, v.Now); err != nil {
+		return fmt.Errorf("disabling: %w", err)
+	}
+
+	return webapp.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token. The presented refresh token can't be used again.
+func (h Handlers) Refresh(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := webapp.GetValues(ctx)
+	if err != nil {
+		return webapp.NewShutdownError("web value missing from context")
+	}
+
+	var req struct {
+		RefreshToken string `json:"refresh_token" validate:"required"`
+	}
+	if err := webapp.Decode(r, &req); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+	if err := validate.Check(req); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	usr, refreshToken, err := h.User.Refresh(ctx, req.RefreshToken, v.Now, h.RefreshTTL)
 	if err != nil {
 		switch {
-		case errors.Is(err, database.ErrDBNotFound):
-			return validate.NewRequestError(err, http.StatusNotFound)
-		case errors.Is(err, database.ErrAuthenticationFailure):
-			return validate.NewRequestError(err, http.StatusUnauthorized)
+		case errors.Is(err, database.ErrAuthenticationFailure), errors.Is(err, database.ErrDBNotFound):
+			return validate.NewRequestError(errors.New("invalid refresh token"), http.StatusUnauthorized)
 		default:
-			return fmt.Errorf("authenticating: %w", err)
+			return fmt.Errorf("refreshing: %w", err)
 		}
 	}
 
-	var tkn struct {
-		Token string `json:"token"`
-	}
-	tkn.Token, err = h.Auth.GenerateToken(claims)
+	tkn, err := h.tokens(ctx, usr.ID, usr.Roles, refreshToken, v.Now)
 	if err != nil {
-		return fmt.Errorf("generating token: %w", err)
+		return err
 	}
 
 	return webapp.Respond(ctx, w, tkn, http.StatusOK)
 }
+
+// Revoke kills the access token used for the request and the refresh token
+// from the payload, if one is provided.
+func (h Handlers) Revoke(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := webapp.GetValues(ctx)
+	if err != nil {
+		return webapp.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("no claims in context")
+	}
+
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := webapp.Decode(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	if err := h.User.Revoke(ctx, claims, req.RefreshToken, v.Now); err != nil {
+		return fmt.Errorf("revoking: %w", err)
+	}
+
+	return webapp.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// ForgotPassword mails a password reset token to the user with the email
+// from the payload. It always succeeds so the response doesn't reveal which
+// accounts exist.
+func (h Handlers) ForgotPassword(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := webapp.GetValues(ctx)
+	if err != nil {
+		return webapp.NewShutdownError("web value missing from context")
+	}
+
+	var req struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+	if err := webapp.Decode(r, &req); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+	if err := validate.Check(req); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	if err := h.User.RequestPasswordReset(ctx, req.Email, v.Now, h.ResetTTL); err != nil {
+		return fmt.Errorf("requesting password reset: %w", err)
+	}
+
+	return webapp.Respond(ctx, w, nil, http.StatusAccepted)
+}
+
+// ResetPassword sets a new password using a reset token.
+func (h Handlers) ResetPassword(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := webapp.GetValues(ctx)
+	if err != nil {
+		return webapp.NewShutdownError("web value missing from context")
+	}
+
+	var rp userStorage.ResetPasswordDTO
+	if err := webapp.Decode(r, &rp); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	if err := h.User.ResetPassword(ctx, rp, v.Now); err != nil {
+		return fmt.Errorf("resetting password: %w", err)
+	}
+
+	return webapp.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// RequestVerification mails an email verification token to the
+// authenticated user.
+func (h Handlers) RequestVerification(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := webapp.GetValues(ctx)
+	if err != nil {
+		return webapp.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("no claims in context")
+	}
+
+	if err := h.User.RequestVerification(ctx, claims, v.Now, h.VerifyTTL); err != nil {
+		return fmt.Errorf("requesting verification: %w", err)
+	}
+
+	return webapp.Respond(ctx, w, nil, http.StatusAccepted)
+}
+
+// VerifyEmail marks an email as verified using a verification token.
+func (h Handlers) VerifyEmail(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := webapp.GetValues(ctx)
+	if err != nil {
+		return webapp.NewShutdownError("web value missing from context")
+	}
+
+	var req struct {
+		Token string `json:"token" validate:"required"`
+	}
+	if err := webapp.Decode(r, &req); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+	if err := validate.Check(req); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	if err := h.User.VerifyEmail(ctx, req.Token, v.Now); err != nil {
+		return fmt.Errorf("verifying email: %w", err)
+	}
+
+	return webapp.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// tokens signs a new access token and packs it with the refresh token. The
+// permissions of the roles are resolved now and embedded in the token.
+func (h Handlers) tokens(ctx context.Context, userID string, roles []string, refreshToken string, now time.Time) (tokenResponse, error) {
+	perms, err := h.User.Permissions(ctx, roles)
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("resolving permissions: %w", err)
+	}
+
+	claims := auth.NewClaims(userID, roles, now, h.AccessTTL)
+	claims.Permissions = perms
+
+	token, err := h.Auth.GenerateToken(claims)
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("generating token: %w", err)
+	}
+
+	tkn := tokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresAt:    claims.ExpiresAt,
+	}
+
+	return tkn, nil
+}
@@ -0,0 +1,70 @@
+// Package wellknown maintains the group of handlers for the discovery
+// documents other services use to verify our tokens.
+package wellknown
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/dimashiro/service/foundation/keystore"
+	"github.com/dimashiro/service/foundation/webapp"
+)
+
+// KeySet declares the behavior required to publish the public keys.
+type KeySet interface {
+	JWKS() keystore.JWKSet
+}
+
+// Handlers manages the set of well-known enpoints. The issuer and the base
+// URL are configured, never taken from the request, since the documents are
+// cached by the clients and the proxies in front of us.
+type Handlers struct {
+	Keys    KeySet
+	Issuer  string
+	BaseURL string
+}
+
+// JWKS returns every public key that can be used to verify a token.
+func (h Handlers) JWKS(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	return webapp.Respond(ctx, w, h.Keys.JWKS(), http.StatusOK)
+}
+
+// OpenIDConfiguration returns the discovery document pointing at the key set.
+func (h Handlers) OpenIDConfiguration(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	base := strings.TrimSuffix(h.BaseURL, "/")
+
+	doc := struct {
+		Issuer            string   `json:"issuer"`
+		JWKSURI           string   `json:"jwks_uri"`
+		TokenEndpoint     string   `json:"token_endpoint"`
+		SigningAlgorithms []string `json:"id_token_signing_alg_values_supported"`
+		ClaimsSupported   []string `json:"claims_supported"`
+	}{
+		Issuer:            h.Issuer,
+		JWKSURI:           base + "/.well-known/jwks.json",
+		TokenEndpoint:     base + "/v1/users/token",
+		SigningAlgorithms: algorithms(h.Keys.JWKS()),
+		ClaimsSupported:   []string{"iss", "sub", "exp", "iat", "jti", "roles"},
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	return webapp.Respond(ctx, w, doc, http.StatusOK)
+}
+
+// algorithms returns the distinct signing algorithms used by the key set.
+func algorithms(set keystore.JWKSet) []string {
+	seen := make(map[string]bool)
+	algs := []string{}
+	for _, k := range set.Keys {
+		if !seen[k.Algorithm] {
+			seen[k.Algorithm] = true
+			algs = append(algs, k.Algorithm)
+		}
+	}
+
+	return algs
+}
@@ -0,0 +1,117 @@
+package wellknown_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dimashiro/service/app/services/retail-api/handlers/wellknown"
+	"github.com/dimashiro/service/foundation/keystore"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestWellKnown(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Should be able to create an RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Should be able to create an ECDSA key: %v", err)
+	}
+
+	h := wellknown.Handlers{
+		Keys: keystore.NewMap(map[string]crypto.Signer{
+			"rsa-key": rsaKey,
+			"ec-key":  ecKey,
+		}),
+		Issuer:  "https://api.example.com",
+		BaseURL: "https://api.example.com/",
+	}
+
+	t.Log("Given the need to publish the keys that verify our tokens.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen requesting the key set.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+			w := httptest.NewRecorder()
+
+			if err := h.JWKS(context.Background(), w, r); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to serve the key set : %v.", failed, testID, err)
+			}
+
+			if w.Code != http.StatusOK || w.Header().Get("Cache-Control") == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould serve a cacheable 200 : got %d %q.", failed, testID, w.Code, w.Header().Get("Cache-Control"))
+			}
+			t.Logf("\t%s\tTest %d:\tShould serve a cacheable 200.", success, testID)
+
+			var set keystore.JWKSet
+			if err := json.Unmarshal(w.Body.Bytes(), &set); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the key set : %v.", failed, testID, err)
+			}
+
+			if len(set.Keys) != 2 || set.Keys[0].KeyID != "ec-key" || set.Keys[1].KeyID != "rsa-key" {
+				t.Fatalf("\t%s\tTest %d:\tShould publish every key ordered by kid : got %+v.", failed, testID, set.Keys)
+			}
+			t.Logf("\t%s\tTest %d:\tShould publish every key ordered by kid.", success, testID)
+
+			for _, k := range set.Keys {
+				if k.Use != "sig" {
+					t.Fatalf("\t%s\tTest %d:\tShould publish signing keys : got %q for %s.", failed, testID, k.Use, k.KeyID)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould publish signing keys.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen requesting the discovery document with forged headers.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+			r.Host = "evil.example.com"
+			r.Header.Set("X-Forwarded-Proto", "http")
+			w := httptest.NewRecorder()
+
+			if err := h.OpenIDConfiguration(context.Background(), w, r); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to serve the document : %v.", failed, testID, err)
+			}
+
+			var doc struct {
+				Issuer            string   `json:"issuer"`
+				JWKSURI           string   `json:"jwks_uri"`
+				TokenEndpoint     string   `json:"token_endpoint"`
+				SigningAlgorithms []string `json:"id_token_signing_alg_values_supported"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the document : %v.", failed, testID, err)
+			}
+
+			if doc.Issuer != h.Issuer {
+				t.Fatalf("\t%s\tTest %d:\tShould name our issuer : got %q.", failed, testID, doc.Issuer)
+			}
+			t.Logf("\t%s\tTest %d:\tShould name our issuer.", success, testID)
+
+			if doc.JWKSURI != "https://api.example.com/.well-known/jwks.json" || doc.TokenEndpoint != "https://api.example.com/v1/users/token" {
+				t.Fatalf("\t%s\tTest %d:\tShould point at the configured address : got %q %q.", failed, testID, doc.JWKSURI, doc.TokenEndpoint)
+			}
+			t.Logf("\t%s\tTest %d:\tShould point at the configured address.", success, testID)
+
+			if len(doc.SigningAlgorithms) != 2 || doc.SigningAlgorithms[0] != "ES256" || doc.SigningAlgorithms[1] != "RS256" {
+				t.Fatalf("\t%s\tTest %d:\tShould list the algorithms of the keys : got %v.", failed, testID, doc.SigningAlgorithms)
+			}
+			t.Logf("\t%s\tTest %d:\tShould list the algorithms of the keys.", success, testID)
+		}
+	}
+}
@@ -2,19 +2,40 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"runtime"
 	"syscall"
 	"time"
 
+	"github.com/dimashiro/service/app/config"
 	"github.com/dimashiro/service/app/services/retail-api/handlers"
 	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/core/apikey"
+	"github.com/dimashiro/service/business/data/schema"
+	"github.com/dimashiro/service/business/data/store/token"
+	userStorage "github.com/dimashiro/service/business/data/store/user"
 	"github.com/dimashiro/service/business/database"
+	"github.com/dimashiro/service/business/mailer"
+	"github.com/dimashiro/service/business/metrics"
+	"github.com/dimashiro/service/business/middleware"
+	"github.com/dimashiro/service/foundation/health"
 	"github.com/dimashiro/service/foundation/keystore"
 	"github.com/ilyakaznacheev/cleanenv"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
 	"go.uber.org/automaxprocs/maxprocs"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -52,21 +73,51 @@ func run(log *zap.SugaredLogger) error {
 	//__________________________________________________________________________
 	// Config
 	cfg := struct {
-		APIHost         string        `env:"APIHOST" env-default:"0.0.0.0:3000"`
-		DebugHost       string        `env:"DEBUGHOST" env-default:"0.0.0.0:4000"`
-		ReadTimeout     time.Duration `env:"READTIMEOUT" env-default:"5s"`
-		WriteTimeout    time.Duration `env:"WRITETIMEOUT" env-default:"10s"`
-		IdleTimeout     time.Duration `env:"IDLETIMEOUT" env-default:"120s"`
-		ShutdownTimeout time.Duration `env:"SHUTDOWNTIMEOUT" env-default:"20s"`
-		AuthKeysFolder  string        `env:"AUTHKEYSFOLDER" env-default:"deploy/keys/"`
-		AuthActiveKID   string        `env:"AUTHACTIVEKID" env-default:"developmentkeyid"`
-		DBUser          string        `env:"DBUSER" env-default:"postgres"`
-		DBPassword      string        `env:"DBPASSWORD" env-default:"postgres,mask"`
-		DBHost          string        `env:"DBHOST" env-default:"localhost"`
-		DBName          string        `env:"DBNAME" env-default:"postgres"`
-		DBMaxIdleConns  int           `env:"DBMAXIDLECONNS" env-default:"0"`
-		DBMaxOpenConns  int           `env:"DBMAXOPENCONNS" env-default:"0"`
-		DBDisableTLS    bool          `env:"DBDISABLETLS" env-default:"true"`
+		APIHost          string        `env:"APIHOST" env-default:"0.0.0.0:3000"`
+		DebugHost        string        `env:"DEBUGHOST" env-default:"0.0.0.0:4000"`
+		APIBaseURL       string        `env:"APIBASEURL" env-default:"http://localhost:3000"`
+		HealthTimeout    time.Duration `env:"HEALTHTIMEOUT" env-default:"1s"`
+		HealthCacheTTL   time.Duration `env:"HEALTHCACHETTL" env-default:"2s"`
+		ReadTimeout      time.Duration `env:"READTIMEOUT" env-default:"5s"`
+		WriteTimeout     time.Duration `env:"WRITETIMEOUT" env-default:"10s"`
+		IdleTimeout      time.Duration `env:"IDLETIMEOUT" env-default:"120s"`
+		ShutdownTimeout  time.Duration `env:"SHUTDOWNTIMEOUT" env-default:"20s"`
+		Keys             config.Keys
+		AuthKeysReload   time.Duration `env:"AUTHKEYSRELOAD" env-default:"1m"`
+		AuthKeysGrace    time.Duration `env:"AUTHKEYSGRACE" env-default:"1h"`
+		AuthAccessTTL    time.Duration `env:"AUTHACCESSTTL" env-default:"1h"`
+		AuthRefreshTTL   time.Duration `env:"AUTHREFRESHTTL" env-default:"720h"`
+		AuthMaxFailures  int           `env:"AUTHMAXFAILURES" env-default:"5"`
+		AuthLockout      time.Duration `env:"AUTHLOCKOUT" env-default:"15m"`
+		AuthTokenRate    int           `env:"AUTHTOKENRATE" env-default:"20"`
+		AuthProxies      []string      `env:"AUTHTRUSTEDPROXIES" env-default:""`
+		AuthClientHeader string        `env:"AUTHCLIENTIPHEADER" env-default:"X-Forwarded-For"`
+		AuthTokenPer     time.Duration `env:"AUTHTOKENPER" env-default:"1m"`
+		AuthResetTTL     time.Duration `env:"AUTHRESETTTL" env-default:"1h"`
+		AuthVerifyTTL    time.Duration `env:"AUTHVERIFYTTL" env-default:"48h"`
+		AuthMFAIssuer    string        `env:"AUTHMFAISSUER" env-default:"retail-api"`
+		AuthMFATTL       time.Duration `env:"AUTHMFATTL" env-default:"5m"`
+		AuthMFAKey       string        `env:"AUTHMFAKEY" env-default:""`
+		Mailer           string        `env:"MAILER" env-default:""`
+		MailFrom         string        `env:"MAILFROM" env-default:"no-reply@localhost"`
+		MailDir          string        `env:"MAILDIR" env-default:"mail/"`
+		SMTPHost         string        `env:"SMTPHOST" env-default:"localhost"`
+		SMTPPort         int           `env:"SMTPPORT" env-default:"587"`
+		SMTPUser         string        `env:"SMTPUSER" env-default:""`
+		SMTPPassword     string        `env:"SMTPPASSWORD" env-default:""`
+		SMTPTimeout      time.Duration `env:"SMTPTIMEOUT" env-default:"10s"`
+		DB               config.DB
+		DBLogLevel       string        `env:"DBLOGLEVEL" env-default:"info"`
+		DBLogNamesOnly   bool          `env:"DBLOGNAMESONLY" env-default:"false"`
+		DBLogRedact      []string      `env:"DBLOGREDACT" env-default:"password_hash,token_hash,key_hash,code_hash,mfa_secret,email,cursor_value"`
+		DBSlowThreshold  time.Duration `env:"DBSLOWTHRESHOLD" env-default:"500ms"`
+		MigrateOnStart   bool          `env:"MIGRATEONSTART" env-default:"false"`
+		MigrateTimeout   time.Duration `env:"MIGRATETIMEOUT" env-default:"5m"`
+		TraceService     string        `env:"TRACESERVICE" env-default:"retail-api"`
+		TraceExporter    string        `env:"TRACEEXPORTER" env-default:"none"`
+		TraceEndpoint    string        `env:"TRACEENDPOINT" env-default:""`
+		TraceInsecure    bool          `env:"TRACEINSECURE" env-default:"true"`
+		TraceProbability float64       `env:"TRACEPROBABILITY" env-default:"0.05"`
 	}{}
 
 	err := cleanenv.ReadEnv(&cfg)
@@ -74,28 +125,211 @@ func run(log *zap.SugaredLogger) error {
 		return fmt.Errorf("loading conf: %w", err)
 	}
 
+	//__________________________________________________________________________
+	// Health checks
+
+	// The subsystems register the checks of their dependencies as they are
+	// initialized, the readiness endpoint reports them all.
+	hr := health.New(health.Config{
+		Timeout: cfg.HealthTimeout,
+		TTL:     cfg.HealthCacheTTL,
+	})
+
 	//__________________________________________________________________________
 	// Database
 
-	log.Infow("start", "status", "initializing database support", "host", cfg.DBHost)
+	log.Infow("start", "status", "initializing database support", "host", cfg.DB.Host)
 
-	db, err := database.Open(database.Config{
-		User:         cfg.DBUser,
-		Password:     cfg.DBPassword,
-		Host:         cfg.DBHost,
-		Name:         cfg.DBName,
-		MaxIdleConns: cfg.DBMaxIdleConns,
-		MaxOpenConns: cfg.DBMaxOpenConns,
-		DisableTLS:   cfg.DBDisableTLS,
+	var dbLogLevel zapcore.Level
+	if err := dbLogLevel.UnmarshalText([]byte(cfg.DBLogLevel)); err != nil {
+		return fmt.Errorf("parsing db log level: %w", err)
+	}
+
+	// The stores log their queries following the configuration held by the
+	// logger they are given.
+	log = database.WithLogConfig(log, database.LogConfig{
+		Level:         dbLogLevel,
+		NamesOnly:     cfg.DBLogNamesOnly,
+		Redact:        cfg.DBLogRedact,
+		SlowThreshold: cfg.DBSlowThreshold,
 	})
+
+	db, err := database.Open(cfg.DB.Database())
 	if err != nil {
 		return fmt.Errorf("connecting to db: %w", err)
 	}
 	defer func() {
-		log.Infow("shutdown", "status", "stopping database support", "host", cfg.DBHost)
+		log.Infow("shutdown", "status", "stopping database support", "host", cfg.DB.Host)
 		db.Close()
 	}()
 
+	// Migrate the database when asked to, the replicas starting together
+	// take turns on a lock. Otherwise refuse to start against a schema whose
+	// applied migrations were edited or removed. An unreachable database is
+	// not fatal then, readiness reports it.
+	if cfg.MigrateOnStart {
+		if cfg.DB.MaxOpenConns == 1 {
+			return errors.New("migrating database: the lock needs a connection of its own, DBMAXOPENCONNS must not be 1")
+		}
+
+		log.Infow("start", "status", "migrating database")
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.MigrateTimeout)
+		err := schema.MigrateLocked(ctx, log, db)
+		cancel()
+
+		if err != nil {
+			return fmt.Errorf("migrating database: %w", err)
+		}
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := schema.Validate(ctx, log, db)
+		cancel()
+
+		var drift schema.DriftError
+		switch {
+		case errors.As(err, &drift):
+			return fmt.Errorf("validating schema: %w", err)
+		case err != nil:
+			log.Warnw("start", "status", "validating schema", "ERROR", err)
+		}
+	}
+
+	checks := []health.Check{
+		{Name: "database", Checker: func(ctx context.Context) error { return database.StatusCheck(ctx, db) }},
+		{Name: "migrations", Checker: func(ctx context.Context) error { return schema.CheckVersion(ctx, db) }},
+	}
+	for _, c := range checks {
+		if err := hr.Register(c); err != nil {
+			return fmt.Errorf("registering health check: %w", err)
+		}
+	}
+
+	//__________________________________________________________________________
+	// App start
+	log.Infow("start", "version", build)
+
+	//__________________________________________________________________________
+	// Start Tracing Support
+
+	log.Infow("start", "status", "initializing OT/tracing support", "exporter", cfg.TraceExporter)
+
+	traceProvider, err := startTracing(
+		cfg.TraceService,
+		build,
+		cfg.TraceExporter,
+		cfg.TraceEndpoint,
+		cfg.TraceInsecure,
+		cfg.TraceProbability,
+	)
+	if err != nil {
+		return fmt.Errorf("starting tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+
+		log.Infow("shutdown", "status", "stopping tracing support")
+		if err := traceProvider.Shutdown(ctx); err != nil {
+			log.Errorw("shutdown", "status", "stopping tracing support", "ERROR", err)
+		}
+	}()
+
+	//__________________________________________________________________________
+	// Initialize authentication support
+
+	log.Infow("start", "status", "initializing authentication support")
+
+	// Construct a key store based on the key files stored in the specified
+	// directory. The directory is rescanned so keys can be rotated by
+	// updating the mounted secret without restarting the service. The active
+	// key is switched the same way, through the active kid file of the
+	// secret, so every replica signs with the same key.
+	var a *auth.Auth
+	onKeyChange := func(e keystore.Event) error {
+		metrics.AddKeyStoreEvent(e.Kind)
+		if e.Err != nil {
+			log.Errorw("keystore", "status", e.Kind, "ERROR", e.Err)
+			return nil
+		}
+		log.Infow("keystore", "status", e.Kind, "kid", e.KID)
+
+		// A failed switch is reported by the rescan as an error event and
+		// retried on the next one.
+		if e.Kind == keystore.EventActive {
+			previous := a.ActiveKID()
+			if err := a.SetActiveKID(e.KID); err != nil {
+				return err
+			}
+			log.Infow("keystore", "status", "active kid changed", "previous", previous, "kid", e.KID)
+		}
+
+		return nil
+	}
+
+	ks, err := keystore.NewWatcher(os.DirFS(cfg.Keys.Folder), cfg.AuthKeysReload, cfg.AuthKeysGrace, onKeyChange)
+	if err != nil {
+		return fmt.Errorf("reading keys: %w", err)
+	}
+
+	// Revoked access tokens are tracked in the database.
+	revoked := token.NewStore(log, db)
+
+	// API keys are resolved to the claims of their owner.
+	apiKeys := apikey.NewCore(log, db)
+
+	// The active kid file of the keys folder takes precedence over the
+	// configuration.
+	activeKID := cfg.Keys.ActiveKID
+	if kid := ks.ActiveKID(); kid != "" {
+		activeKID = kid
+	}
+
+	// The discovery clients compare the issuer of the tokens with the address
+	// they found the keys at, both are external URLs.
+	for _, u := range []string{cfg.Keys.Issuer, cfg.APIBaseURL} {
+		if pu, err := url.Parse(u); err != nil || pu.Scheme == "" || pu.Host == "" {
+			return fmt.Errorf("%q must be an absolute URL", u)
+		}
+	}
+
+	a, err = auth.New(cfg.Keys.Issuer, activeKID, ks, revoked, apiKeys)
+	if err != nil {
+		return fmt.Errorf("constructing auth: %w", err)
+	}
+
+	// The TOTP secrets are encrypted in the database with a key of their
+	// own, a leaked dump doesn't give away the second factor.
+	if cfg.AuthMFAKey == "" {
+		return errors.New("AUTHMFAKEY must be set to a base64 encoded 32 bytes key")
+	}
+	mfaSecrets, err := auth.NewSecretBox(cfg.AuthMFAKey)
+	if err != nil {
+		return fmt.Errorf("constructing mfa secret box: %w", err)
+	}
+
+	ksCtx, ksCancel := context.WithCancel(context.Background())
+	defer ksCancel()
+
+	if cfg.AuthKeysReload > 0 {
+		go ks.Run(ksCtx)
+	}
+
+	// Keys are rotated at runtime, tokens can't be signed once the active one
+	// is gone.
+	keysCheck := func(ctx context.Context) error {
+		if len(ks.JWKS().Keys) == 0 {
+			return errors.New("no signing keys")
+		}
+		if _, err := ks.PrivateKey(a.ActiveKID()); err != nil {
+			return fmt.Errorf("active key %q: %w", a.ActiveKID(), err)
+		}
+		return nil
+	}
+	if err := hr.Register(health.Check{Name: "keystore", Checker: keysCheck}); err != nil {
+		return fmt.Errorf("registering health check: %w", err)
+	}
+
 	//__________________________________________________________________________
 	// Start Debug Service
 
@@ -105,7 +339,7 @@ func run(log *zap.SugaredLogger) error {
 	// related endpoints. This includes the standard library endpoints.
 
 	// Construct the mux for the debug calls.
-	debugMux := handlers.DebugMux(build, log, db)
+	debugMux := handlers.DebugMux(build, log, db, a, hr)
 
 	// Start the service listening for debug requests.
 	go func() {
@@ -115,37 +349,77 @@ func run(log *zap.SugaredLogger) error {
 	}()
 
 	//__________________________________________________________________________
-	// App start
-	log.Infow("start", "version", build)
+	// Mailer
+
+	log.Infow("start", "status", "initializing mailer", "mailer", cfg.Mailer)
+
+	// There is no default mailer, the log mailer writes the reset and
+	// verification tokens to the logs and must be asked for explicitly.
+	var mail mailer.Mailer
+	switch cfg.Mailer {
+	case "":
+		return errors.New("MAILER must be set to smtp, file or log")
+	case "log":
+		log.Warnw("start", "status", "mails are written to the log, for local development only")
+		mail = mailer.NewLog(log)
+	case "file":
+		if mail, err = mailer.NewFile(cfg.MailDir, cfg.MailFrom); err != nil {
+			return fmt.Errorf("constructing file mailer: %w", err)
+		}
+	case "smtp":
+		mail = mailer.NewSMTP(mailer.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			User:     cfg.SMTPUser,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+			Timeout:  cfg.SMTPTimeout,
+		})
+	default:
+		return fmt.Errorf("unknown mailer %q", cfg.Mailer)
+	}
 
 	//__________________________________________________________________________
-	// Initialize authentication support
-
-	log.Infow("start", "status", "initializing authentication support")
-
-	// Construct a key store based on the key files stored in
-	// the specified directory.
-	ks, err := keystore.NewFS(os.DirFS(cfg.AuthKeysFolder))
+	// The token endpoint is throttled by client address. Behind an ingress
+	// or a load balancer, the address is read from the header the trusted
+	// proxies append it to. The limit applies to each replica.
+	proxies, err := middleware.ParseNetworks(cfg.AuthProxies)
 	if err != nil {
-		return fmt.Errorf("reading keys: %w", err)
+		return fmt.Errorf("parsing trusted proxies: %w", err)
 	}
 
-	auth, err := auth.New(cfg.AuthActiveKID, ks)
-	if err != nil {
-		return fmt.Errorf("constructing auth: %w", err)
+	clientIP := middleware.RemoteAddr
+	if len(proxies) > 0 {
+		clientIP = middleware.ForwardedFor(cfg.AuthClientHeader, proxies)
 	}
 
-	//__________________________________________________________________________
 	// Start service
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
 
 	// Construct the mux for the API calls.
 	apiMux := handlers.APIMux(handlers.APIMuxConfig{
-		Shutdown: shutdown,
-		Log:      log,
-		Auth:     auth,
-		DB:       db,
+		Shutdown:   shutdown,
+		Log:        log,
+		Auth:       a,
+		DB:         db,
+		Keys:       ks,
+		BaseURL:    cfg.APIBaseURL,
+		AccessTTL:  cfg.AuthAccessTTL,
+		RefreshTTL: cfg.AuthRefreshTTL,
+		Lockout: userStorage.Lockout{
+			MaxFailures: cfg.AuthMaxFailures,
+			Duration:    cfg.AuthLockout,
+		},
+		TokenRateLimit:  cfg.AuthTokenRate,
+		TokenRatePer:    cfg.AuthTokenPer,
+		ClientIP:        clientIP,
+		Mailer:          mail,
+		ResetTTL:        cfg.AuthResetTTL,
+		VerifyTTL:       cfg.AuthVerifyTTL,
+		MFAIssuer:       cfg.AuthMFAIssuer,
+		MFAChallengeTTL: cfg.AuthMFATTL,
+		MFASecrets:      mfaSecrets,
 	})
 
 	api := http.Server{
@@ -183,6 +457,82 @@ func run(log *zap.SugaredLogger) error {
 	return nil
 }
 
+// startTracing configures open telemetry to be used with the service. Every
+// request gets a trace id, the sampled spans are only sent somewhere when an
+// exporter is configured.
+// Exporters: none, stdout, otlphttp, otlpgrpc.
+func startTracing(serviceName string, version string, exporter string, endpoint string, insecure bool, probability float64) (*sdktrace.TracerProvider, error) {
+	ctx := context.Background()
+
+	var spanExporter sdktrace.SpanExporter
+	switch exporter {
+	case "none", "":
+	case "stdout":
+		exp, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, fmt.Errorf("creating stdout exporter: %w", err)
+		}
+		spanExporter = exp
+	case "otlphttp":
+		var opts []otlptracehttp.Option
+		if endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(endpoint))
+		}
+		if insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exp, err := otlptrace.New(ctx, otlptracehttp.NewClient(opts...))
+		if err != nil {
+			return nil, fmt.Errorf("creating otlp http exporter: %w", err)
+		}
+		spanExporter = exp
+	case "otlpgrpc":
+		var opts []otlptracegrpc.Option
+		if endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(endpoint))
+		}
+		if insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exp, err := otlptrace.New(ctx, otlptracegrpc.NewClient(opts...))
+		if err != nil {
+			return nil, fmt.Errorf("creating otlp grpc exporter: %w", err)
+		}
+		spanExporter = exp
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+
+	res := resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceNameKey.String(serviceName),
+		semconv.ServiceVersionKey.String(version),
+		attribute.String("exporter", exporter),
+	)
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(probability))),
+		sdktrace.WithResource(res),
+	}
+	if spanExporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(spanExporter))
+	}
+
+	traceProvider := sdktrace.NewTracerProvider(opts...)
+
+	// The provider must be global, otelhttp and the database package pick
+	// their tracer from it.
+	otel.SetTracerProvider(traceProvider)
+
+	// Accept and forward the trace context of the callers.
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return traceProvider, nil
+}
+
 func initLogger(service string) (*zap.SugaredLogger, error) {
 	config := zap.NewProductionConfig()
 	config.OutputPaths = []string{"stdout"}
@@ -5,10 +5,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/dimashiro/service/app/services/retail-api/handlers"
 	"github.com/dimashiro/service/business/data/tests"
+	"github.com/dimashiro/service/business/mailer"
+	"github.com/dimashiro/service/business/validate"
 )
 
 type UserTests struct {
@@ -28,31 +31,48 @@ func TestUsers(t *testing.T) {
 			Log:      test.Log,
 			Auth:     test.Auth,
 			DB:       test.DB,
+			Keys:     test.Keys,
+			Mailer:   mailer.NewLog(test.Log),
 		}),
 		userToken:  test.Token("user@example.com", "gophers"),
 		adminToken: test.Token("admin@example.com", "gophers"),
 	}
 
-	t.Run("getToken404", tests.getToken404)
+	t.Run("getToken401", tests.getToken401)
 	t.Run("getToken200", tests.getToken200)
+	t.Run("forgotPassword202", tests.forgotPassword202)
+	t.Run("resetPassword400", tests.resetPassword400)
 }
 
-func (ut *UserTests) getToken404(t *testing.T) {
+func (ut *UserTests) getToken401(t *testing.T) {
 	r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
 	w := httptest.NewRecorder()
 
 	r.SetBasicAuth("unknown@example.com", "some-password")
 	ut.app.ServeHTTP(w, r)
 
-	t.Log("Given the need to deny tokens to unknown users.")
+	t.Log("Given the need to deny tokens to unknown users without revealing they don't exist.")
 	{
 		testID := 0
 		t.Logf("\tTest %d:\tWhen fetching a token with an unrecognized email.", testID)
 		{
-			if w.Code != http.StatusNotFound {
-				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 404 for the response : %v", tests.Failed, testID, w.Code)
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 for the response : %v", tests.Failed, testID, w.Code)
 			}
-			t.Logf("\t%s\tTest %d:\tShould receive a status code of 404 for the response.", tests.Success, testID)
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 for the response.", tests.Success, testID)
+
+			if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a problem details document : %s", tests.Failed, testID, ct)
+			}
+
+			var got validate.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+			if got.Status != http.StatusUnauthorized || got.Code != "authentication_failed" || got.TraceID == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a problem details document : %+v", tests.Failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a problem details document.", tests.Success, testID)
 		}
 	}
 }
@@ -86,3 +106,52 @@ func (ut *UserTests) getToken200(t *testing.T) {
 		}
 	}
 }
+
+func (ut *UserTests) forgotPassword202(t *testing.T) {
+	body := strings.NewReader(`{"email":"unknown@example.com"}`)
+	r := httptest.NewRequest(http.MethodPost, "/v1/users/password/forgot", body)
+	w := httptest.NewRecorder()
+
+	ut.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to accept password resets without revealing which users exist.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen asking a password reset for an unknown email.", testID)
+		{
+			if w.Code != http.StatusAccepted {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 202 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 202 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+func (ut *UserTests) resetPassword400(t *testing.T) {
+	body := strings.NewReader(`{"token":"unknown","password":"gophers","password_confirm":"gophers"}`)
+	r := httptest.NewRequest(http.MethodPost, "/v1/users/password/reset", body)
+	w := httptest.NewRecorder()
+
+	ut.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to only reset passwords with a valid token.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen resetting a password with an unknown token.", testID)
+		{
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 for the response.", tests.Success, testID)
+
+			var got validate.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+			if got.Code != "invalid_token" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive the invalid_token code : %q", tests.Failed, testID, got.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive the invalid_token code.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Package commands contains the functionality for the set of commands
+// currently supported by the admin tool.
+package commands
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/database"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Set of error variables for the commands. A command fails with an error
+// wrapping ErrUsage when it's called with invalid arguments.
+var (
+	ErrUsage   = errors.New("usage error")
+	ErrUnknown = fmt.Errorf("%w: unknown command", ErrUsage)
+)
+
+// Actor is the subject recorded in the audit log for the changes made by
+// the tool.
+const Actor = "cli:admin"
+
+// Env is the configuration and the IO the commands run with.
+type Env struct {
+	Log        *zap.SugaredLogger
+	Stdout     io.Writer
+	Stderr     io.Writer
+	Stdin      io.Reader
+	DB         database.Config
+	KeysFolder string
+	ActiveKID  string
+	Issuer     string
+	Timeout    time.Duration
+}
+
+// Command is a command of the tool.
+type Command struct {
+	Name string
+	Help string
+	run  func(env Env, args []string) error
+}
+
+// List returns the commands of the tool in the order they are documented.
+func List() []Command {
+	return []Command{
+		{"migrate", "apply the pending migrations: migrate [-dry-run], migrate status, migrate down", Migrate},
+		{"seed", "add a seed set to the database: dev, demo or loadtest", Seed},
+		{"genkey", "generate a signing key in the keys folder", GenKey},
+		{"gentoken", "generate an access token signed with a key of the keys folder", GenToken},
+		{"useradd", "add a user", UserAdd},
+		{"userpasswd", "change the password of a user", UserPasswd},
+		{"keys", "manage the signing keys: keys list", Keys},
+	}
+}
+
+// Run runs the named command with its arguments.
+func Run(env Env, name string, args []string) error {
+	for _, c := range List() {
+		if c.Name == name {
+			return c.run(env, args)
+		}
+	}
+
+	return fmt.Errorf("%w %q", ErrUnknown, name)
+}
+
+// =============================================================================
+
+// newFlagSet constructs the flag set of a command, printing its usage and
+// errors on stderr.
+func newFlagSet(env Env, name string, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(env.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: admin %s\n", synopsis)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// parse parses the arguments of a command. Errors other than a request for
+// help are usage errors.
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return fmt.Errorf("%w: %s: %v", ErrUsage, fs.Name(), err)
+	}
+
+	if fs.NArg() > 0 {
+		return fmt.Errorf("%w: %s: unexpected arguments %v", ErrUsage, fs.Name(), fs.Args())
+	}
+
+	return nil
+}
+
+// required returns a usage error when the value of the flag is missing.
+func required(fs *flag.FlagSet, name string, value string) error {
+	if value != "" {
+		return nil
+	}
+
+	fs.Usage()
+	return fmt.Errorf("%w: %s: the -%s flag is required", ErrUsage, fs.Name(), name)
+}
+
+// openDB connects to the database and returns a context bound to the timeout
+// of the command.
+func openDB(env Env) (*sqlx.DB, context.Context, context.CancelFunc, error) {
+	db, err := database.Open(env.DB)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("connect database: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), env.Timeout)
+	if err := database.StatusCheck(ctx, db); err != nil {
+		cancel()
+		db.Close()
+		return nil, nil, nil, fmt.Errorf("status check database: %w", err)
+	}
+
+	return db, ctx, cancel, nil
+}
+
+// adminClaims are the claims the tool acts with.
+func adminClaims() auth.Claims {
+	return auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Subject: Actor,
+		},
+		Roles:       []string{auth.RoleAdmin},
+		Permissions: []string{auth.PermUsersRead, auth.PermUsersWrite},
+	}
+}
+
+// readPassword returns the password of the flag or, when it's empty, the
+// first line of stdin so it doesn't show in the shell history.
+func readPassword(env Env, password string) (string, error) {
+	if password != "" {
+		return password, nil
+	}
+
+	fmt.Fprint(env.Stderr, "Password: ")
+	line, err := bufio.NewReader(env.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("reading password: %w", err)
+	}
+
+	password = strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("%w: empty password", ErrUsage)
+	}
+
+	return password, nil
+}
+
+// splitList splits a comma separated list, ignoring empty items.
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
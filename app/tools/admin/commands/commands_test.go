@@ -0,0 +1,118 @@
+package commands_test
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dimashiro/service/app/tools/admin/commands"
+	"github.com/dimashiro/service/foundation/keystore"
+	"go.uber.org/zap"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// newEnv returns an environment writing keys to a temporary folder.
+func newEnv(t *testing.T) commands.Env {
+	return commands.Env{
+		Log:        zap.NewNop().Sugar(),
+		Stdout:     new(bytes.Buffer),
+		Stderr:     new(bytes.Buffer),
+		Stdin:      new(bytes.Buffer),
+		KeysFolder: t.TempDir(),
+	}
+}
+
+func TestRun(t *testing.T) {
+	tt := []struct {
+		name string
+		cmd  string
+		args []string
+		err  error
+	}{
+		{"an unknown command", "bogus", nil, commands.ErrUnknown},
+		{"an unknown flag", "genkey", []string{"-bogus"}, commands.ErrUsage},
+		{"unexpected arguments", "genkey", []string{"extra"}, commands.ErrUsage},
+		{"a missing required flag", "useradd", []string{"-email", "user@example.com"}, commands.ErrUsage},
+		{"the help flag", "gentoken", []string{"-h"}, flag.ErrHelp},
+		{"valid arguments", "genkey", []string{"-kid", "kid"}, nil},
+	}
+
+	t.Log("Given the need to dispatch the commands of the tool.")
+	{
+		for testID, tc := range tt {
+			t.Logf("\tTest %d:\tWhen running %s with %s.", testID, tc.cmd, tc.name)
+			{
+				err := commands.Run(newEnv(t), tc.cmd, tc.args)
+
+				switch {
+				case tc.err == nil && err != nil:
+					t.Fatalf("\t%s\tTest %d:\tShould run the command : %s.", failed, testID, err)
+				case tc.err != nil && !errors.Is(err, tc.err):
+					t.Fatalf("\t%s\tTest %d:\tShould fail with %q : got %v.", failed, testID, tc.err, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould return %v.", success, testID, tc.err)
+			}
+		}
+	}
+}
+
+func TestGenKey(t *testing.T) {
+	tt := []struct {
+		name string
+		args []string
+		alg  string
+	}{
+		{"rsa", []string{"-alg", "rsa"}, keystore.AlgRS256},
+		{"ecdsa", []string{"-alg", "ecdsa"}, keystore.AlgES256},
+		{"ecdsa P-384", []string{"-alg", "ecdsa", "-curve", "P-384"}, keystore.AlgES384},
+		{"ed25519", []string{"-alg", "ed25519"}, keystore.AlgEdDSA},
+	}
+
+	t.Log("Given the need to generate signing keys of every supported algorithm.")
+	{
+		for testID, tc := range tt {
+			t.Logf("\tTest %d:\tWhen generating a %s key.", testID, tc.name)
+			{
+				env := newEnv(t)
+
+				if err := commands.Run(env, "genkey", append([]string{"-kid", "kid"}, tc.args...)); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to generate the key : %s.", failed, testID, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be able to generate the key.", success, testID)
+
+				data, err := os.ReadFile(filepath.Join(env.KeysFolder, "kid.pem"))
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould write the key file : %s.", failed, testID, err)
+				}
+
+				key, err := keystore.ParsePrivateKeyFromPEM(data)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould write a key the keystore can load : %s.", failed, testID, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould write a key the keystore can load.", success, testID)
+
+				if alg, err := keystore.Algorithm(key.Public()); err != nil || alg != tc.alg {
+					t.Fatalf("\t%s\tTest %d:\tShould sign with %s : got %s, %v.", failed, testID, tc.alg, alg, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould sign with %s.", success, testID, tc.alg)
+			}
+		}
+
+		testID := len(tt)
+		t.Logf("\tTest %d:\tWhen asking for an unsupported algorithm.", testID)
+		{
+			if err := commands.Run(newEnv(t), "genkey", []string{"-alg", "dsa"}); !errors.Is(err, commands.ErrUsage) {
+				t.Fatalf("\t%s\tTest %d:\tShould report a usage error : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report a usage error.", success, testID)
+		}
+	}
+}
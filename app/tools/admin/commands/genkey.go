@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+// curves are the ECDSA curves the tokens can be signed with.
+var curves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+// GenKey generates a private key in the keys folder. The name of the file is
+// the key id, the retail-api picks the key up when it rescans the folder.
+func GenKey(env Env, args []string) error {
+	fs := newFlagSet(env, "genkey", "genkey [-kid <kid>] [-alg rsa|ecdsa|ed25519] [-bits <bits>] [-curve <curve>]")
+	kid := fs.String("kid", uuid.NewString(), "key id of the new key, a random uuid by default")
+	alg := fs.String("alg", "rsa", "algorithm of the key: rsa, ecdsa or ed25519")
+	bits := fs.Int("bits", 2048, "size of the RSA key")
+	curve := fs.String("curve", "P-256", "curve of the ECDSA key: P-256, P-384 or P-521")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	if *kid == "" || filepath.Base(*kid) != *kid {
+		return fmt.Errorf("%w: genkey: invalid key id %q", ErrUsage, *kid)
+	}
+
+	block, err := generateKey(*alg, *bits, *curve)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(env.KeysFolder, 0o700); err != nil {
+		return fmt.Errorf("creating keys folder: %w", err)
+	}
+
+	// Never overwrite a key, tokens signed with it would no longer validate.
+	path := filepath.Join(env.KeysFolder, *kid+".pem")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("key %q already exists", *kid)
+		}
+		return fmt.Errorf("creating private file: %w", err)
+	}
+	defer file.Close()
+
+	if err := pem.Encode(file, block); err != nil {
+		return fmt.Errorf("encoding to private file: %w", err)
+	}
+
+	fmt.Fprintf(env.Stdout, "key %s generated in %s\n", *kid, path)
+	return nil
+}
+
+// generateKey generates a private key for the algorithm and returns it in the
+// PEM block the keystore expects for it.
+func generateKey(alg string, bits int, curve string) (*pem.Block, error) {
+	switch alg {
+	case "rsa":
+		privateKey, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, fmt.Errorf("generating key: %w", err)
+		}
+		return &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)}, nil
+
+	case "ecdsa":
+		c, ok := curves[curve]
+		if !ok {
+			return nil, fmt.Errorf("%w: genkey: unsupported curve %q", ErrUsage, curve)
+		}
+		privateKey, err := ecdsa.GenerateKey(c, rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generating key: %w", err)
+		}
+		der, err := x509.MarshalECPrivateKey(privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("encoding key: %w", err)
+		}
+		return &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}, nil
+
+	case "ed25519":
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generating key: %w", err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("encoding key: %w", err)
+		}
+		return &pem.Block{Type: "PRIVATE KEY", Bytes: der}, nil
+	}
+
+	return nil, fmt.Errorf("%w: genkey: unsupported algorithm %q", ErrUsage, alg)
+}
@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/data/store/role"
+	"github.com/dimashiro/service/foundation/keystore"
+)
+
+// GenToken generates an access token for the subject. The permissions of the
+// roles are resolved from the database, as they are for the tokens issued by
+// the retail-api.
+func GenToken(env Env, args []string) error {
+	fs := newFlagSet(env, "gentoken", "gentoken -sub <subject> [-kid <kid>] [-roles <roles>] [-ttl <ttl>]")
+	kid := fs.String("kid", env.ActiveKID, "key id of the signing key")
+	sub := fs.String("sub", "", "subject of the token, a user id")
+	roles := fs.String("roles", auth.RoleAdmin, "comma separated roles of the subject")
+	ttl := fs.Duration("ttl", time.Hour, "time to live of the token")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := required(fs, "sub", *sub); err != nil {
+		return err
+	}
+	if *ttl <= 0 {
+		return fmt.Errorf("%w: gentoken: the ttl must be positive", ErrUsage)
+	}
+
+	ks, err := keystore.NewFS(os.DirFS(env.KeysFolder))
+	if err != nil {
+		return fmt.Errorf("reading keys: %w", err)
+	}
+
+	a, err := auth.New(env.Issuer, *kid, ks, nil, nil)
+	if err != nil {
+		return fmt.Errorf("constructing auth: %w", err)
+	}
+
+	db, ctx, cancel, err := openDB(env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	defer cancel()
+
+	claims := auth.NewClaims(*sub, splitList(*roles), time.Now(), *ttl)
+
+	claims.Permissions, err = role.NewStore(env.Log, db).Permissions(ctx, claims.Roles)
+	if err != nil {
+		return fmt.Errorf("resolving permissions: %w", err)
+	}
+
+	token, err := a.GenerateToken(claims)
+	if err != nil {
+		return fmt.Errorf("generating token: %w", err)
+	}
+
+	fmt.Fprintln(env.Stdout, token)
+	return nil
+}
@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dimashiro/service/foundation/keystore"
+)
+
+// Keys manages the signing keys of the keys folder.
+func Keys(env Env, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: keys: expected a subcommand: list", ErrUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		return keysList(env, args[1:])
+	}
+
+	return fmt.Errorf("%w: keys: unknown subcommand %q", ErrUsage, args[0])
+}
+
+// keysList prints the key id and algorithm of every key in the keys folder,
+// marking the active one.
+func keysList(env Env, args []string) error {
+	fs := newFlagSet(env, "keys list", "keys list")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	ks, err := keystore.NewFS(os.DirFS(env.KeysFolder))
+	if err != nil {
+		return fmt.Errorf("reading keys: %w", err)
+	}
+
+	w := tabwriter.NewWriter(env.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KID\tALG\tACTIVE")
+	for _, jwk := range ks.JWKS().Keys {
+		active := ""
+		if jwk.KeyID == env.ActiveKID {
+			active = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", jwk.KeyID, jwk.Algorithm, active)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,212 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dimashiro/service/business/data/schema"
+)
+
+// Migrate applies the pending migrations to the database. With -dry-run it
+// prints their SQL instead. The status and down subcommands show the state
+// of the migrations and revert them.
+func Migrate(env Env, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "status":
+			return migrateStatus(env, args[1:])
+		case "down":
+			return migrateDown(env, args[1:])
+		}
+	}
+
+	fs := newFlagSet(env, "migrate", "migrate [-dry-run] | migrate status | migrate down -to <version> [-dry-run]")
+	dryRun := fs.Bool("dry-run", false, "print the SQL of the pending migrations without applying them")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	db, ctx, cancel, err := openDB(env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	defer cancel()
+
+	if *dryRun {
+		if err := schema.Validate(ctx, env.Log, db); err != nil {
+			return err
+		}
+
+		pending, err := schema.Pending(ctx, db)
+		if err != nil {
+			return fmt.Errorf("planning migrations: %w", err)
+		}
+
+		printScripts(env, pending, false)
+		fmt.Fprintf(env.Stdout, "-- %d pending migrations\n", len(pending))
+		return nil
+	}
+
+	if err := schema.Migrate(ctx, env.Log, db); err != nil {
+		return fmt.Errorf("migrate database: %w", err)
+	}
+
+	fmt.Fprintln(env.Stdout, "migrations complete")
+	return nil
+}
+
+// migrateStatus prints the state of every migration. It fails when the
+// applied migrations drifted from the ones of the schema.
+func migrateStatus(env Env, args []string) error {
+	fs := newFlagSet(env, "migrate status", "migrate status")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	db, ctx, cancel, err := openDB(env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	defer cancel()
+
+	status, err := schema.Status(ctx, db)
+	if err != nil {
+		return fmt.Errorf("migration status: %w", err)
+	}
+
+	w := tabwriter.NewWriter(env.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tSTATUS\tCHECKSUM\tAPPLIED AT\tDOWN\tDESCRIPTION")
+	for _, s := range status {
+		state, appliedAt := "pending", ""
+		switch {
+		case s.Drift:
+			state, appliedAt = "DRIFT", s.AppliedAt.UTC().Format(time.RFC3339)
+		case s.Applied:
+			state, appliedAt = "applied", s.AppliedAt.UTC().Format(time.RFC3339)
+		}
+
+		down := "no"
+		if s.Down != "" {
+			down = "yes"
+		}
+
+		fmt.Fprintf(w, "%v\t%s\t%s\t%s\t%s\t%s\n", s.Version, state, s.Checksum, appliedAt, down, s.Description)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	return schema.Validate(ctx, env.Log, db)
+}
+
+// migrateDown reverts the applied migrations newer than the version. With
+// -dry-run it prints their down SQL instead.
+func migrateDown(env Env, args []string) error {
+	fs := newFlagSet(env, "migrate down", "migrate down -to <version> [-dry-run]")
+	to := fs.String("to", "", "version to bring the schema back to, 0 reverts every migration")
+	dryRun := fs.Bool("dry-run", false, "print the SQL of the down migrations without running them")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := required(fs, "to", *to); err != nil {
+		return err
+	}
+
+	version, err := strconv.ParseFloat(*to, 64)
+	if err != nil {
+		return fmt.Errorf("%w: migrate down: invalid version %q", ErrUsage, *to)
+	}
+
+	db, ctx, cancel, err := openDB(env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	defer cancel()
+
+	if *dryRun {
+		if err := schema.Validate(ctx, env.Log, db); err != nil {
+			return err
+		}
+
+		plan, err := schema.RollbackPlan(ctx, db, version)
+		if err != nil {
+			return fmt.Errorf("planning rollback: %w", err)
+		}
+
+		printScripts(env, plan, true)
+		fmt.Fprintf(env.Stdout, "-- %d migrations to revert\n", len(plan))
+		return nil
+	}
+
+	reverted, err := schema.Rollback(ctx, env.Log, db, version)
+	for _, mig := range reverted {
+		fmt.Fprintf(env.Stdout, "reverted migration %v: %s\n", mig.Version, mig.Description)
+	}
+	if err != nil {
+		return fmt.Errorf("rollback database: %w", err)
+	}
+
+	fmt.Fprintln(env.Stdout, "rollback complete")
+	return nil
+}
+
+// printScripts prints the SQL of the migrations, or of their down migrations.
+func printScripts(env Env, migs []schema.Migration, down bool) {
+	for _, mig := range migs {
+		header, script := "Version", mig.Script
+		if down {
+			header, script = "Revert version", mig.Down
+		}
+
+		fmt.Fprintf(env.Stdout, "-- %s: %v\n-- Description: %s\n%s\n\n", header, mig.Version, mig.Description, strings.TrimSpace(script))
+	}
+}
+
+// Seed adds a seed set to the database. The size of the load test set can
+// be changed with flags.
+func Seed(env Env, args []string) error {
+	fs := newFlagSet(env, "seed", "seed [-set <set>] [-users <n> -products <n> -sales <n>]")
+	set := fs.String("set", schema.SeedDev, fmt.Sprintf("seed set, one of %v", schema.SeedSets()))
+	users := fs.Int("users", schema.DefaultLoadTest.Users, "number of users of the loadtest set")
+	products := fs.Int("products", schema.DefaultLoadTest.Products, "number of products of the loadtest set")
+	sales := fs.Int("sales", schema.DefaultLoadTest.Sales, "number of sales of the loadtest set")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	db, ctx, cancel, err := openDB(env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	defer cancel()
+
+	switch *set {
+	case schema.SeedLoadTest:
+		lt := schema.LoadTest{
+			Users:    *users,
+			Products: *products,
+			Sales:    *sales,
+		}
+		err = schema.GenerateLoadTest(ctx, env.Log, db, lt)
+	default:
+		err = schema.Seed(ctx, env.Log, db, *set)
+	}
+
+	if err != nil {
+		if errors.Is(err, schema.ErrUnknownSeed) {
+			return fmt.Errorf("%w: seed: %v", ErrUsage, err)
+		}
+		return fmt.Errorf("seed database: %w", err)
+	}
+
+	fmt.Fprintf(env.Stdout, "seed data %s complete\n", *set)
+	return nil
+}
@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/core/user"
+	userStorage "github.com/dimashiro/service/business/data/store/user"
+	"github.com/dimashiro/service/business/database"
+	"github.com/dimashiro/service/business/mailer"
+)
+
+// UserAdd adds a user. The password is read from stdin when the flag is not
+// provided.
+func UserAdd(env Env, args []string) error {
+	fs := newFlagSet(env, "useradd", "useradd -name <name> -email <email> [-roles <roles>] [-password <password>]")
+	name := fs.String("name", "", "name of the user")
+	email := fs.String("email", "", "email of the user")
+	roles := fs.String("roles", auth.RoleUser, "comma separated roles of the user")
+	password := fs.String("password", "", "password of the user, read from stdin when empty")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := required(fs, "name", *name); err != nil {
+		return err
+	}
+	if err := required(fs, "email", *email); err != nil {
+		return err
+	}
+
+	pass, err := readPassword(env, *password)
+	if err != nil {
+		return err
+	}
+
+	db, ctx, cancel, err := openDB(env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	defer cancel()
+
+	core := user.NewCore(env.Log, db, mailer.NewLog(env.Log), nil)
+
+	nu := userStorage.NewUserDTO{
+		Name:            *name,
+		Email:           *email,
+		Roles:           splitList(*roles),
+		Password:        pass,
+		PasswordConfirm: pass,
+	}
+
+	usr, err := core.Create(ctx, adminClaims(), nu, time.Now())
+	if err != nil {
+		return fmt.Errorf("create user: %w", err)
+	}
+
+	fmt.Fprintf(env.Stdout, "user %s created with id %s\n", usr.Email, usr.ID)
+	return nil
+}
+
+// UserPasswd changes the password of the user with the email. The password
+// is read from stdin when the flag is not provided.
+func UserPasswd(env Env, args []string) error {
+	fs := newFlagSet(env, "userpasswd", "userpasswd -email <email> [-password <password>]")
+	email := fs.String("email", "", "email of the user")
+	password := fs.String("password", "", "new password of the user, read from stdin when empty")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := required(fs, "email", *email); err != nil {
+		return err
+	}
+
+	pass, err := readPassword(env, *password)
+	if err != nil {
+		return err
+	}
+
+	db, ctx, cancel, err := openDB(env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	defer cancel()
+
+	usr, err := userStorage.NewStore(env.Log, db).QueryByEmail(ctx, *email)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return fmt.Errorf("user %s not found", *email)
+		}
+		return fmt.Errorf("query user: %w", err)
+	}
+
+	core := user.NewCore(env.Log, db, mailer.NewLog(env.Log), nil)
+
+	uu := userStorage.UpdateUserDTO{
+		Password:        &pass,
+		PasswordConfirm: &pass,
+	}
+
+	if err := core.Update(ctx, adminClaims(), usr.ID, uu, time.Now()); err != nil {
+		return fmt.Errorf("update user: %w", err)
+	}
+
+	fmt.Fprintf(env.Stdout, "password of user %s changed\n", usr.Email)
+	return nil
+}
@@ -1,219 +1,149 @@
+// This program performs administrative tasks for the retail service.
 package main
 
 import (
-	"context"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"os"
 	"time"
 
-	"github.com/dimashiro/service/business/data/schema"
-	"github.com/dimashiro/service/business/database"
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/dimashiro/service/app/config"
+	"github.com/dimashiro/service/app/tools/admin/commands"
+	"github.com/ilyakaznacheev/cleanenv"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Exit codes of the tool.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
 )
 
 func main() {
-	err := migrate()
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-	}
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
 }
 
-func migrate() error {
-	cfg := database.Config{
-		User:         "postgres",
-		Password:     "postgres",
-		Host:         "localhost",
-		Name:         "postgres",
-		MaxIdleConns: 0,
-		MaxOpenConns: 0,
-		DisableTLS:   true,
-	}
-
-	db, err := database.Open(cfg)
-	if err != nil {
-		return fmt.Errorf("connect database: %w", err)
-	}
-	defer db.Close()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	if err := schema.Migrate(ctx, db); err != nil {
-		return fmt.Errorf("migrate database: %w", err)
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+
+	// The database and keys settings are shared with the retail-api, so the
+	// tool works against the same database and keys.
+	cfg := struct {
+		DB      config.DB
+		Keys    config.Keys
+		Timeout time.Duration `env:"ADMINTIMEOUT" env-default:"30s"`
+	}{}
+
+	if err := cleanenv.ReadEnv(&cfg); err != nil {
+		fmt.Fprintln(stderr, "loading conf:", err)
+		return exitFailure
+	}
+
+	// Flags override the environment.
+	fs := flag.NewFlagSet("admin", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&cfg.Keys.Folder, "keys-folder", cfg.Keys.Folder, "folder of the signing keys (AUTHKEYSFOLDER)")
+	fs.StringVar(&cfg.Keys.ActiveKID, "active-kid", cfg.Keys.ActiveKID, "key id used to sign tokens (AUTHACTIVEKID)")
+	fs.StringVar(&cfg.Keys.Issuer, "issuer", cfg.Keys.Issuer, "issuer of the tokens (AUTHISSUER)")
+	fs.StringVar(&cfg.DB.User, "db-user", cfg.DB.User, "database user (DBUSER)")
+	fs.Var(secret{&cfg.DB.Password}, "db-password", "database password (DBPASSWORD)")
+	fs.StringVar(&cfg.DB.Host, "db-host", cfg.DB.Host, "database host (DBHOST)")
+	fs.StringVar(&cfg.DB.Name, "db-name", cfg.DB.Name, "database name (DBNAME)")
+	fs.BoolVar(&cfg.DB.DisableTLS, "db-disable-tls", cfg.DB.DisableTLS, "disable TLS to the database (DBDISABLETLS)")
+	fs.DurationVar(&cfg.Timeout, "timeout", cfg.Timeout, "timeout of the command (ADMINTIMEOUT)")
+	fs.Usage = func() { usage(fs) }
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if fs.NArg() == 0 {
+		usage(fs)
+		return exitUsage
+	}
+
+	log := initLogger(stderr)
+	defer log.Sync()
+
+	env := commands.Env{
+		Log:        log,
+		Stdout:     stdout,
+		Stderr:     stderr,
+		Stdin:      os.Stdin,
+		DB:         cfg.DB.Database(),
+		KeysFolder: cfg.Keys.Folder,
+		ActiveKID:  cfg.Keys.ActiveKID,
+		Issuer:     cfg.Keys.Issuer,
+		Timeout:    cfg.Timeout,
+	}
+
+	err := commands.Run(env, fs.Arg(0), fs.Args()[1:])
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.Is(err, commands.ErrUnknown):
+		fmt.Fprintln(stderr, err)
+		usage(fs)
+		return exitUsage
+	case errors.Is(err, commands.ErrUsage):
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	default:
+		fmt.Fprintln(stderr, err)
+		return exitFailure
 	}
-
-	fmt.Println("migrations complete")
-
-	return seed()
 }
 
-func seed() error {
-	cfg := database.Config{
-		User:         "postgres",
-		Password:     "postgres",
-		Host:         "localhost",
-		Name:         "postgres",
-		MaxIdleConns: 0,
-		MaxOpenConns: 0,
-		DisableTLS:   true,
-	}
-
-	db, err := database.Open(cfg)
-	if err != nil {
-		return fmt.Errorf("connect database: %w", err)
-	}
-	defer db.Close()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	if err := schema.Seed(ctx, db); err != nil {
-		return fmt.Errorf("seed database: %w", err)
-	}
-
-	fmt.Println("seed data complete")
-	return nil
+// secret is a flag whose value, read from the environment, is never shown
+// in the usage.
+type secret struct {
+	value *string
 }
 
-func genToken() error {
-	// temporary hardcoded
-	file, err := os.Open("private.pem")
-	if err != nil {
-		return err
-	}
-
-	privatePEM, err := io.ReadAll(io.LimitReader(file, 1024*1024))
-	if err != nil {
-		return fmt.Errorf("reading auth private key: %w", err)
-	}
-
-	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
-	if err != nil {
-		return fmt.Errorf("parsing auth private key: %w", err)
-	}
-
-	//__________________________________________________________________________
-
-	claims := struct {
-		jwt.StandardClaims
-		Roles []string
-	}{
-		StandardClaims: jwt.StandardClaims{
-			Issuer:    "retail-api project",
-			Subject:   "000000000",
-			ExpiresAt: time.Now().Add(8760 * time.Hour).Unix(),
-			IssuedAt:  time.Now().UTC().Unix(),
-		},
-		Roles: []string{"ADMIN"},
-	}
-
-	method := jwt.GetSigningMethod("RS256")
-	token := jwt.NewWithClaims(method, claims)
-	token.Header["kid"] = "developmentkeyid"
-
-	tokenStr, err := token.SignedString(privateKey)
-	if err != nil {
-		return err
-	}
-
-	fmt.Println("token: ", tokenStr)
-
-	// Marshal the public key from the private key to PKIX.
-	asn1Bytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
-	if err != nil {
-		return fmt.Errorf("marshaling public key: %w", err)
-	}
-
-	publicBlock := pem.Block{
-		Type:  "RSA PUBLIC KEY",
-		Bytes: asn1Bytes,
-	}
-
-	if err := pem.Encode(os.Stdout, &publicBlock); err != nil {
-		return fmt.Errorf("encoding to public file: %w", err)
-	}
-
-	parser := jwt.Parser{
-		ValidMethods: []string{"RS256"},
-	}
-
-	var parsedClaims struct {
-		jwt.StandardClaims
-		Roles []string
-	}
-
-	keyFunc := func(t *jwt.Token) (interface{}, error) {
-		return &privateKey.PublicKey, nil
-	}
-
-	parsedToken, err := parser.ParseWithClaims(tokenStr, &parsedClaims, keyFunc)
-	if err != nil {
-		return fmt.Errorf("parsing token: %w", err)
-	}
-
-	if !parsedToken.Valid {
-		return errors.New("invalid token")
-	}
-
-	fmt.Println("token is ok")
+func (s secret) String() string { return "" }
 
+func (s secret) Set(v string) error {
+	*s.value = v
 	return nil
 }
 
-func genKey() error {
-	//___Gen private key________________________________________________________
-	prvKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return err
-	}
-
-	// Create a file for the private key information in PEM form.
-	prvFile, err := os.Create("private.pem")
-	if err != nil {
-		return fmt.Errorf("creating private file: %w", err)
-	}
-	defer prvFile.Close()
-
-	prvBlock := pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(prvKey),
-	}
-
-	if err := pem.Encode(prvFile, &prvBlock); err != nil {
-		return fmt.Errorf("encoding to private file: %w", err)
-	}
-
-	//___Gen publick key________________________________________________________
-	// Marshal the public key from the private key to PKIX.
-	asn1Bytes, err := x509.MarshalPKIXPublicKey(&prvKey.PublicKey)
-	if err != nil {
-		return fmt.Errorf("marshaling public key: %w", err)
-	}
+// usage prints the commands and the global flags.
+func usage(fs *flag.FlagSet) {
+	w := fs.Output()
+
+	fmt.Fprintln(w, "Usage: admin [flags] <command> [command flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands.List() {
+		fmt.Fprintf(w, "  %-12s %s\n", c.Name, c.Help)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Flags:")
+	fs.PrintDefaults()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'admin <command> -h' for the flags of a command.")
+	fmt.Fprintln(w, "Exit codes: 0 success, 1 failure, 2 usage error.")
+}
 
-	// Create a file for the public key information in PEM form.
-	publicFile, err := os.Create("public.pem")
-	if err != nil {
-		return fmt.Errorf("creating public file: %w", err)
-	}
-	defer publicFile.Close()
+// initLogger constructs a logger writing warnings and errors, slow queries
+// included, to the writer. The output of the commands goes to stdout.
+func initLogger(w io.Writer) *zap.SugaredLogger {
+	encoder := zap.NewProductionEncoderConfig()
+	encoder.EncodeTime = zapcore.ISO8601TimeEncoder
 
-	publicBlock := pem.Block{
-		Type:  "RSA PUBLIC KEY",
-		Bytes: asn1Bytes,
-	}
+	core := zapcore.NewCore(
+		zapcore.NewJSONEncoder(encoder),
+		zapcore.AddSync(w),
+		zapcore.WarnLevel,
+	)
 
-	if err := pem.Encode(publicFile, &publicBlock); err != nil {
-		return fmt.Errorf("encoding to public file: %w", err)
-	}
-
-	fmt.Println("private and public key files generated")
+	log := zap.New(core).With(zap.String("service", "ADMIN"))
 
-	return nil
+	return log.Sugar()
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestRun(t *testing.T) {
+	t.Setenv("DBPASSWORD", "s3cr3t")
+	keys := t.TempDir()
+
+	tt := []struct {
+		name   string
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{"no command", nil, exitUsage, "", "Usage: admin"},
+		{"the help flag", []string{"-h"}, exitOK, "", "Exit codes"},
+		{"an unknown flag", []string{"-bogus"}, exitUsage, "", "flag provided but not defined"},
+		{"an unknown command", []string{"bogus"}, exitUsage, "", "unknown command"},
+		{"an invalid command flag", []string{"genkey", "-bogus"}, exitUsage, "", "usage error"},
+		{"the help of a command", []string{"genkey", "-h"}, exitOK, "", "Usage: admin genkey"},
+		{"a valid command", []string{"-keys-folder", keys, "genkey", "-kid", "kid", "-alg", "ed25519"}, exitOK, "key kid generated", ""},
+		{"a failing command", []string{"-keys-folder", keys, "genkey", "-kid", "kid", "-alg", "ed25519"}, exitFailure, "", "already exists"},
+	}
+
+	t.Log("Given the need to report the outcome of the tool through its exit code.")
+	{
+		for testID, tc := range tt {
+			t.Logf("\tTest %d:\tWhen running the tool with %s.", testID, tc.name)
+			{
+				var stdout, stderr bytes.Buffer
+				code := run(tc.args, &stdout, &stderr)
+
+				if code != tc.code {
+					t.Fatalf("\t%s\tTest %d:\tShould exit with %d : got %d, %s.", failed, testID, tc.code, code, stderr.String())
+				}
+				t.Logf("\t%s\tTest %d:\tShould exit with %d.", success, testID, tc.code)
+
+				if !strings.Contains(stdout.String(), tc.stdout) || !strings.Contains(stderr.String(), tc.stderr) {
+					t.Fatalf("\t%s\tTest %d:\tShould explain the outcome : got %q, %q.", failed, testID, stdout.String(), stderr.String())
+				}
+				t.Logf("\t%s\tTest %d:\tShould explain the outcome.", success, testID)
+
+				if strings.Contains(stderr.String(), "s3cr3t") {
+					t.Fatalf("\t%s\tTest %d:\tShould not show the database password : got %q.", failed, testID, stderr.String())
+				}
+				t.Logf("\t%s\tTest %d:\tShould not show the database password.", success, testID)
+			}
+		}
+	}
+}
@@ -2,36 +2,55 @@
 package auth
 
 import (
-	"crypto/rsa"
+	"context"
+	"crypto"
 	"errors"
 	"fmt"
+	"sync"
 
+	"github.com/dimashiro/service/foundation/keystore"
 	"github.com/golang-jwt/jwt/v4"
 )
 
+// KeyLookup declares the behavior required to find the keys by kid. The
+// signing algorithm is picked from the type of the key.
 type KeyLookup interface {
-	PrivateKey(kid string) (*rsa.PrivateKey, error)
-	PublicKey(kid string) (*rsa.PublicKey, error)
+	PrivateKey(kid string) (crypto.Signer, error)
+	PublicKey(kid string) (crypto.PublicKey, error)
+}
+
+// RevocationLookup declares the behavior required to check if a token was
+// revoked before its expiration.
+type RevocationLookup interface {
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// APIKeyLookup declares the behavior required to resolve an API key to the
+// claims of its owner.
+type APIKeyLookup interface {
+	ValidateAPIKey(ctx context.Context, key string) (Claims, error)
 }
 
 type Auth struct {
+	mu        sync.RWMutex
+	issuer    string
 	activeKID string
 	keyLookup KeyLookup
-	method    jwt.SigningMethod
+	revoked   RevocationLookup
+	apiKeys   APIKeyLookup
 	keyFunc   func(t *jwt.Token) (interface{}, error)
 	parser    jwt.Parser
 }
 
-func New(activeKID string, keyLookup KeyLookup) (*Auth, error) {
+// New constructs an Auth for signing and validating tokens. The issuer is the
+// iss claim of the tokens it signs, the URL other services discover our keys
+// from. The revocation lookup is optional, when it is nil revoked tokens are
+// not checked. The API key lookup is optional too, when it is nil API keys
+// are rejected.
+func New(issuer string, activeKID string, keyLookup KeyLookup, revoked RevocationLookup, apiKeys APIKeyLookup) (*Auth, error) {
 
-	_, err := keyLookup.PrivateKey(activeKID)
-	if err != nil {
-		return nil, errors.New("active KID does not exist in store")
-	}
-
-	method := jwt.GetSigningMethod("RS256")
-	if method == nil {
-		return nil, errors.New("configuring algorithm RS256")
+	if _, _, err := signingKey(keyLookup, activeKID); err != nil {
+		return nil, err
 	}
 
 	keyFunc := func(t *jwt.Token) (interface{}, error) {
@@ -43,17 +62,35 @@ func New(activeKID string, keyLookup KeyLookup) (*Auth, error) {
 		if !ok {
 			return nil, errors.New("user token key id (kid) must be string")
 		}
-		return keyLookup.PublicKey(kidID)
+
+		publicKey, err := keyLookup.PublicKey(kidID)
+		if err != nil {
+			return nil, err
+		}
+
+		// The algorithm is bound to the key, never trust the token header
+		// alone to pick it.
+		alg, err := keystore.Algorithm(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		if t.Method.Alg() != alg {
+			return nil, fmt.Errorf("token algorithm %s does not match key algorithm %s", t.Method.Alg(), alg)
+		}
+
+		return publicKey, nil
 	}
 
 	parser := jwt.Parser{
-		ValidMethods: []string{"RS256"},
+		ValidMethods: keystore.Algorithms,
 	}
 
 	a := Auth{
+		issuer:    issuer,
 		activeKID: activeKID,
 		keyLookup: keyLookup,
-		method:    method,
+		revoked:   revoked,
+		apiKeys:   apiKeys,
 		keyFunc:   keyFunc,
 		parser:    parser,
 	}
@@ -61,15 +98,47 @@ func New(activeKID string, keyLookup KeyLookup) (*Auth, error) {
 	return &a, nil
 }
 
+// Issuer returns the iss claim of the tokens signed by the Auth.
+func (a *Auth) Issuer() string {
+	return a.issuer
+}
+
+// ActiveKID returns the key id currently used to sign tokens.
+func (a *Auth) ActiveKID() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.activeKID
+}
+
+// SetActiveKID switches the key used to sign new tokens. Tokens signed with
+// the previous key are still valid as long as that key stays in the store.
+func (a *Auth) SetActiveKID(kid string) error {
+	if _, _, err := signingKey(a.keyLookup, kid); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.activeKID = kid
+	return nil
+}
+
+// GenerateToken signs the claims with the active key. The token is issued by
+// the issuer of the Auth, whatever the claims say.
 func (a *Auth) GenerateToken(claims Claims) (string, error) {
-	token := jwt.NewWithClaims(a.method, claims)
-	token.Header["kid"] = a.activeKID
+	claims.Issuer = a.issuer
+	activeKID := a.ActiveKID()
 
-	privateKey, err := a.keyLookup.PrivateKey(a.activeKID)
+	privateKey, method, err := signingKey(a.keyLookup, activeKID)
 	if err != nil {
-		return "", errors.New("kid lookup failed")
+		return "", err
 	}
 
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = activeKID
+
 	str, err := token.SignedString(privateKey)
 	if err != nil {
 		return "", fmt.Errorf("signing token: %w", err)
@@ -78,16 +147,60 @@ func (a *Auth) GenerateToken(claims Claims) (string, error) {
 	return str, nil
 }
 
-func (a *Auth) ValidateToken(tokenStr string) (Claims, error) {
+// ValidateToken returns the claims of a token signed by one of our keys. The
+// tokens that can't be trusted fail with ErrInvalidToken, other errors are
+// failures to check them.
+func (a *Auth) ValidateToken(ctx context.Context, tokenStr string) (Claims, error) {
 	var claims Claims
 	token, err := a.parser.ParseWithClaims(tokenStr, &claims, a.keyFunc)
 	if err != nil {
-		return Claims{}, fmt.Errorf("parsing token: %w", err)
+		return Claims{}, fmt.Errorf("%w: parsing token: %v", ErrInvalidToken, err)
 	}
 
 	if !token.Valid {
-		return Claims{}, errors.New("invalid token")
+		return Claims{}, ErrInvalidToken
+	}
+
+	if a.revoked != nil && claims.Id != "" {
+		revoked, err := a.revoked.IsRevoked(ctx, claims.Id)
+		if err != nil {
+			return Claims{}, fmt.Errorf("checking revocation: %w", err)
+		}
+		if revoked {
+			return Claims{}, ErrRevoked
+		}
 	}
 
 	return claims, nil
 }
+
+// ValidateAPIKey returns the claims of the owner of the API key, limited to
+// the scopes of the key.
+func (a *Auth) ValidateAPIKey(ctx context.Context, key string) (Claims, error) {
+	if a.apiKeys == nil {
+		return Claims{}, ErrInvalidAPIKey
+	}
+
+	return a.apiKeys.ValidateAPIKey(ctx, key)
+}
+
+// signingKey finds the private key for the kid and the signing method that
+// goes with it.
+func signingKey(keyLookup KeyLookup, kid string) (crypto.Signer, jwt.SigningMethod, error) {
+	privateKey, err := keyLookup.PrivateKey(kid)
+	if err != nil {
+		return nil, nil, errors.New("active KID does not exist in store")
+	}
+
+	alg, err := keystore.Algorithm(privateKey.Public())
+	if err != nil {
+		return nil, nil, fmt.Errorf("kid %s: %w", kid, err)
+	}
+
+	method := jwt.GetSigningMethod(alg)
+	if method == nil {
+		return nil, nil, fmt.Errorf("configuring algorithm %s", alg)
+	}
+
+	return privateKey, method, nil
+}
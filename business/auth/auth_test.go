@@ -1,12 +1,19 @@
 package auth_test
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/foundation/keystore"
 	"github.com/golang-jwt/jwt/v4"
 )
 
@@ -27,7 +34,7 @@ func TestAuth(t *testing.T) {
 		}
 		t.Logf("\t%s\tTest:\tShould be able to create a private key.", success)
 
-		a, err := auth.New(keyID, &keyStore{pk: privateKey})
+		a, err := auth.New("http://localhost:3000", keyID, &keyStore{pk: privateKey}, nil, nil)
 		if err != nil {
 			t.Fatalf("\t%s\tTest:\tShould be able to create an authenticator: %v", failed, err)
 		}
@@ -49,7 +56,7 @@ func TestAuth(t *testing.T) {
 		}
 		t.Logf("\t%s\tTest:\tShould be able to generate a JWT.", success)
 
-		parsedClaims, err := a.ValidateToken(token)
+		parsedClaims, err := a.ValidateToken(context.Background(), token)
 		if err != nil {
 			t.Fatalf("\t%s\tTest:\tShould be able to parse the claims: %v", failed, err)
 		}
@@ -68,17 +75,242 @@ func TestAuth(t *testing.T) {
 			t.Fatalf("\t%s\tTest:\tShould have the expected roles: %v", failed, err)
 		}
 		t.Logf("\t%s\tTest:\tShould have the expected roles.", success)
+
+		if exp, got := a.Issuer(), parsedClaims.Issuer; exp != got {
+			t.Logf("\t\tTest:\texp: %v", exp)
+			t.Logf("\t\tTest:\tgot: %v", got)
+			t.Fatalf("\t%s\tTest:\tShould be issued by the configured issuer.", failed)
+		}
+		t.Logf("\t%s\tTest:\tShould be issued by the configured issuer.", success)
+	}
+}
+
+func TestRevocation(t *testing.T) {
+
+	t.Logf("\tTest:\tWhen validating a revoked token.")
+	{
+		const keyID = "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to create a private key: %v", failed, err)
+		}
+		t.Logf("\t%s\tTest:\tShould be able to create a private key.", success)
+
+		revoked := revocationStore{}
+		a, err := auth.New("http://localhost:3000", keyID, &keyStore{pk: privateKey}, revoked, nil)
+		if err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to create an authenticator: %v", failed, err)
+		}
+		t.Logf("\t%s\tTest:\tShould be able to create an authenticator.", success)
+
+		claims := auth.NewClaims("5cf37266-3473-4006-984f-9325122678b7", []string{auth.RoleUser}, time.Now(), time.Hour)
+		if claims.Id == "" {
+			t.Fatalf("\t%s\tTest:\tShould have a token id in the claims.", failed)
+		}
+		t.Logf("\t%s\tTest:\tShould have a token id in the claims.", success)
+
+		token, err := a.GenerateToken(claims)
+		if err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to generate a JWT: %v", failed, err)
+		}
+		t.Logf("\t%s\tTest:\tShould be able to generate a JWT.", success)
+
+		if _, err := a.ValidateToken(context.Background(), token); err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to validate the token before revocation: %v", failed, err)
+		}
+		t.Logf("\t%s\tTest:\tShould be able to validate the token before revocation.", success)
+
+		revoked[claims.Id] = true
+
+		if _, err := a.ValidateToken(context.Background(), token); !errors.Is(err, auth.ErrRevoked) {
+			t.Fatalf("\t%s\tTest:\tShould NOT be able to validate the token after revocation: %v", failed, err)
+		}
+		t.Logf("\t%s\tTest:\tShould NOT be able to validate the token after revocation.", success)
+	}
+}
+
+func TestRotation(t *testing.T) {
+
+	t.Logf("\tTest:\tWhen switching the active key at runtime.")
+	{
+		const oldKID = "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"
+		const newKID = "0b7c1f3e-4b9a-4c55-9a63-0c2c9e7f5d10"
+
+		store := make(map[string]crypto.Signer)
+		for _, kid := range []string{oldKID, newKID} {
+			privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatalf("\t%s\tTest:\tShould be able to create a private key: %v", failed, err)
+			}
+			store[kid] = privateKey
+		}
+		t.Logf("\t%s\tTest:\tShould be able to create the private keys.", success)
+
+		a, err := auth.New("http://localhost:3000", oldKID, keystore.NewMap(store), nil, nil)
+		if err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to create an authenticator: %v", failed, err)
+		}
+		t.Logf("\t%s\tTest:\tShould be able to create an authenticator.", success)
+
+		claims := auth.NewClaims("5cf37266-3473-4006-984f-9325122678b7", []string{auth.RoleUser}, time.Now(), time.Hour)
+
+		oldToken, err := a.GenerateToken(claims)
+		if err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to generate a JWT: %v", failed, err)
+		}
+		t.Logf("\t%s\tTest:\tShould be able to generate a JWT.", success)
+
+		if err := a.SetActiveKID("unknown"); err == nil {
+			t.Fatalf("\t%s\tTest:\tShould NOT be able to activate an unknown kid.", failed)
+		}
+		t.Logf("\t%s\tTest:\tShould NOT be able to activate an unknown kid.", success)
+
+		if err := a.SetActiveKID(newKID); err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to activate a new kid: %v", failed, err)
+		}
+		t.Logf("\t%s\tTest:\tShould be able to activate a new kid.", success)
+
+		newToken, err := a.GenerateToken(claims)
+		if err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to generate a JWT: %v", failed, err)
+		}
+
+		var parser jwt.Parser
+		parsed, _, err := parser.ParseUnverified(newToken, &auth.Claims{})
+		if err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to parse the new token: %v", failed, err)
+		}
+		if kid := parsed.Header["kid"]; kid != newKID {
+			t.Fatalf("\t%s\tTest:\tShould sign new tokens with the new kid: got %v", failed, kid)
+		}
+		t.Logf("\t%s\tTest:\tShould sign new tokens with the new kid.", success)
+
+		for _, token := range []string{oldToken, newToken} {
+			if _, err := a.ValidateToken(context.Background(), token); err != nil {
+				t.Fatalf("\t%s\tTest:\tShould be able to validate tokens from both kids: %v", failed, err)
+			}
+		}
+		t.Logf("\t%s\tTest:\tShould be able to validate tokens from both kids.", success)
+	}
+}
+
+func TestAlgorithms(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("\t%s\tTest:\tShould be able to create a P-256 key: %v", failed, err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("\t%s\tTest:\tShould be able to create a P-384 key: %v", failed, err)
 	}
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("\t%s\tTest:\tShould be able to create an Ed25519 key: %v", failed, err)
+	}
+
+	tt := []struct {
+		alg string
+		key crypto.Signer
+	}{
+		{alg: "ES256", key: p256},
+		{alg: "ES384", key: p384},
+		{alg: "EdDSA", key: ed},
+	}
+
+	for _, tst := range tt {
+		t.Logf("\tTest:\tWhen signing with %s.", tst.alg)
+		{
+			const keyID = "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"
+
+			a, err := auth.New("http://localhost:3000", keyID, keystore.NewMap(map[string]crypto.Signer{keyID: tst.key}), nil, nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest:\tShould be able to create an authenticator: %v", failed, err)
+			}
+			t.Logf("\t%s\tTest:\tShould be able to create an authenticator.", success)
+
+			claims := auth.NewClaims("5cf37266-3473-4006-984f-9325122678b7", []string{auth.RoleUser}, time.Now(), time.Hour)
+
+			token, err := a.GenerateToken(claims)
+			if err != nil {
+				t.Fatalf("\t%s\tTest:\tShould be able to generate a JWT: %v", failed, err)
+			}
+			t.Logf("\t%s\tTest:\tShould be able to generate a JWT.", success)
+
+			var parser jwt.Parser
+			parsed, _, err := parser.ParseUnverified(token, &auth.Claims{})
+			if err != nil {
+				t.Fatalf("\t%s\tTest:\tShould be able to parse the token: %v", failed, err)
+			}
+			if got := parsed.Method.Alg(); got != tst.alg {
+				t.Fatalf("\t%s\tTest:\tShould sign with the algorithm of the key: got %s", failed, got)
+			}
+			t.Logf("\t%s\tTest:\tShould sign with the algorithm of the key.", success)
+
+			parsedClaims, err := a.ValidateToken(context.Background(), token)
+			if err != nil {
+				t.Fatalf("\t%s\tTest:\tShould be able to validate the token: %v", failed, err)
+			}
+			if parsedClaims.Subject != claims.Subject {
+				t.Fatalf("\t%s\tTest:\tShould get back the subject: got %s", failed, parsedClaims.Subject)
+			}
+			t.Logf("\t%s\tTest:\tShould be able to validate the token.", success)
+		}
+	}
+}
+
+type revocationStore map[string]bool
+
+func (rs revocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	return rs[jti], nil
 }
 
 type keyStore struct {
 	pk *rsa.PrivateKey
 }
 
-func (ks *keyStore) PrivateKey(kid string) (*rsa.PrivateKey, error) {
+func (ks *keyStore) PrivateKey(kid string) (crypto.Signer, error) {
 	return ks.pk, nil
 }
 
-func (ks *keyStore) PublicKey(kid string) (*rsa.PublicKey, error) {
+func (ks *keyStore) PublicKey(kid string) (crypto.PublicKey, error) {
 	return &ks.pk.PublicKey, nil
 }
+
+func TestPermissions(t *testing.T) {
+
+	t.Logf("\tTest:\tWhen checking the permissions of a token.")
+	{
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to create a private key: %v", failed, err)
+		}
+
+		a, err := auth.New("http://localhost:3000", "kid", &keyStore{pk: privateKey}, nil, nil)
+		if err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to create an authenticator: %v", failed, err)
+		}
+
+		claims := auth.NewClaims("5cf37266-3473-4006-984f-9325122678b7", []string{auth.RoleUser}, time.Now(), time.Hour)
+		claims.Permissions = []string{auth.PermProductsRead, auth.PermProductsWrite}
+
+		token, err := a.GenerateToken(claims)
+		if err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to generate a JWT: %v", failed, err)
+		}
+
+		parsedClaims, err := a.ValidateToken(context.Background(), token)
+		if err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to parse the claims: %v", failed, err)
+		}
+
+		if !parsedClaims.HasPermission(auth.PermProductsRead, auth.PermProductsWrite) {
+			t.Fatalf("\t%s\tTest:\tShould have the embedded permissions: %v", failed, parsedClaims.Permissions)
+		}
+		t.Logf("\t%s\tTest:\tShould have the embedded permissions.", success)
+
+		if parsedClaims.HasPermission(auth.PermProductsRead, auth.PermUsersRead) {
+			t.Fatalf("\t%s\tTest:\tShould NOT have a permission that wasn't granted.", failed)
+		}
+		t.Logf("\t%s\tTest:\tShould NOT have a permission that wasn't granted.", success)
+	}
+}
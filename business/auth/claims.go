@@ -3,8 +3,11 @@ package auth
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 )
 
 const (
@@ -12,9 +15,40 @@ const (
 	RoleUser  = "USER"
 )
 
+// ErrInvalidToken is returned when a token is malformed, expired, not signed
+// by one of our keys or revoked.
+var ErrInvalidToken = errors.New("invalid token")
+
+// ErrRevoked is returned when a token was revoked before its expiration.
+var ErrRevoked = fmt.Errorf("%w: token has been revoked", ErrInvalidToken)
+
+// ErrInvalidAPIKey is returned when an API key is unknown, expired or its
+// owner no longer exists.
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// Claims are the claims of the tokens issued by the service. Permissions
+// are resolved from the roles when the token is issued. APIKeyID is set when
+// the claims were resolved from an API key instead of a token.
 type Claims struct {
 	jwt.StandardClaims
-	Roles []string `json:"roles"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"perms,omitempty"`
+	APIKeyID    string   `json:"-"`
+}
+
+// NewClaims constructs the claims for a token valid for the ttl starting from
+// now. Every token gets a unique id (jti) so it can be revoked. The issuer is
+// set by the Auth signing the token.
+func NewClaims(subject string, roles []string, now time.Time, ttl time.Duration) Claims {
+	return Claims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.NewString(),
+			Subject:   subject,
+			ExpiresAt: now.Add(ttl).Unix(),
+			IssuedAt:  now.UTC().Unix(),
+		},
+		Roles: roles,
+	}
 }
 
 func (c Claims) Authorized(roles ...string) bool {
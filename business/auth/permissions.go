@@ -0,0 +1,32 @@
+package auth
+
+// Set of permissions checked by the service. A role is a named set of
+// permissions, the sets are stored in the database.
+const (
+	PermUsersRead      = "users:read"
+	PermUsersWrite     = "users:write"
+	PermProductsRead   = "products:read"
+	PermProductsWrite  = "products:write"
+	PermProductsManage = "products:manage"
+	PermSalesCreate    = "sales:create"
+	PermSalesRead      = "sales:read"
+	PermAPIKeysManage  = "apikeys:manage"
+	PermAuditRead      = "audit:read"
+)
+
+// HasPermission reports if the claims grant every one of the permissions.
+func (c Claims) HasPermission(perms ...string) bool {
+	for _, want := range perms {
+		found := false
+		for _, has := range c.Permissions {
+			if has == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// ErrNoSecretKey is returned by a nil SecretBox, for the programs that never
+// handle the secrets.
+var ErrNoSecretKey = errors.New("secret key not configured")
+
+// SecretBox encrypts the secrets stored in the database, like the TOTP
+// secrets, with AES-GCM. Each secret is bound to the id of the row holding
+// it so a sealed secret can't be copied to another row.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox constructs a SecretBox from a 32 bytes key encoded in
+// standard base64.
+func NewSecretBox(key string) (*SecretBox, error) {
+	k, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("decoding secret key: %w", err)
+	}
+	if len(k) != 32 {
+		return nil, fmt.Errorf("secret key must be 32 bytes, got %d", len(k))
+	}
+
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, fmt.Errorf("constructing cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("constructing cipher: %w", err)
+	}
+
+	return &SecretBox{aead: aead}, nil
+}
+
+// Seal encrypts the secret of the row with the id. The result is the nonce
+// followed by the ciphertext, encoded in standard base64.
+func (sb *SecretBox) Seal(id string, secret string) (string, error) {
+	if sb == nil {
+		return "", ErrNoSecretKey
+	}
+
+	nonce := make([]byte, sb.aead.NonceSize(), sb.aead.NonceSize()+len(secret)+sb.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generating nonce: %w", err)
+	}
+
+	sealed := sb.aead.Seal(nonce, nonce, []byte(secret), []byte(id))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a secret sealed for the row with the id.
+func (sb *SecretBox) Open(id string, sealed string) (string, error) {
+	if sb == nil {
+		return "", ErrNoSecretKey
+	}
+
+	b, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", fmt.Errorf("decoding secret: %w", err)
+	}
+	if len(b) < sb.aead.NonceSize() {
+		return "", errors.New("decoding secret: too short")
+	}
+
+	secret, err := sb.aead.Open(nil, b[:sb.aead.NonceSize()], b[sb.aead.NonceSize():], []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("opening secret: %w", err)
+	}
+
+	return string(secret), nil
+}
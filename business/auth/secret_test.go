@@ -0,0 +1,68 @@
+package auth_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dimashiro/service/business/auth"
+)
+
+func TestSecretBox(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+
+	sb, err := auth.NewSecretBox(key)
+	if err != nil {
+		t.Fatalf("Should be able to construct the secret box : %s.", err)
+	}
+
+	t.Log("Given the need to encrypt the secrets stored in the database.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen sealing a secret.", testID)
+		{
+			const id = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+			const secret = "JBSWY3DPEHPK3PXP"
+
+			sealed, err := sb.Seal(id, secret)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to seal the secret : %s.", failed, testID, err)
+			}
+			if strings.Contains(sealed, secret) {
+				t.Fatalf("\t%s\tTest %d:\tShould not store the secret in clear : got %s.", failed, testID, sealed)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to seal the secret.", success, testID)
+
+			got, err := sb.Open(id, sealed)
+			if err != nil || got != secret {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to open the secret : %q, %v.", failed, testID, got, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to open the secret.", success, testID)
+
+			if _, err := sb.Open("5cf37266-3473-4006-984f-9325122678b7", sealed); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to open the secret for another row.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to open the secret for another row.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen no key is configured.", testID)
+		{
+			var none *auth.SecretBox
+			if _, err := none.Seal("id", "secret"); !errors.Is(err, auth.ErrNoSecretKey) {
+				t.Fatalf("\t%s\tTest %d:\tShould report the missing key : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report the missing key.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen the key has the wrong size.", testID)
+		{
+			if _, err := auth.NewSecretBox(base64.StdEncoding.EncodeToString([]byte("short"))); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to construct the secret box.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to construct the secret box.", success, testID)
+		}
+	}
+}
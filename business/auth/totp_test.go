@@ -0,0 +1,77 @@
+package auth_test
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimashiro/service/business/auth"
+)
+
+func TestTOTP(t *testing.T) {
+	t.Log("Given the need to verify one-time passwords.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the RFC 6238 test vectors.", testID)
+		{
+			secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+			// The RFC lists 8 digit codes, the last 6 digits are the 6 digit codes.
+			vectors := []struct {
+				unix int64
+				code string
+			}{
+				{59, "287082"},
+				{1111111109, "081804"},
+				{1111111111, "050471"},
+				{1234567890, "005924"},
+				{2000000000, "279037"},
+			}
+
+			for _, v := range vectors {
+				got, err := auth.TOTPCode(secret, time.Unix(v.unix, 0))
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to compute a code : %s.", failed, testID, err)
+				}
+				if got != v.code {
+					t.Fatalf("\t%s\tTest %d:\tShould get code %s at %d : got %s.", failed, testID, v.code, v.unix, got)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould compute the expected codes.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen validating codes.", testID)
+		{
+			secret, err := auth.GenerateTOTPSecret()
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a secret : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to generate a secret.", success, testID)
+
+			now := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+			code, err := auth.TOTPCode(secret, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to compute a code : %s.", failed, testID, err)
+			}
+
+			step, ok := auth.ValidateTOTP(secret, code, now.Add(30*time.Second))
+			if !ok || step != now.Unix()/30 {
+				t.Fatalf("\t%s\tTest %d:\tShould accept the code of the previous step : %d, %v.", failed, testID, step, ok)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept the code of the previous step.", success, testID)
+
+			if _, ok := auth.ValidateTOTP(secret, code, now.Add(2*time.Minute)); ok {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept an old code.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept an old code.", success, testID)
+
+			uri := auth.TOTPURI("Retail", "user@example.com", secret)
+			if !strings.HasPrefix(uri, "otpauth://totp/Retail:user@example.com?") || !strings.Contains(uri, "secret="+secret) {
+				t.Fatalf("\t%s\tTest %d:\tShould build the otpauth URI : %s.", failed, testID, uri)
+			}
+			t.Logf("\t%s\tTest %d:\tShould build the otpauth URI.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+// Package apikey provides the business logic of the API keys.
+package apikey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/data/store/apikey"
+	"github.com/dimashiro/service/business/data/store/audit"
+	"github.com/dimashiro/service/business/data/store/role"
+	"github.com/dimashiro/service/business/data/store/user"
+	"github.com/dimashiro/service/business/database"
+	"github.com/dimashiro/service/foundation/webapp"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// touchEvery limits how often the last use of a key is written, so a busy
+// caller doesn't update the same row on every request.
+const touchEvery = time.Minute
+
+type Core struct {
+	log    *zap.SugaredLogger
+	db     *sqlx.DB
+	apikey apikey.Store
+	user   user.Store
+	role   role.Store
+	audit  audit.Store
+}
+
+func NewCore(log *zap.SugaredLogger, db *sqlx.DB) Core {
+	return Core{
+		log:    log,
+		db:     db,
+		apikey: apikey.NewStore(log, db),
+		user:   user.NewStore(log, db),
+		role:   role.NewStore(log, db),
+		audit:  audit.NewStore(log, db),
+	}
+}
+
+// Create adds an API key and returns it along with the key to hand to the
+// client, which can't be retrieved later. The creation is recorded in the
+// audit log.
+func (c Core) Create(ctx context.Context, claims auth.Claims, nk apikey.NewAPIKeyDTO, now time.Time) (apikey.APIKey, string, error) {
+	var key apikey.APIKey
+	var value string
+
+	tran := func(tx sqlx.ExtContext) error {
+		var err error
+		key, value, err = c.apikey.Tran(tx).Create(ctx, claims, nk, now)
+		if err != nil {
+			return err
+		}
+
+		ne := audit.NewEvent{
+			Actor:      claims.Subject,
+			Action:     audit.ActionAPIKeyCreate,
+			TargetType: audit.TargetAPIKey,
+			TargetID:   key.ID,
+			After:      key,
+		}
+		return c.audit.Tran(tx).Record(ctx, ne, now)
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return apikey.APIKey{}, "", fmt.Errorf("create: %w", err)
+	}
+
+	return key, value, nil
+}
+
+// Update modifies an API key and records the changes in the audit log.
+func (c Core) Update(ctx context.Context, claims auth.Claims, keyID string, uk apikey.UpdateAPIKeyDTO, now time.Time) error {
+	tran := func(tx sqlx.ExtContext) error {
+		before, err := c.apikey.Tran(tx).GetByID(ctx, claims, keyID)
+		if err != nil {
+			return err
+		}
+
+		if err := c.apikey.Tran(tx).Update(ctx, claims, keyID, uk, now); err != nil {
+			return err
+		}
+
+		after, err := c.apikey.Tran(tx).GetByID(ctx, claims, keyID)
+		if err != nil {
+			return err
+		}
+
+		ne := audit.NewEvent{
+			Actor:      claims.Subject,
+			Action:     audit.ActionAPIKeyUpdate,
+			TargetType: audit.TargetAPIKey,
+			TargetID:   keyID,
+			Before:     before,
+			After:      after,
+		}
+		return c.audit.Tran(tx).Record(ctx, ne, now)
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes an API key and records what was removed in the audit log.
+func (c Core) Delete(ctx context.Context, claims auth.Claims, keyID string, now time.Time) error {
+	tran := func(tx sqlx.ExtContext) error {
+		before, err := c.apikey.Tran(tx).GetByID(ctx, claims, keyID)
+		if err != nil {
+			return err
+		}
+
+		if err := c.apikey.Tran(tx).Delete(ctx, claims, keyID); err != nil {
+			return err
+		}
+
+		ne := audit.NewEvent{
+			Actor:      claims.Subject,
+			Action:     audit.ActionAPIKeyDelete,
+			TargetType: audit.TargetAPIKey,
+			TargetID:   keyID,
+			Before:     before,
+		}
+		return c.audit.Tran(tx).Record(ctx, ne, now)
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	return nil
+}
+
+func (c Core) GetAll(ctx context.Context, claims auth.Claims) ([]apikey.APIKey, error) {
+
+	keys, err := c.apikey.GetAll(ctx, claims)
+	if err != nil {
+		return nil, fmt.Errorf("get all api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (c Core) GetByID(ctx context.Context, claims auth.Claims, keyID string) (apikey.APIKey, error) {
+
+	key, err := c.apikey.GetByID(ctx, claims, keyID)
+	if err != nil {
+		return apikey.APIKey{}, fmt.Errorf("get api key by id: %w", err)
+	}
+
+	return key, nil
+}
+
+// ValidateAPIKey resolves an API key to the claims of its owner, as a token
+// issued now would carry them, limited to the scopes of the key. Keys owned
+// by a service get the scopes as permissions and no roles.
+func (c Core) ValidateAPIKey(ctx context.Context, value string) (auth.Claims, error) {
+	now := time.Now()
+
+	key, err := c.apikey.QueryByKey(ctx, value)
+	if err != nil {
+		return auth.Claims{}, fmt.Errorf("validate api key: %w", err)
+	}
+
+	if key.DateExpires != nil && !now.Before(*key.DateExpires) {
+		return auth.Claims{}, fmt.Errorf("validate api key: %w", auth.ErrInvalidAPIKey)
+	}
+
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			IssuedAt: now.Unix(),
+		},
+		APIKeyID: key.ID,
+	}
+	if key.DateExpires != nil {
+		claims.ExpiresAt = key.DateExpires.Unix()
+	}
+
+	switch {
+	case key.UserID != nil:
+		owner := auth.Claims{
+			StandardClaims: jwt.StandardClaims{
+				Subject: *key.UserID,
+			},
+		}
+
+		usr, err := c.user.GetByID(ctx, owner, *key.UserID)
+		if err != nil {
+			if errors.Is(err, database.ErrDBNotFound) {
+				err = auth.ErrInvalidAPIKey
+			}
+			return auth.Claims{}, fmt.Errorf("validate api key: %w", err)
+		}
+
+		perms, err := c.role.Permissions(ctx, usr.Roles)
+		if err != nil {
+			return auth.Claims{}, fmt.Errorf("validate api key: %w", err)
+		}
+
+		claims.Subject = usr.ID
+		claims.Roles = usr.Roles
+		claims.Permissions = intersect(key.Scopes, perms)
+
+	default:
+		claims.Subject = "service:" + *key.Service
+		claims.Permissions = key.Scopes
+	}
+
+	if key.DateLastUsed == nil || now.Sub(*key.DateLastUsed) >= touchEvery {
+		if err := c.apikey.Touch(ctx, key.ID, now); err != nil {
+			c.log.Errorw("apikey", "traceid", webapp.GetTraceID(ctx), "keyid", key.ID, "ERROR", err)
+		}
+	}
+
+	return claims, nil
+}
+
+// intersect returns the scopes still granted by the permissions, so a key
+// loses what its owner lost since it was created.
+func intersect(scopes []string, perms []string) []string {
+	var out []string
+	for _, scope := range scopes {
+		for _, perm := range perms {
+			if scope == perm {
+				out = append(out, scope)
+				break
+			}
+		}
+	}
+	return out
+}
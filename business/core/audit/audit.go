@@ -0,0 +1,34 @@
+// Package audit provides the business logic to query the audit log. Events
+// are recorded by the other cores, in the transaction of the change.
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dimashiro/service/business/data/store/audit"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+type Core struct {
+	log   *zap.SugaredLogger
+	audit audit.Store
+}
+
+func NewCore(log *zap.SugaredLogger, db *sqlx.DB) Core {
+	return Core{
+		log:   log,
+		audit: audit.NewStore(log, db),
+	}
+}
+
+func (c Core) GetAll(ctx context.Context, qf audit.QueryFilter) ([]audit.Event, string, error) {
+
+	evts, next, err := c.audit.GetAll(ctx, qf)
+	if err != nil {
+		return nil, "", fmt.Errorf("get all audit events: %w", err)
+	}
+
+	return evts, next, nil
+}
@@ -0,0 +1,142 @@
+package product
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/data/store/audit"
+	"github.com/dimashiro/service/business/data/store/product"
+	"github.com/dimashiro/service/business/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+type Core struct {
+	log     *zap.SugaredLogger
+	db      *sqlx.DB
+	product product.Store
+	audit   audit.Store
+}
+
+func NewCore(log *zap.SugaredLogger, db *sqlx.DB) Core {
+	return Core{
+		log:     log,
+		db:      db,
+		product: product.NewStore(log, db),
+		audit:   audit.NewStore(log, db),
+	}
+}
+
+// Create adds a product and records the creation in the audit log.
+func (c Core) Create(ctx context.Context, claims auth.Claims, np product.NewProductDTO, now time.Time) (product.Product, error) {
+	var prd product.Product
+
+	tran := func(tx sqlx.ExtContext) error {
+		var err error
+		prd, err = c.product.Tran(tx).Create(ctx, claims, np, now)
+		if err != nil {
+			return err
+		}
+
+		ne := audit.NewEvent{
+			Actor:      claims.Subject,
+			Action:     audit.ActionProductCreate,
+			TargetType: audit.TargetProduct,
+			TargetID:   prd.ID,
+			After:      prd,
+		}
+		return c.audit.Tran(tx).Record(ctx, ne, now)
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return product.Product{}, fmt.Errorf("create: %w", err)
+	}
+
+	return prd, nil
+}
+
+// Update modifies a product and records the changes in the audit log.
+func (c Core) Update(ctx context.Context, claims auth.Claims, productID string, up product.UpdateProductDTO, now time.Time) error {
+	tran := func(tx sqlx.ExtContext) error {
+		before, err := c.product.Tran(tx).GetByID(ctx, productID)
+		if err != nil {
+			return err
+		}
+
+		if err := c.product.Tran(tx).Update(ctx, claims, productID, up, now); err != nil {
+			return err
+		}
+
+		after, err := c.product.Tran(tx).GetByID(ctx, productID)
+		if err != nil {
+			return err
+		}
+
+		ne := audit.NewEvent{
+			Actor:      claims.Subject,
+			Action:     audit.ActionProductUpdate,
+			TargetType: audit.TargetProduct,
+			TargetID:   productID,
+			Before:     before,
+			After:      after,
+		}
+		return c.audit.Tran(tx).Record(ctx, ne, now)
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a product and records what was removed in the audit log.
+func (c Core) Delete(ctx context.Context, claims auth.Claims, productID string, now time.Time) error {
+	tran := func(tx sqlx.ExtContext) error {
+		before, err := c.product.Tran(tx).GetByID(ctx, productID)
+		if err != nil {
+			return err
+		}
+
+		if err := c.product.Tran(tx).Delete(ctx, claims, productID); err != nil {
+			return err
+		}
+
+		ne := audit.NewEvent{
+			Actor:      claims.Subject,
+			Action:     audit.ActionProductDelete,
+			TargetType: audit.TargetProduct,
+			TargetID:   productID,
+			Before:     before,
+		}
+		return c.audit.Tran(tx).Record(ctx, ne, now)
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	return nil
+}
+
+func (c Core) GetAll(ctx context.Context, pageNumber int, rowsPerPage int) ([]product.Product, error) {
+
+	prds, err := c.product.GetAll(ctx, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("get all products: %w", err)
+	}
+
+	return prds, nil
+}
+
+func (c Core) GetByID(ctx context.Context, productID string) (product.Product, error) {
+
+	prd, err := c.product.GetByID(ctx, productID)
+	if err != nil {
+		return product.Product{}, fmt.Errorf("get product by id: %w", err)
+	}
+
+	return prd, nil
+}
@@ -0,0 +1,68 @@
+package sale
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/data/store/audit"
+	"github.com/dimashiro/service/business/data/store/sale"
+	"github.com/dimashiro/service/business/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+type Core struct {
+	log   *zap.SugaredLogger
+	db    *sqlx.DB
+	sale  sale.Store
+	audit audit.Store
+}
+
+func NewCore(log *zap.SugaredLogger, db *sqlx.DB) Core {
+	return Core{
+		log:   log,
+		db:    db,
+		sale:  sale.NewStore(log, db),
+		audit: audit.NewStore(log, db),
+	}
+}
+
+// Create records a sale and adds it to the audit log.
+func (c Core) Create(ctx context.Context, claims auth.Claims, productID string, ns sale.NewSaleDTO, now time.Time) (sale.Sale, error) {
+	var sl sale.Sale
+
+	tran := func(tx sqlx.ExtContext) error {
+		var err error
+		sl, err = c.sale.Tran(tx).Create(ctx, claims, productID, ns, now)
+		if err != nil {
+			return err
+		}
+
+		ne := audit.NewEvent{
+			Actor:      claims.Subject,
+			Action:     audit.ActionSaleCreate,
+			TargetType: audit.TargetSale,
+			TargetID:   sl.ID,
+			After:      sl,
+		}
+		return c.audit.Tran(tx).Record(ctx, ne, now)
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return sale.Sale{}, fmt.Errorf("create: %w", err)
+	}
+
+	return sl, nil
+}
+
+func (c Core) GetByProductID(ctx context.Context, productID string) ([]sale.Sale, error) {
+
+	sls, err := c.sale.GetByProductID(ctx, productID)
+	if err != nil {
+		return nil, fmt.Errorf("get sales by product id: %w", err)
+	}
+
+	return sls, nil
+}
@@ -0,0 +1,261 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/data/store/audit"
+	"github.com/dimashiro/service/business/data/store/token"
+	"github.com/dimashiro/service/business/data/store/user"
+	"github.com/dimashiro/service/business/database"
+	"github.com/dimashiro/service/foundation/webapp"
+	"github.com/jmoiron/sqlx"
+)
+
+// Challenge is the second step of the login of a user with MFA. The token is
+// exchanged, along with a one-time password, for the access token. Enroll is
+// set when MFA is required but the user has no authenticator app yet.
+type Challenge struct {
+	Token     string
+	ExpiresAt time.Time
+	Enroll    bool
+}
+
+// MFAEnrollment is the TOTP secret to add to an authenticator app. The URI
+// carries the secret and is usually shown as a QR code.
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// EnrollMFA generates a TOTP secret for the user described by the claims.
+// MFA is enabled once the user confirms it with ConfirmMFA.
+func (c Core) EnrollMFA(ctx context.Context, claims auth.Claims, issuer string, now time.Time) (MFAEnrollment, error) {
+	usr, err := c.user.GetByID(ctx, claims, claims.Subject)
+	if err != nil {
+		return MFAEnrollment{}, fmt.Errorf("enroll mfa: %w", err)
+	}
+
+	enr, err := c.enroll(ctx, usr, issuer, now)
+	if err != nil {
+		return MFAEnrollment{}, fmt.Errorf("enroll mfa: %w", err)
+	}
+
+	return enr, nil
+}
+
+// EnrollChallenge generates a TOTP secret for a user who has to enroll
+// before completing their login. The challenge stays valid, the enrollment
+// is confirmed by completing it.
+func (c Core) EnrollChallenge(ctx context.Context, challenge string, issuer string, now time.Time) (MFAEnrollment, error) {
+	ut, err := c.token.QueryUserToken(ctx, token.PurposeMFA, challenge, now)
+	if err != nil {
+		return MFAEnrollment{}, fmt.Errorf("enroll challenge: %w", err)
+	}
+
+	usr, err := c.owner(ctx, c.db, ut.UserID)
+	if err != nil {
+		return MFAEnrollment{}, fmt.Errorf("enroll challenge: %w", err)
+	}
+
+	enr, err := c.enroll(ctx, usr, issuer, now)
+	if err != nil {
+		return MFAEnrollment{}, fmt.Errorf("enroll challenge: %w", err)
+	}
+
+	return enr, nil
+}
+
+// ConfirmMFA enables MFA for the user described by the claims once they
+// provide a code of the enrolled secret. It returns the recovery codes of
+// the user.
+func (c Core) ConfirmMFA(ctx context.Context, claims auth.Claims, code string, now time.Time) ([]string, error) {
+	var codes []string
+
+	tran := func(tx sqlx.ExtContext) error {
+		usr, err := c.user.Tran(tx).GetByID(ctx, claims, claims.Subject)
+		if err != nil {
+			return err
+		}
+
+		codes, err = c.confirm(ctx, tx, usr, code, now)
+		return err
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return nil, fmt.Errorf("confirm mfa: %w", err)
+	}
+
+	return codes, nil
+}
+
+// CompleteChallenge verifies the one-time password, or a recovery code, of
+// the user the challenge was issued to and returns the user so tokens can be
+// issued. The challenge is consumed even when the code is wrong. When the
+// challenge completes an enrollment, the new recovery codes are returned.
+func (c Core) CompleteChallenge(ctx context.Context, challenge string, code string, now time.Time) (user.User, []string, error) {
+	ut, err := c.token.UseUserToken(ctx, token.PurposeMFA, challenge, now)
+	if err != nil {
+		return user.User{}, nil, fmt.Errorf("complete challenge: %w", err)
+	}
+
+	var usr user.User
+	var codes []string
+
+	tran := func(tx sqlx.ExtContext) error {
+		var err error
+		usr, err = c.owner(ctx, tx, ut.UserID)
+		if err != nil {
+			return err
+		}
+
+		if !usr.MFAEnabled {
+			codes, err = c.confirm(ctx, tx, usr, code, now)
+			return err
+		}
+
+		return c.verify(ctx, tx, usr, code, now, true)
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return user.User{}, nil, fmt.Errorf("complete challenge: %w", err)
+	}
+
+	return usr, codes, nil
+}
+
+// DisableMFA turns MFA off for the user described by the claims, who has to
+// provide a one-time password or a recovery code. Users required to use MFA
+// can't turn it off.
+func (c Core) DisableMFA(ctx context.Context, claims auth.Claims, code string, now time.Time) error {
+	tran := func(tx sqlx.ExtContext) error {
+		usr, err := c.user.Tran(tx).GetByID(ctx, claims, claims.Subject)
+		if err != nil {
+			return err
+		}
+
+		if usr.MFARequired {
+			return database.ErrForbidden
+		}
+		if !usr.MFAEnabled {
+			return user.ErrMFANotEnrolled
+		}
+
+		if err := c.verify(ctx, tx, usr, code, now, true); err != nil {
+			return err
+		}
+
+		if err := c.user.Tran(tx).DisableMFA(ctx, usr.ID, now); err != nil {
+			return err
+		}
+
+		ne := audit.NewEvent{
+			Actor:      claims.Subject,
+			Action:     audit.ActionUserMFADisable,
+			TargetType: audit.TargetUser,
+			TargetID:   usr.ID,
+		}
+		if err := c.audit.Tran(tx).Record(ctx, ne, now); err != nil {
+			return err
+		}
+
+		return c.token.Tran(tx).DeleteRecoveryCodes(ctx, usr.ID)
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return fmt.Errorf("disable mfa: %w", err)
+	}
+
+	return nil
+}
+
+// enroll stores a new pending TOTP secret for the user, encrypted.
+func (c Core) enroll(ctx context.Context, usr user.User, issuer string, now time.Time) (MFAEnrollment, error) {
+	if usr.MFAEnabled {
+		return MFAEnrollment{}, user.ErrMFAEnabled
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return MFAEnrollment{}, err
+	}
+
+	sealed, err := c.secrets.Seal(usr.ID, secret)
+	if err != nil {
+		return MFAEnrollment{}, err
+	}
+
+	if err := c.user.SetMFASecret(ctx, usr.ID, sealed, now); err != nil {
+		return MFAEnrollment{}, err
+	}
+
+	enr := MFAEnrollment{
+		Secret: secret,
+		URI:    auth.TOTPURI(issuer, usr.Email, secret),
+	}
+
+	return enr, nil
+}
+
+// confirm enables the pending TOTP secret of the user when the code matches
+// it, records it in the audit log and issues their recovery codes.
+func (c Core) confirm(ctx context.Context, tx sqlx.ExtContext, usr user.User, code string, now time.Time) ([]string, error) {
+	if usr.MFAEnabled {
+		return nil, user.ErrMFAEnabled
+	}
+	if !usr.MFASecret.Valid {
+		return nil, user.ErrMFANotEnrolled
+	}
+
+	if err := c.verify(ctx, tx, usr, code, now, false); err != nil {
+		return nil, err
+	}
+
+	if err := c.user.Tran(tx).EnableMFA(ctx, usr.ID, now); err != nil {
+		return nil, err
+	}
+
+	ne := audit.NewEvent{
+		Actor:      usr.ID,
+		Action:     audit.ActionUserMFAEnable,
+		TargetType: audit.TargetUser,
+		TargetID:   usr.ID,
+	}
+	if err := c.audit.Tran(tx).Record(ctx, ne, now); err != nil {
+		return nil, err
+	}
+
+	return c.token.Tran(tx).CreateRecoveryCodes(ctx, usr.ID, now)
+}
+
+// verify checks a one-time password of the user or, when allowed, one of
+// their recovery codes. A one-time password can't be used twice.
+func (c Core) verify(ctx context.Context, tx sqlx.ExtContext, usr user.User, code string, now time.Time, recovery bool) error {
+	if usr.MFASecret.Valid {
+		secret, err := c.secrets.Open(usr.ID, usr.MFASecret.String)
+		if err != nil {
+			return err
+		}
+
+		if step, ok := auth.ValidateTOTP(secret, code, now); ok {
+			return c.user.Tran(tx).UseMFAStep(ctx, usr.ID, step)
+		}
+	}
+
+	if recovery {
+		err := c.token.Tran(tx).UseRecoveryCode(ctx, usr.ID, code, now)
+		switch {
+		case err == nil:
+			c.log.Infow("security", "event", "recovery_code_used", "traceid", webapp.GetTraceID(ctx), "userid", usr.ID)
+			return nil
+		case !errors.Is(err, token.ErrInvalidToken):
+			return err
+		}
+	}
+
+	c.log.Warnw("security", "event", "mfa_failed", "traceid", webapp.GetTraceID(ctx), "userid", usr.ID)
+	return database.ErrAuthenticationFailure
+}
@@ -2,63 +2,226 @@ package user
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/data/store/audit"
+	"github.com/dimashiro/service/business/data/store/product"
+	"github.com/dimashiro/service/business/data/store/role"
+	"github.com/dimashiro/service/business/data/store/token"
 	"github.com/dimashiro/service/business/data/store/user"
+	"github.com/dimashiro/service/business/database"
+	"github.com/dimashiro/service/business/mailer"
+	"github.com/dimashiro/service/business/validate"
+	"github.com/dimashiro/service/foundation/webapp"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
 
 type Core struct {
-	log  *zap.SugaredLogger
-	user user.Store
+	log     *zap.SugaredLogger
+	db      *sqlx.DB
+	user    user.Store
+	product product.Store
+	role    role.Store
+	token   token.Store
+	audit   audit.Store
+	mailer  mailer.Mailer
+	secrets *auth.SecretBox
 }
 
-func NewCore(log *zap.SugaredLogger, db *sqlx.DB) Core {
+// NewCore constructs a core for user api access. The secrets box encrypts
+// the TOTP secrets, it can be nil for programs that don't handle MFA.
+func NewCore(log *zap.SugaredLogger, db *sqlx.DB, mailer mailer.Mailer, secrets *auth.SecretBox) Core {
 	return Core{
-		log:  log,
-		user: user.NewStore(log, db),
+		log:     log,
+		db:      db,
+		mailer:  mailer,
+		secrets: secrets,
+		user:    user.NewStore(log, db),
+		product: product.NewStore(log, db),
+		role:    role.NewStore(log, db),
+		token:   token.NewStore(log, db),
+		audit:   audit.NewStore(log, db),
 	}
 }
 
-func (c Core) Create(ctx context.Context, nu user.NewUserDTO, now time.Time) (user.User, error) {
+// Create adds a new user. The user from the claims is recorded as the actor
+// in the audit log.
+func (c Core) Create(ctx context.Context, claims auth.Claims, nu user.NewUserDTO, now time.Time) (user.User, error) {
+	var usr user.User
 
-	usr, err := c.user.Create(ctx, nu, now)
-	if err != nil {
+	tran := func(tx sqlx.ExtContext) error {
+		var err error
+		usr, err = c.user.Tran(tx).Create(ctx, nu, now)
+		if err != nil {
+			return err
+		}
+
+		ne := audit.NewEvent{
+			Actor:      claims.Subject,
+			Action:     audit.ActionUserCreate,
+			TargetType: audit.TargetUser,
+			TargetID:   usr.ID,
+			After:      usr,
+		}
+		return c.audit.Tran(tx).Record(ctx, ne, now)
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
 		return user.User{}, fmt.Errorf("create: %w", err)
 	}
 
 	return usr, nil
 }
 
+// CreateWithProduct adds a new user together with an initial product owned by
+// that user. Both records are created in the same transaction and recorded in
+// the audit log with the user from the claims as the actor.
+func (c Core) CreateWithProduct(ctx context.Context, claims auth.Claims, nu user.NewUserDTO, np product.NewProductDTO, now time.Time) (user.User, product.Product, error) {
+	var usr user.User
+	var prd product.Product
+
+	tran := func(tx sqlx.ExtContext) error {
+		var err error
+		usr, err = c.user.Tran(tx).Create(ctx, nu, now)
+		if err != nil {
+			return fmt.Errorf("create user: %w", err)
+		}
+
+		owner := auth.Claims{
+			StandardClaims: jwt.StandardClaims{
+				Subject: usr.ID,
+			},
+		}
+
+		prd, err = c.product.Tran(tx).Create(ctx, owner, np, now)
+		if err != nil {
+			return fmt.Errorf("create product: %w", err)
+		}
+
+		nes := []audit.NewEvent{
+			{
+				Actor:      claims.Subject,
+				Action:     audit.ActionUserCreate,
+				TargetType: audit.TargetUser,
+				TargetID:   usr.ID,
+				After:      usr,
+			},
+			{
+				Actor:      claims.Subject,
+				Action:     audit.ActionProductCreate,
+				TargetType: audit.TargetProduct,
+				TargetID:   prd.ID,
+				After:      prd,
+			},
+		}
+		for _, ne := range nes {
+			if err := c.audit.Tran(tx).Record(ctx, ne, now); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return user.User{}, product.Product{}, fmt.Errorf("create with product: %w", err)
+	}
+
+	return usr, prd, nil
+}
+
+// Update modifies a user and records the changes in the audit log. A new
+// password is recorded as an event of its own since its hash is never shown.
 func (c Core) Update(ctx context.Context, claims auth.Claims, userID string, uu user.UpdateUserDTO, now time.Time) error {
+	tran := func(tx sqlx.ExtContext) error {
+		before, err := c.owner(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+
+		if err := c.user.Tran(tx).Update(ctx, claims, userID, uu, now); err != nil {
+			return err
+		}
+
+		after, err := c.owner(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
 
-	if err := c.user.Update(ctx, claims, userID, uu, now); err != nil {
+		ne := audit.NewEvent{
+			Actor:      claims.Subject,
+			Action:     audit.ActionUserUpdate,
+			TargetType: audit.TargetUser,
+			TargetID:   userID,
+			Before:     before,
+			After:      after,
+		}
+		if err := c.audit.Tran(tx).Record(ctx, ne, now); err != nil {
+			return err
+		}
+
+		if uu.Password != nil {
+			ne := audit.NewEvent{
+				Actor:      claims.Subject,
+				Action:     audit.ActionUserPassword,
+				TargetType: audit.TargetUser,
+				TargetID:   userID,
+			}
+			return c.audit.Tran(tx).Record(ctx, ne, now)
+		}
+
+		return nil
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
 		return fmt.Errorf("update: %w", err)
 	}
 
 	return nil
 }
 
-func (c Core) Delete(ctx context.Context, claims auth.Claims, userID string) error {
+// Delete removes a user and records what was removed in the audit log.
+func (c Core) Delete(ctx context.Context, claims auth.Claims, userID string, now time.Time) error {
+	tran := func(tx sqlx.ExtContext) error {
+		before, err := c.owner(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+
+		if err := c.user.Tran(tx).Delete(ctx, claims, userID); err != nil {
+			return err
+		}
 
-	if err := c.user.Delete(ctx, claims, userID); err != nil {
+		ne := audit.NewEvent{
+			Actor:      claims.Subject,
+			Action:     audit.ActionUserDelete,
+			TargetType: audit.TargetUser,
+			TargetID:   userID,
+			Before:     before,
+		}
+		return c.audit.Tran(tx).Record(ctx, ne, now)
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
 		return fmt.Errorf("delete: %w", err)
 	}
 
 	return nil
 }
 
-func (c Core) GetAll(ctx context.Context, pageNumber int, rowsPerPage int) ([]user.User, error) {
+func (c Core) GetAll(ctx context.Context, qf user.QueryFilter) ([]user.User, string, error) {
 
-	users, err := c.user.GetAll(ctx, pageNumber, rowsPerPage)
+	users, next, err := c.user.GetAll(ctx, qf)
 	if err != nil {
-		return nil, fmt.Errorf("get all users: %w", err)
+		return nil, "", fmt.Errorf("get all users: %w", err)
 	}
 
-	return users, nil
+	return users, next, nil
 }
 
 func (c Core) GetByID(ctx context.Context, claims auth.Claims, userID string) (user.User, error) {
@@ -71,12 +234,356 @@ func (c Core) GetByID(ctx context.Context, claims auth.Claims, userID string) (u
 	return usr, nil
 }
 
-func (c Core) Authenticate(ctx context.Context, now time.Time, email, password string) (auth.Claims, error) {
+// Authenticate verifies the credentials of a user. Unknown emails, wrong
+// passwords and locked accounts all fail with ErrAuthenticationFailure. When
+// the user has to provide a one-time password, no user is returned but a
+// challenge valid for challengeTTL, to complete with CompleteChallenge.
+func (c Core) Authenticate(ctx context.Context, now time.Time, email, password string, lockout user.Lockout, challengeTTL time.Duration) (user.User, Challenge, error) {
+
+	usr, err := c.user.Authenticate(ctx, now, email, password, lockout)
+	if err != nil {
+		if errors.Is(err, user.ErrLocked) {
+			err = database.ErrAuthenticationFailure
+		}
+		return user.User{}, Challenge{}, fmt.Errorf("authenticate: %w", err)
+	}
+
+	if !usr.MFAEnabled && !usr.MFARequired {
+		return usr, Challenge{}, nil
+	}
+
+	value, err := c.issueUserToken(ctx, usr.ID, usr.Email, token.PurposeMFA, now, challengeTTL)
+	if err != nil {
+		return user.User{}, Challenge{}, fmt.Errorf("authenticate: %w", err)
+	}
+
+	ch := Challenge{
+		Token:     value,
+		ExpiresAt: now.Add(challengeTTL),
+		Enroll:    !usr.MFAEnabled,
+	}
+
+	return user.User{}, ch, nil
+}
 
-	claims, err := c.user.Authenticate(ctx, now, email, password)
+// Permissions returns the permissions granted by the roles, to be embedded
+// in the access tokens of a user.
+func (c Core) Permissions(ctx context.Context, roles []string) ([]string, error) {
+	perms, err := c.role.Permissions(ctx, roles)
 	if err != nil {
-		return auth.Claims{}, fmt.Errorf("authenticate: %w", err)
+		return nil, fmt.Errorf("permissions: %w", err)
+	}
+
+	return perms, nil
+}
+
+// IssueRefreshToken creates a new refresh token for the user valid for ttl.
+// The issuance is recorded in the audit log.
+func (c Core) IssueRefreshToken(ctx context.Context, userID string, now time.Time, ttl time.Duration) (string, error) {
+	var rt string
+
+	tran := func(tx sqlx.ExtContext) error {
+		var err error
+		rt, err = c.token.Tran(tx).CreateRefresh(ctx, userID, now, ttl)
+		if err != nil {
+			return err
+		}
+
+		ne := audit.NewEvent{
+			Actor:      userID,
+			Action:     audit.ActionTokenIssue,
+			TargetType: audit.TargetUser,
+			TargetID:   userID,
+		}
+		return c.audit.Tran(tx).Record(ctx, ne, now)
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return "", fmt.Errorf("issue refresh token: %w", err)
+	}
+
+	return rt, nil
+}
+
+// Refresh consumes a refresh token and rotates it. It returns the owner of
+// the token, so a new access token can be issued, and the refresh token that
+// replaces the consumed one.
+func (c Core) Refresh(ctx context.Context, refreshToken string, now time.Time, ttl time.Duration) (user.User, string, error) {
+	var usr user.User
+	var next string
+	var reused token.RefreshToken
+
+	tran := func(tx sqlx.ExtContext) error {
+		rt, err := c.token.Tran(tx).UseRefresh(ctx, refreshToken, now)
+		if err != nil {
+			reused = rt
+			return err
+		}
+
+		owner := auth.Claims{
+			StandardClaims: jwt.StandardClaims{
+				Subject: rt.UserID,
+			},
+		}
+
+		usr, err = c.user.Tran(tx).GetByID(ctx, owner, rt.UserID)
+		if err != nil {
+			return err
+		}
+
+		next, err = c.token.Tran(tx).CreateRefresh(ctx, usr.ID, now, ttl)
+		if err != nil {
+			return err
+		}
+
+		ne := audit.NewEvent{
+			Actor:      usr.ID,
+			Action:     audit.ActionTokenRefresh,
+			TargetType: audit.TargetUser,
+			TargetID:   usr.ID,
+		}
+		return c.audit.Tran(tx).Record(ctx, ne, now)
+	}
+
+	err := database.WithinTran(ctx, c.log, c.db, tran)
+	switch {
+	case errors.Is(err, token.ErrRefreshReused):
+
+		// The transaction was rolled back, the revocation gets one of its own
+		// so the stolen token family is killed for good.
+		if err := c.token.RevokeAllRefresh(ctx, reused.UserID, now); err != nil {
+			return user.User{}, "", fmt.Errorf("refresh: revoking reused refresh token: %w", err)
+		}
+		return user.User{}, "", fmt.Errorf("refresh: %w", err)
+	case err != nil:
+		return user.User{}, "", fmt.Errorf("refresh: %w", err)
+	}
+
+	return usr, next, nil
+}
+
+// Revoke kills the access token described by the claims and, when provided,
+// the refresh token of the same user.
+func (c Core) Revoke(ctx context.Context, claims auth.Claims, refreshToken string, now time.Time) error {
+	tran := func(tx sqlx.ExtContext) error {
+		if claims.Id != "" {
+			expires := time.Unix(claims.ExpiresAt, 0)
+			if err := c.token.Tran(tx).RevokeAccess(ctx, claims.Id, expires, now); err != nil {
+				return err
+			}
+		}
+
+		if refreshToken != "" {
+			if err := c.token.Tran(tx).RevokeRefresh(ctx, claims.Subject, refreshToken, now); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return fmt.Errorf("revoke: %w", err)
+	}
+
+	return nil
+}
+
+// resetTimeout bounds the background work of a password reset request.
+const resetTimeout = time.Minute
+
+// RequestPasswordReset mails a password reset token valid for ttl to the
+// user with the email. The work runs in the background and its failures are
+// only logged, so neither the result nor the response time reveals which
+// accounts exist.
+func (c Core) RequestPasswordReset(ctx context.Context, email string, now time.Time, ttl time.Duration) error {
+	ctx = webapp.Detach(ctx)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, resetTimeout)
+		defer cancel()
+
+		if err := c.requestPasswordReset(ctx, email, now, ttl); err != nil {
+			c.log.Errorw("request password reset", "traceid", webapp.GetTraceID(ctx), "ERROR", err)
+		}
+	}()
+
+	return nil
+}
+
+// requestPasswordReset issues and mails the reset token when a user has the
+// email.
+func (c Core) requestPasswordReset(ctx context.Context, email string, now time.Time, ttl time.Duration) error {
+	usr, err := c.user.QueryByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	value, err := c.issueUserToken(ctx, usr.ID, usr.Email, token.PurposeReset, now, ttl)
+	if err != nil {
+		return err
+	}
+
+	msg := mailer.Message{
+		To:      usr.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nUse this token to choose a new password, it expires in %s:\n\n%s\n\n"+
+			"If you didn't ask to reset your password, you can ignore this email.\n", usr.Name, ttl, value),
+	}
+
+	if err := c.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("userid[%s]: %w", usr.ID, err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets the password of the owner of a reset token. The token
+// is consumed, any lockout is lifted and every refresh token of the user is
+// revoked.
+func (c Core) ResetPassword(ctx context.Context, rp user.ResetPasswordDTO, now time.Time) error {
+	if err := validate.Check(rp); err != nil {
+		return fmt.Errorf("reset password: validating data: %w", err)
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		ut, err := c.token.Tran(tx).UseUserToken(ctx, token.PurposeReset, rp.Token, now)
+		if err != nil {
+			return err
+		}
+
+		usr, err := c.owner(ctx, tx, ut.UserID)
+		if err != nil {
+			return err
+		}
+
+		// The token was mailed to an address the user no longer has.
+		if usr.Email != ut.Email {
+			return token.ErrInvalidToken
+		}
+
+		if err := c.user.Tran(tx).SetPassword(ctx, usr.ID, rp.Password, now); err != nil {
+			return err
+		}
+
+		ne := audit.NewEvent{
+			Actor:      usr.ID,
+			Action:     audit.ActionUserPassword,
+			TargetType: audit.TargetUser,
+			TargetID:   usr.ID,
+		}
+		if err := c.audit.Tran(tx).Record(ctx, ne, now); err != nil {
+			return err
+		}
+
+		return c.token.Tran(tx).RevokeAllRefresh(ctx, usr.ID, now)
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return fmt.Errorf("reset password: %w", err)
+	}
+
+	return nil
+}
+
+// RequestVerification mails an email verification token valid for ttl to the
+// user described by the claims. Nothing is sent when the email is already
+// verified.
+func (c Core) RequestVerification(ctx context.Context, claims auth.Claims, now time.Time, ttl time.Duration) error {
+	usr, err := c.user.GetByID(ctx, claims, claims.Subject)
+	if err != nil {
+		return fmt.Errorf("request verification: %w", err)
+	}
+
+	if usr.EmailVerified {
+		return nil
+	}
+
+	value, err := c.issueUserToken(ctx, usr.ID, usr.Email, token.PurposeVerify, now, ttl)
+	if err != nil {
+		return fmt.Errorf("request verification: %w", err)
+	}
+
+	msg := mailer.Message{
+		To:      usr.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hello %s,\n\nUse this token to verify your email, it expires in %s:\n\n%s\n",
+			usr.Name, ttl, value),
+	}
+
+	if err := c.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("request verification: %w", err)
+	}
+
+	return nil
+}
+
+// VerifyEmail consumes a verification token and marks the email it was
+// mailed to as verified, as long as the user still has that email. The
+// verification is recorded in the audit log.
+func (c Core) VerifyEmail(ctx context.Context, value string, now time.Time) error {
+	tran := func(tx sqlx.ExtContext) error {
+		ut, err := c.token.Tran(tx).UseUserToken(ctx, token.PurposeVerify, value, now)
+		if err != nil {
+			return err
+		}
+
+		if err := c.user.Tran(tx).VerifyEmail(ctx, ut.UserID, ut.Email, now); err != nil {
+			if errors.Is(err, database.ErrDBNotFound) {
+				return token.ErrInvalidToken
+			}
+			return err
+		}
+
+		ne := audit.NewEvent{
+			Actor:      ut.UserID,
+			Action:     audit.ActionUserVerifyEmail,
+			TargetType: audit.TargetUser,
+			TargetID:   ut.UserID,
+		}
+		return c.audit.Tran(tx).Record(ctx, ne, now)
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return fmt.Errorf("verify email: %w", err)
+	}
+
+	return nil
+}
+
+// issueUserToken creates a user token for the purpose, invalidating the ones
+// previously issued for it.
+func (c Core) issueUserToken(ctx context.Context, userID string, email string, purpose string, now time.Time, ttl time.Duration) (string, error) {
+	var value string
+
+	tran := func(tx sqlx.ExtContext) error {
+		if err := c.token.Tran(tx).RevokeUserTokens(ctx, userID, purpose, now); err != nil {
+			return err
+		}
+
+		var err error
+		value, err = c.token.Tran(tx).CreateUserToken(ctx, userID, email, purpose, now, ttl)
+		return err
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
+// owner returns the user acting as its own owner, for flows authenticated by
+// a token instead of claims and for snapshots taken for the audit log.
+func (c Core) owner(ctx context.Context, tx sqlx.ExtContext, userID string) (user.User, error) {
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Subject: userID,
+		},
 	}
 
-	return claims, nil
+	return c.user.Tran(tx).GetByID(ctx, claims, userID)
 }
@@ -0,0 +1,138 @@
+package user_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/core/user"
+	"github.com/dimashiro/service/business/data/store/audit"
+	"github.com/dimashiro/service/business/data/store/product"
+	userStorage "github.com/dimashiro/service/business/data/store/user"
+	"github.com/dimashiro/service/business/data/tests"
+	"github.com/dimashiro/service/business/database"
+	"github.com/dimashiro/service/business/mailer"
+	"github.com/dimashiro/service/foundation/docker"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = tests.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer tests.StopDB(c)
+
+	m.Run()
+}
+
+func TestRefresh(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t, c, "testcoreuser")
+	t.Cleanup(teardown)
+
+	core := user.NewCore(log, db, mailer.NewLog(log), nil)
+
+	t.Log("Given the need to rotate refresh tokens.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a consumed refresh token is reused.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+			// User Gopher from the seed data.
+			const userID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+
+			first, err := core.IssueRefreshToken(ctx, userID, now, time.Hour)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to issue a refresh token : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to issue a refresh token.", tests.Success, testID)
+
+			_, second, err := core.Refresh(ctx, first, now, time.Hour)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to rotate the refresh token : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to rotate the refresh token.", tests.Success, testID)
+
+			if _, _, err := core.Refresh(ctx, first, now, time.Hour); !errors.Is(err, database.ErrAuthenticationFailure) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to reuse the refresh token : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to reuse the refresh token.", tests.Success, testID)
+
+			if _, _, err := core.Refresh(ctx, second, now, time.Hour); !errors.Is(err, database.ErrAuthenticationFailure) {
+				t.Fatalf("\t%s\tTest %d:\tShould revoke the newer refresh token on reuse : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould revoke the newer refresh token on reuse.", tests.Success, testID)
+		}
+	}
+}
+
+func TestCreateWithProduct(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t, c, "testcoreuserproduct")
+	t.Cleanup(teardown)
+
+	core := user.NewCore(log, db, mailer.NewLog(log), nil)
+
+	t.Log("Given the need to create a user with an initial product.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen creating both in one call.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+			// Admin Gopher from the seed data.
+			claims := auth.Claims{
+				StandardClaims: jwt.StandardClaims{
+					Subject: "5cf37266-3473-4006-984f-9325122678b7",
+				},
+			}
+
+			nu := userStorage.NewUserDTO{
+				Name:            "Bill Kennedy",
+				Email:           "bill@ardanlabs.com",
+				Roles:           []string{auth.RoleUser},
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			}
+			np := product.NewProductDTO{
+				Name:     "Comic Books",
+				Cost:     10,
+				Quantity: 55,
+			}
+
+			u, prd, err := core.CreateWithProduct(ctx, claims, nu, np, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create the user and the product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create the user and the product.", tests.Success, testID)
+
+			if prd.UserID != u.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould make the user the owner of the product : got %s.", tests.Failed, testID, prd.UserID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould make the user the owner of the product.", tests.Success, testID)
+
+			evts, _, err := audit.NewStore(log, db).GetAll(ctx, audit.QueryFilter{Actor: claims.Subject})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query the audit log : %s.", tests.Failed, testID, err)
+			}
+
+			recorded := make(map[string]string)
+			for _, evt := range evts {
+				recorded[evt.Action] = evt.TargetID
+			}
+			if recorded[audit.ActionUserCreate] != u.ID || recorded[audit.ActionProductCreate] != prd.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould record both creations in the audit log : got %v.", tests.Failed, testID, recorded)
+			}
+			t.Logf("\t%s\tTest %d:\tShould record both creations in the audit log.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package schema
+
+import (
+	"context"
+	"database/sql/driver"
+	_ "embed"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ardanlabs/darwin"
+	"github.com/dimashiro/service/business/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// downDoc holds the optional down migrations, paired with the migrations of
+// the schema by version.
+//
+//go:embed sql/down.sql
+var downDoc string
+
+// Migration is a migration of the schema along with its optional down
+// migration.
+type Migration struct {
+	Version     float64
+	Description string
+	Script      string
+	Checksum    string
+	Down        string
+}
+
+// MigrationStatus is the state of a migration in the database. A migration
+// applied with another checksum than the one of its script has drifted: its
+// script was edited after it was applied.
+type MigrationStatus struct {
+	Migration
+	Applied         bool
+	AppliedAt       time.Time
+	AppliedChecksum string
+	Drift           bool
+}
+
+// DriftError is returned when the applied migrations don't match the ones
+// of the schema, either because their script was edited or because they
+// were removed.
+type DriftError struct {
+	Edited  []float64
+	Removed []float64
+}
+
+// Error implements the error interface.
+func (de DriftError) Error() string {
+	var parts []string
+	if len(de.Edited) > 0 {
+		parts = append(parts, fmt.Sprintf("applied migrations %v were edited", de.Edited))
+	}
+	if len(de.Removed) > 0 {
+		parts = append(parts, fmt.Sprintf("applied migrations %v were removed", de.Removed))
+	}
+	return "schema drift: " + strings.Join(parts, ", ")
+}
+
+// Migrations returns the migrations of the schema ordered by version.
+func Migrations() ([]Migration, error) {
+	ups := darwin.ParseMigrations(schemaDoc)
+	if ups == nil {
+		return nil, fmt.Errorf("parsing migrations")
+	}
+
+	downs := darwin.ParseMigrations(downDoc)
+	if downs == nil {
+		return nil, fmt.Errorf("parsing down migrations")
+	}
+
+	byVersion := make(map[float64]string, len(downs))
+	for _, d := range downs {
+		byVersion[d.Version] = d.Script
+	}
+
+	migs := make([]Migration, len(ups))
+	for i, up := range ups {
+		migs[i] = Migration{
+			Version:     up.Version,
+			Description: up.Description,
+			Script:      up.Script,
+			Checksum:    up.Checksum(),
+			Down:        byVersion[up.Version],
+		}
+		delete(byVersion, up.Version)
+	}
+
+	for version := range byVersion {
+		return nil, fmt.Errorf("down migration %v has no migration", version)
+	}
+
+	sort.Slice(migs, func(i, j int) bool { return migs[i].Version < migs[j].Version })
+
+	return migs, nil
+}
+
+// Status returns the state of every migration of the schema in the database.
+func Status(ctx context.Context, db *sqlx.DB) ([]MigrationStatus, error) {
+	migs, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, len(migs))
+	for i, mig := range migs {
+		status[i] = MigrationStatus{
+			Migration: mig,
+		}
+
+		if rec, exists := applied[mig.Version]; exists {
+			status[i].Applied = true
+			status[i].AppliedAt = rec.AppliedAt
+			status[i].AppliedChecksum = rec.Checksum
+			status[i].Drift = rec.Checksum != mig.Checksum
+		}
+	}
+
+	return status, nil
+}
+
+// Validate checks the applied migrations match the ones of the schema. It
+// fails with a DriftError when they don't. Applied migrations newer than the
+// latest one of the schema come from a newer release and are only logged,
+// the migrations are expected to stay compatible with the previous release.
+func Validate(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB) error {
+	migs, err := Migrations()
+	if err != nil {
+		return err
+	}
+
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	de, newer := checkDrift(migs, applied)
+	if len(newer) > 0 {
+		log.Warnw("schema", "status", "applied migrations are newer than this release", "versions", newer)
+	}
+
+	if len(de.Edited) > 0 || len(de.Removed) > 0 {
+		return de
+	}
+
+	return nil
+}
+
+// checkDrift compares the applied migrations with the ones of the schema. It
+// returns the drift along with the applied versions newer than the latest
+// migration of the schema.
+func checkDrift(migs []Migration, applied map[float64]darwin.MigrationRecord) (DriftError, []float64) {
+	var latest float64
+	known := make(map[float64]bool, len(migs))
+
+	var de DriftError
+	for _, mig := range migs {
+		if rec, exists := applied[mig.Version]; exists && rec.Checksum != mig.Checksum {
+			de.Edited = append(de.Edited, mig.Version)
+		}
+		if mig.Version > latest {
+			latest = mig.Version
+		}
+		known[mig.Version] = true
+	}
+
+	var newer []float64
+	for version := range applied {
+		switch {
+		case known[version]:
+		case version > latest:
+			newer = append(newer, version)
+		default:
+			de.Removed = append(de.Removed, version)
+		}
+	}
+	sort.Float64s(de.Removed)
+	sort.Float64s(newer)
+
+	return de, newer
+}
+
+// Pending returns the migrations Migrate would apply, in order.
+func Pending(ctx context.Context, db *sqlx.DB) ([]Migration, error) {
+	status, err := Status(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like Migrate, only the migrations newer than the last applied one are
+	// pending.
+	var last float64
+	for _, s := range status {
+		if s.Applied && s.Version > last {
+			last = s.Version
+		}
+	}
+
+	var pending []Migration
+	for _, s := range status {
+		if s.Version > last {
+			pending = append(pending, s.Migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// RollbackPlan returns the migrations Rollback would revert to bring the
+// schema back to the version, the most recent first. It fails when one of
+// them has no down migration.
+func RollbackPlan(ctx context.Context, db *sqlx.DB, version float64) ([]Migration, error) {
+	status, err := Status(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan []Migration
+	for i := len(status) - 1; i >= 0; i-- {
+		s := status[i]
+		if !s.Applied || s.Version <= version {
+			continue
+		}
+		if s.Down == "" {
+			return nil, fmt.Errorf("migration %v has no down migration", s.Version)
+		}
+		plan = append(plan, s.Migration)
+	}
+
+	return plan, nil
+}
+
+// Rollback reverts the applied migrations newer than the version, the most
+// recent first. Each down migration runs in a transaction along with the
+// removal of its record, so a failure leaves the schema at a known version.
+// It refuses to run when a newer release migrated the database.
+func Rollback(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, version float64) ([]Migration, error) {
+	if err := database.StatusCheck(ctx, db); err != nil {
+		return nil, fmt.Errorf("status check database: %w", err)
+	}
+
+	if err := Validate(ctx, log, db); err != nil {
+		return nil, err
+	}
+
+	// The migrations of a newer release would be left on top of the ones
+	// reverted, only that release can revert them.
+	newer, err := newerMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if len(newer) > 0 {
+		return nil, fmt.Errorf("applied migrations %v are newer than this release", newer)
+	}
+
+	plan, err := RollbackPlan(ctx, db, version)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, mig := range plan {
+		if err := revert(ctx, log, db, mig); err != nil {
+			return plan[:i], fmt.Errorf("reverting migration %v: %w", mig.Version, err)
+		}
+	}
+
+	return plan, nil
+}
+
+// ErrOutdated is returned when the migrations of the schema are not all
+// applied to the database.
+var ErrOutdated = errors.New("schema is outdated")
+
+// CheckVersion checks the latest migration of the schema is applied. A
+// database migrated further by a newer release is fine, the migrations are
+// expected to stay compatible with the previous release.
+func CheckVersion(ctx context.Context, db *sqlx.DB) error {
+	migs, err := Migrations()
+	if err != nil {
+		return err
+	}
+
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	latest := migs[len(migs)-1].Version
+	if _, exists := applied[latest]; !exists {
+		return fmt.Errorf("%w: migration %v is not applied", ErrOutdated, latest)
+	}
+
+	return nil
+}
+
+// lockID identifies the advisory lock held while migrating.
+const lockID = 7_301_946_553_118
+
+// MigrateLocked applies the pending migrations while holding an advisory
+// lock, so replicas starting together migrate the database once: the others
+// wait for the lock and find nothing left to apply. The lock is held by a
+// connection of its own, the pool must allow at least two.
+func MigrateLocked(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB) (err error) {
+	if err := database.StatusCheck(ctx, db); err != nil {
+		return fmt.Errorf("status check database: %w", err)
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("acquiring migration lock: %w", err)
+	}
+
+	defer func() {
+		// The lock belongs to the session, when it can't be released the
+		// connection is discarded rather than returned to the pool with it.
+		if _, unlockErr := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID); unlockErr != nil {
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+			if err == nil {
+				err = fmt.Errorf("releasing migration lock: %w", unlockErr)
+			}
+		}
+	}()
+
+	return Migrate(ctx, log, db)
+}
+
+// =============================================================================
+
+// revert runs the down migration and removes the record of the migration.
+func revert(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, mig Migration) error {
+	tran := func(tx sqlx.ExtContext) error {
+		if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+			return err
+		}
+
+		// The versions are stored as REAL, compare them at that precision.
+		const q = `DELETE FROM darwin_migrations WHERE version = CAST($1 AS REAL)`
+		_, err := tx.ExecContext(ctx, q, mig.Version)
+		return err
+	}
+
+	return database.WithinTran(ctx, log, db, tran)
+}
+
+// newerMigrations returns the applied versions newer than the latest
+// migration of the schema.
+func newerMigrations(ctx context.Context, db *sqlx.DB) ([]float64, error) {
+	migs, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	_, newer := checkDrift(migs, applied)
+	return newer, nil
+}
+
+// appliedMigrations returns the records of the applied migrations by version.
+// There are none when the schema was never migrated.
+func appliedMigrations(ctx context.Context, db *sqlx.DB) (map[float64]darwin.MigrationRecord, error) {
+	var exists bool
+	const q = `SELECT to_regclass('darwin_migrations') IS NOT NULL`
+	if err := db.QueryRowContext(ctx, q).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("checking migrations table: %w", err)
+	}
+
+	applied := make(map[float64]darwin.MigrationRecord)
+	if !exists {
+		return applied, nil
+	}
+
+	driver, err := darwin.NewGenericDriver(db.DB, darwin.PostgresDialect{})
+	if err != nil {
+		return nil, fmt.Errorf("construct darwin driver: %w", err)
+	}
+
+	recs, err := driver.All()
+	if err != nil {
+		return nil, fmt.Errorf("selecting applied migrations: %w", err)
+	}
+
+	for _, rec := range recs {
+		applied[rec.Version] = rec
+	}
+
+	return applied, nil
+}
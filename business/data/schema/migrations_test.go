@@ -0,0 +1,55 @@
+package schema
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ardanlabs/darwin"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestCheckDrift(t *testing.T) {
+	migs := []Migration{
+		{Version: 1.1, Checksum: "a"},
+		{Version: 1.2, Checksum: "b"},
+		{Version: 1.4, Checksum: "c"},
+	}
+
+	tt := []struct {
+		name    string
+		applied map[float64]darwin.MigrationRecord
+		edited  []float64
+		removed []float64
+		newer   []float64
+	}{
+		{"every migration applied", map[float64]darwin.MigrationRecord{1.1: {Checksum: "a"}, 1.2: {Checksum: "b"}, 1.4: {Checksum: "c"}}, nil, nil, nil},
+		{"an edited migration", map[float64]darwin.MigrationRecord{1.1: {Checksum: "a"}, 1.2: {Checksum: "x"}}, []float64{1.2}, nil, nil},
+		{"a removed migration", map[float64]darwin.MigrationRecord{1.1: {Checksum: "a"}, 1.3: {Checksum: "x"}}, nil, []float64{1.3}, nil},
+		{"migrations of a newer release", map[float64]darwin.MigrationRecord{1.1: {Checksum: "a"}, 1.4: {Checksum: "c"}, 1.5: {}, 1.6: {}}, nil, nil, []float64{1.5, 1.6}},
+	}
+
+	t.Log("Given the need to detect the applied migrations that drifted.")
+	{
+		for testID, tc := range tt {
+			t.Logf("\tTest %d:\tWhen checking %s.", testID, tc.name)
+			{
+				de, newer := checkDrift(migs, tc.applied)
+
+				if fmt.Sprint(de.Edited) != fmt.Sprint(tc.edited) || fmt.Sprint(de.Removed) != fmt.Sprint(tc.removed) {
+					t.Fatalf("\t%s\tTest %d:\tShould report edited %v and removed %v : got %+v.", failed, testID, tc.edited, tc.removed, de)
+				}
+				t.Logf("\t%s\tTest %d:\tShould report edited %v and removed %v.", success, testID, tc.edited, tc.removed)
+
+				if fmt.Sprint(newer) != fmt.Sprint(tc.newer) {
+					t.Fatalf("\t%s\tTest %d:\tShould report the newer versions %v : got %v.", failed, testID, tc.newer, newer)
+				}
+				t.Logf("\t%s\tTest %d:\tShould report the newer versions %v.", success, testID, tc.newer)
+			}
+		}
+	}
+}
@@ -9,24 +9,38 @@ import (
 	"github.com/ardanlabs/darwin"
 	"github.com/dimashiro/service/business/database"
 	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
 )
 
 var (
 	//go:embed sql/schema.sql
 	schemaDoc string
 
-	//go:embed sql/seed.sql
-	seedDoc string
-
 	//go:embed sql/delete.sql
 	deleteDoc string
 )
 
-func Migrate(ctx context.Context, db *sqlx.DB) error {
+// Migrate applies the pending migrations. It refuses to run when the applied
+// migrations drifted from the ones of the schema. There is nothing to apply
+// when a newer release already migrated the database.
+func Migrate(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB) error {
 	if err := database.StatusCheck(ctx, db); err != nil {
 		return fmt.Errorf("status check database: %w", err)
 	}
 
+	if err := Validate(ctx, log, db); err != nil {
+		return err
+	}
+
+	// darwin rejects the versions it doesn't know.
+	newer, err := newerMigrations(ctx, db)
+	if err != nil {
+		return err
+	}
+	if len(newer) > 0 {
+		return nil
+	}
+
 	driver, err := darwin.NewGenericDriver(db.DB, darwin.PostgresDialect{})
 	if err != nil {
 		return fmt.Errorf("construct darwin driver: %w", err)
@@ -51,23 +65,3 @@ func DeleteAll(db *sqlx.DB) error {
 
 	return tx.Commit()
 }
-
-func Seed(ctx context.Context, db *sqlx.DB) error {
-	if err := database.StatusCheck(ctx, db); err != nil {
-		return fmt.Errorf("status check database: %w", err)
-	}
-
-	tx, err := db.Begin()
-	if err != nil {
-		return err
-	}
-
-	if _, err := tx.Exec(seedDoc); err != nil {
-		if err := tx.Rollback(); err != nil {
-			return err
-		}
-		return err
-	}
-
-	return tx.Commit()
-}
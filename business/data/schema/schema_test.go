@@ -0,0 +1,58 @@
+package schema_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dimashiro/service/business/data/schema"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestMigrations(t *testing.T) {
+	t.Log("Given the need to pair the migrations with their down migrations.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen parsing the embedded migrations.", testID)
+		{
+			migs, err := schema.Migrations()
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to parse the migrations : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to parse the migrations.", success, testID)
+
+			for i, mig := range migs {
+				if i > 0 && mig.Version <= migs[i-1].Version {
+					t.Fatalf("\t%s\tTest %d:\tShould have increasing versions : %v after %v.", failed, testID, mig.Version, migs[i-1].Version)
+				}
+				if mig.Checksum == "" || mig.Script == "" {
+					t.Fatalf("\t%s\tTest %d:\tShould have a script and its checksum : %v.", failed, testID, mig.Version)
+				}
+				if mig.Down == "" {
+					t.Fatalf("\t%s\tTest %d:\tShould have a down migration : %v.", failed, testID, mig.Version)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould have increasing versions, each with a down migration.", success, testID)
+		}
+	}
+}
+
+func TestSeedSets(t *testing.T) {
+	t.Log("Given the need to seed databases with named sets.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen listing the seed sets.", testID)
+		{
+			got := strings.Join(schema.SeedSets(), ",")
+			exp := strings.Join([]string{schema.SeedDemo, schema.SeedDev, schema.SeedLoadTest}, ",")
+			if got != exp {
+				t.Fatalf("\t%s\tTest %d:\tShould list the embedded and generated sets : got %s, exp %s.", failed, testID, got, exp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould list the embedded and generated sets.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/database"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+//go:embed sql/seed/*.sql
+var seedFS embed.FS
+
+// Set of seed sets. The dev and demo sets are embedded from sql/seed, the
+// load test set is generated.
+const (
+	SeedDev      = "dev"
+	SeedDemo     = "demo"
+	SeedLoadTest = "loadtest"
+)
+
+// Set of error variables for seeding.
+var (
+	ErrProduction  = errors.New("database is flagged as production")
+	ErrUnknownSeed = errors.New("unknown seed set")
+)
+
+// EnvironmentSetting is the database setting naming its environment. Seeding
+// a database flagged as production is refused, flag it with:
+//
+//	ALTER DATABASE <name> SET retail.environment = 'production';
+const EnvironmentSetting = "retail.environment"
+
+// SeedSets returns the names of the seed sets.
+func SeedSets() []string {
+	sets := []string{SeedLoadTest}
+
+	files, _ := fs.Glob(seedFS, "sql/seed/*.sql")
+	for _, file := range files {
+		sets = append(sets, strings.TrimSuffix(path.Base(file), ".sql"))
+	}
+	sort.Strings(sets)
+
+	return sets
+}
+
+// Seed adds the named seed set to the database. Seeding is idempotent, the
+// rows that already exist are left untouched. The load test set is generated
+// with the default sizes.
+func Seed(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, set string) error {
+	if set == SeedLoadTest {
+		return GenerateLoadTest(ctx, log, db, DefaultLoadTest)
+	}
+
+	doc, err := seedFS.ReadFile("sql/seed/" + set + ".sql")
+	if err != nil {
+		return fmt.Errorf("%w %q, expected one of %v", ErrUnknownSeed, set, SeedSets())
+	}
+
+	if err := checkSeedable(ctx, db); err != nil {
+		return err
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		_, err := tx.ExecContext(ctx, string(doc))
+		return err
+	}
+
+	if err := database.WithinTran(ctx, log, db, tran); err != nil {
+		return fmt.Errorf("seeding %s: %w", set, err)
+	}
+
+	return nil
+}
+
+// LoadTest sets the size of the generated load test set.
+type LoadTest struct {
+	Users    int
+	Products int
+	Sales    int
+}
+
+// DefaultLoadTest is the size of the load test set used by Seed.
+var DefaultLoadTest = LoadTest{
+	Users:    1_000,
+	Products: 5_000,
+	Sales:    20_000,
+}
+
+// GenerateLoadTest adds a large synthetic dataset for performance testing. The
+// data is derived from a fixed seed, so it's the same on every run and
+// seeding again only adds what is missing. Every user logs in with the
+// password gophers.
+func GenerateLoadTest(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, lt LoadTest) error {
+	if lt.Users < 1 || lt.Products < 0 || lt.Sales < 0 || (lt.Sales > 0 && lt.Products == 0) {
+		return fmt.Errorf("invalid load test size %+v", lt)
+	}
+
+	if err := checkSeedable(ctx, db); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("gophers"), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("generating password hash: %w", err)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	users := make([][]interface{}, lt.Users)
+	userIDs := make([]string, lt.Users)
+	for i := range users {
+		userIDs[i] = seedID("user", i)
+		created := start.Add(time.Duration(i) * time.Minute)
+		users[i] = []interface{}{
+			userIDs[i],
+			fmt.Sprintf("Load User %d", i),
+			fmt.Sprintf("load-user-%d@example.com", i),
+			pq.StringArray{auth.RoleUser},
+			string(hash),
+			true,
+			created,
+			created,
+		}
+	}
+
+	products := make([][]interface{}, lt.Products)
+	costs := make([]int, lt.Products)
+	for i := range products {
+		costs[i] = 1 + rnd.Intn(500)
+		created := start.Add(time.Duration(i) * time.Second)
+		products[i] = []interface{}{
+			seedID("product", i),
+			userIDs[rnd.Intn(lt.Users)],
+			fmt.Sprintf("Load Product %d", i),
+			costs[i],
+			rnd.Intn(1000),
+			created,
+			created,
+		}
+	}
+
+	sales := make([][]interface{}, lt.Sales)
+	for i := range sales {
+		p := rnd.Intn(lt.Products)
+		quantity := 1 + rnd.Intn(5)
+		sales[i] = []interface{}{
+			seedID("sale", i),
+			userIDs[rnd.Intn(lt.Users)],
+			seedID("product", p),
+			quantity,
+			quantity * costs[p],
+			start.Add(time.Duration(i) * time.Second),
+		}
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		if err := insertRows(ctx, tx, "users", []string{"user_id", "name", "email", "roles", "password_hash", "email_verified", "date_created", "date_updated"}, users); err != nil {
+			return err
+		}
+		if err := insertRows(ctx, tx, "products", []string{"product_id", "user_id", "name", "cost", "quantity", "date_created", "date_updated"}, products); err != nil {
+			return err
+		}
+		return insertRows(ctx, tx, "sales", []string{"sale_id", "user_id", "product_id", "quantity", "paid", "date_created"}, sales)
+	}
+
+	if err := database.WithinTran(ctx, log, db, tran); err != nil {
+		return fmt.Errorf("seeding %s: %w", SeedLoadTest, err)
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// checkSeedable checks the database is reachable and not flagged as
+// production.
+func checkSeedable(ctx context.Context, db *sqlx.DB) error {
+	if err := database.StatusCheck(ctx, db); err != nil {
+		return fmt.Errorf("status check database: %w", err)
+	}
+
+	var env sql.NullString
+	const q = `SELECT current_setting($1, true)`
+	if err := db.QueryRowContext(ctx, q, EnvironmentSetting).Scan(&env); err != nil {
+		return fmt.Errorf("checking environment: %w", err)
+	}
+
+	if strings.EqualFold(strings.TrimSpace(env.String), "production") {
+		return ErrProduction
+	}
+
+	return nil
+}
+
+// seedNamespace namespaces the ids of the generated rows.
+var seedNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://github.com/dimashiro/service/seed"))
+
+// seedID returns the id of the nth generated row of the kind. It's the same
+// on every run.
+func seedID(kind string, n int) string {
+	return uuid.NewSHA1(seedNamespace, []byte(fmt.Sprintf("%s-%d", kind, n))).String()
+}
+
+// insertRows inserts the rows in batches, skipping the ones that exist.
+func insertRows(ctx context.Context, tx sqlx.ExtContext, table string, columns []string, rows [][]interface{}) error {
+
+	// PostgreSQL accepts at most 65535 parameters per statement.
+	batch := 60_000 / len(columns)
+
+	for len(rows) > 0 {
+		n := batch
+		if n > len(rows) {
+			n = len(rows)
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES ", table, strings.Join(columns, ", "))
+
+		args := make([]interface{}, 0, n*len(columns))
+		for i, row := range rows[:n] {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteByte('(')
+			for j, v := range row {
+				if j > 0 {
+					b.WriteString(", ")
+				}
+				args = append(args, v)
+				fmt.Fprintf(&b, "$%d", len(args))
+			}
+			b.WriteByte(')')
+		}
+		b.WriteString(" ON CONFLICT DO NOTHING")
+
+		if _, err := tx.ExecContext(ctx, b.String(), args...); err != nil {
+			return fmt.Errorf("inserting %s: %w", table, err)
+		}
+
+		rows = rows[n:]
+	}
+
+	return nil
+}
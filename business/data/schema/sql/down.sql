@@ -0,0 +1,69 @@
+-- Version: 1.1
+-- Description: Drop table users
+DROP TABLE users;
+
+-- Version: 1.2
+-- Description: Drop table products
+DROP TABLE products;
+
+-- Version: 1.3
+-- Description: Drop table sales
+DROP TABLE sales;
+
+-- Version: 1.4
+-- Description: Drop table refresh_tokens
+DROP TABLE refresh_tokens;
+
+-- Version: 1.5
+-- Description: Drop table revoked_tokens
+DROP TABLE revoked_tokens;
+
+-- Version: 1.6
+-- Description: Drop tables roles and role_permissions
+DROP TABLE role_permissions;
+DROP TABLE roles;
+
+-- Version: 1.7
+-- Description: Remove the default roles
+DELETE FROM roles WHERE name IN ('ADMIN', 'USER');
+
+-- Version: 1.8
+-- Description: Stop tracking failed logins of users
+ALTER TABLE users
+	DROP COLUMN failed_logins,
+	DROP COLUMN locked_until;
+
+-- Version: 1.9
+-- Description: Remove email verification and password resets of users
+DROP TABLE user_tokens;
+
+ALTER TABLE users
+	DROP COLUMN email_verified;
+
+-- Version: 2.0
+-- Description: Remove multi-factor authentication of users
+DROP TABLE recovery_codes;
+
+ALTER TABLE users
+	DROP COLUMN mfa_secret,
+	DROP COLUMN mfa_enabled,
+	DROP COLUMN mfa_required,
+	DROP COLUMN mfa_last_step;
+
+-- Version: 2.1
+-- Description: Drop table api_keys
+DELETE FROM role_permissions WHERE permission = 'apikeys:manage';
+
+DROP TABLE api_keys;
+
+-- Version: 2.2
+-- Description: Drop table audit_events
+DELETE FROM role_permissions WHERE permission = 'audit:read';
+
+DROP TABLE audit_events;
+DROP FUNCTION audit_events_append_only();
+
+-- Version: 2.3
+-- Description: Allow truncating audit_events
+DROP TRIGGER audit_events_no_truncate ON audit_events;
+
@@ -40,4 +40,171 @@ CREATE TABLE sales (
 	PRIMARY KEY (sale_id),
 	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
 	FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
-);
\ No newline at end of file
+);
+-- Version: 1.4
+-- Description: Create table refresh_tokens
+CREATE TABLE refresh_tokens (
+	token_id     UUID,
+	user_id      UUID,
+	token_hash   TEXT UNIQUE,
+	date_created TIMESTAMP,
+	date_expires TIMESTAMP,
+	date_revoked TIMESTAMP NULL,
+
+	PRIMARY KEY (token_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Version: 1.5
+-- Description: Create table revoked_tokens
+CREATE TABLE revoked_tokens (
+	jti          TEXT,
+	date_expires TIMESTAMP,
+	date_revoked TIMESTAMP,
+
+	PRIMARY KEY (jti)
+);
+
+-- Version: 1.6
+-- Description: Create tables roles and role_permissions
+CREATE TABLE roles (
+	name        TEXT,
+	description TEXT,
+
+	PRIMARY KEY (name)
+);
+
+CREATE TABLE role_permissions (
+	role_name  TEXT,
+	permission TEXT,
+
+	PRIMARY KEY (role_name, permission),
+	FOREIGN KEY (role_name) REFERENCES roles(name) ON DELETE CASCADE
+);
+
+-- Version: 1.7
+-- Description: Add the default roles
+INSERT INTO roles (name, description) VALUES
+	('ADMIN', 'Manages users and every product'),
+	('USER', 'Sells and buys products')
+	ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role_name, permission) VALUES
+	('ADMIN', 'users:read'),
+	('ADMIN', 'users:write'),
+	('ADMIN', 'products:read'),
+	('ADMIN', 'products:write'),
+	('ADMIN', 'products:manage'),
+	('ADMIN', 'sales:create'),
+	('ADMIN', 'sales:read'),
+	('USER', 'products:read'),
+	('USER', 'products:write'),
+	('USER', 'sales:create'),
+	('USER', 'sales:read')
+	ON CONFLICT DO NOTHING;
+
+-- Version: 1.8
+-- Description: Track failed logins of users
+ALTER TABLE users
+	ADD COLUMN failed_logins INT NOT NULL DEFAULT 0,
+	ADD COLUMN locked_until  TIMESTAMP NULL;
+
+-- Version: 1.9
+-- Description: Verify emails and reset passwords of users
+ALTER TABLE users
+	ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE user_tokens (
+	token_id     UUID,
+	user_id      UUID,
+	purpose      TEXT,
+	email        TEXT,
+	token_hash   TEXT UNIQUE,
+	date_created TIMESTAMP,
+	date_expires TIMESTAMP,
+	date_used    TIMESTAMP NULL,
+
+	PRIMARY KEY (token_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Version: 2.0
+-- Description: Add multi-factor authentication of users
+ALTER TABLE users
+	ADD COLUMN mfa_secret    TEXT NULL,
+	ADD COLUMN mfa_enabled   BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN mfa_required  BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN mfa_last_step BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE recovery_codes (
+	code_id      UUID,
+	user_id      UUID,
+	code_hash    TEXT,
+	date_created TIMESTAMP,
+	date_used    TIMESTAMP NULL,
+
+	PRIMARY KEY (code_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Version: 2.1
+-- Description: Create table api_keys
+CREATE TABLE api_keys (
+	key_id         UUID,
+	user_id        UUID NULL,
+	service        TEXT NULL,
+	name           TEXT,
+	prefix         TEXT UNIQUE,
+	key_hash       TEXT,
+	scopes         TEXT[],
+	date_created   TIMESTAMP,
+	date_updated   TIMESTAMP,
+	date_expires   TIMESTAMP NULL,
+	date_last_used TIMESTAMP NULL,
+
+	PRIMARY KEY (key_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+	CHECK ((user_id IS NULL) <> (service IS NULL))
+);
+
+INSERT INTO role_permissions (role_name, permission) VALUES
+	('ADMIN', 'apikeys:manage')
+	ON CONFLICT DO NOTHING;
+
+-- Version: 2.2
+-- Description: Create table audit_events
+CREATE TABLE audit_events (
+	event_id     UUID,
+	actor        TEXT,
+	action       TEXT,
+	target_type  TEXT,
+	target_id    TEXT,
+	changes      JSONB NOT NULL DEFAULT '{}',
+	trace_id     TEXT,
+	date_created TIMESTAMP,
+
+	PRIMARY KEY (event_id)
+);
+
+CREATE INDEX audit_events_date_created ON audit_events (date_created, event_id);
+
+CREATE FUNCTION audit_events_append_only() RETURNS TRIGGER AS $$
+	BEGIN
+		RAISE EXCEPTION 'audit_events is append-only';
+	END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+	BEFORE UPDATE OR DELETE ON audit_events
+	FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+INSERT INTO role_permissions (role_name, permission) VALUES
+	('ADMIN', 'audit:read')
+	ON CONFLICT DO NOTHING;
+
+-- Version: 2.3
+-- Description: Forbid truncating audit_events
+CREATE TRIGGER audit_events_no_truncate
+	BEFORE TRUNCATE ON audit_events
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+
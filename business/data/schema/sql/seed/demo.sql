@@ -0,0 +1,21 @@
+-- The demo set shows a small shop. Every user logs in with the password
+-- gophers.
+INSERT INTO users (user_id, name, email, roles, password_hash, email_verified, date_created, date_updated) VALUES
+	('5cf37266-3473-4006-984f-9325122678b7', 'Admin Gopher', 'admin@example.com', '{ADMIN,USER}', '$2a$10$1ggfMVZV6Js0ybvJufLRUOWHS5f6KneuP0XwwHpJ8L8ipdry9f2/a', TRUE, '2019-03-24 00:00:00', '2019-03-24 00:00:00'),
+	('8d6b2a5e-2f44-4a1e-9c51-0f3b6f4c7a10', 'Ada Seller', 'ada@example.com', '{USER}', '$2a$10$1ggfMVZV6Js0ybvJufLRUOWHS5f6KneuP0XwwHpJ8L8ipdry9f2/a', TRUE, '2019-04-01 00:00:00', '2019-04-01 00:00:00'),
+	('b3f7c1d2-6e8a-4f0b-8a3d-2c9e5b7d1f42', 'Brian Seller', 'brian@example.com', '{USER}', '$2a$10$1ggfMVZV6Js0ybvJufLRUOWHS5f6KneuP0XwwHpJ8L8ipdry9f2/a', TRUE, '2019-04-02 00:00:00', '2019-04-02 00:00:00'),
+	('e1a4d9c7-3b5f-4c2e-9d8a-6f0b1c3e5a77', 'Carla Buyer', 'carla@example.com', '{USER}', '$2a$10$1ggfMVZV6Js0ybvJufLRUOWHS5f6KneuP0XwwHpJ8L8ipdry9f2/a', FALSE, '2019-04-03 00:00:00', '2019-04-03 00:00:00')
+	ON CONFLICT DO NOTHING;
+
+INSERT INTO products (product_id, user_id, name, cost, quantity, date_created, date_updated) VALUES
+	('0f4e6c2a-8b1d-4e3f-a5c7-9d2b4f6e8a01', '8d6b2a5e-2f44-4a1e-9c51-0f3b6f4c7a10', 'Gopher Plush', 25, 120, '2019-04-05 00:00:00', '2019-04-05 00:00:00'),
+	('1a5f7d3b-9c2e-4f40-b6d8-0e3c5a7f9b12', '8d6b2a5e-2f44-4a1e-9c51-0f3b6f4c7a10', 'Gopher Stickers', 5, 1000, '2019-04-05 00:00:00', '2019-04-05 00:00:00'),
+	('2b6a8e4c-0d3f-4a51-87e9-1f4d6b8a0c23', 'b3f7c1d2-6e8a-4f0b-8a3d-2c9e5b7d1f42', 'Go Programming Book', 40, 35, '2019-04-06 00:00:00', '2019-04-06 00:00:00'),
+	('3c7b9f5d-1e4a-4b62-98fa-2a5e7c9b1d34', 'b3f7c1d2-6e8a-4f0b-8a3d-2c9e5b7d1f42', 'Mechanical Keyboard', 120, 10, '2019-04-06 00:00:00', '2019-04-06 00:00:00')
+	ON CONFLICT DO NOTHING;
+
+INSERT INTO sales (sale_id, user_id, product_id, quantity, paid, date_created) VALUES
+	('4d8c0a6e-2f5b-4c73-a90b-3b6f8d0c2e45', 'e1a4d9c7-3b5f-4c2e-9d8a-6f0b1c3e5a77', '0f4e6c2a-8b1d-4e3f-a5c7-9d2b4f6e8a01', 2, 50, '2019-04-10 00:00:00'),
+	('5e9d1b7f-3a6c-4d84-b01c-4c7a9e1d3f56', 'e1a4d9c7-3b5f-4c2e-9d8a-6f0b1c3e5a77', '2b6a8e4c-0d3f-4a51-87e9-1f4d6b8a0c23', 1, 40, '2019-04-11 00:00:00'),
+	('6fae2c8a-4b7d-4e95-82ad-5d8b0f2e4a67', '8d6b2a5e-2f44-4a1e-9c51-0f3b6f4c7a10', '3c7b9f5d-1e4a-4b62-98fa-2a5e7c9b1d34', 1, 120, '2019-04-12 00:00:00')
+	ON CONFLICT DO NOTHING;
@@ -0,0 +1,329 @@
+// Package apikey manages the API keys used by other services to call the API.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/database"
+	"github.com/dimashiro/service/business/validate"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// KeyPrefix starts every API key so leaked keys are easy to spot.
+const KeyPrefix = "rk"
+
+// Store manages the set of API's for API key access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Tran returns a copy of the Store that runs its queries inside the
+// provided transaction.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log: s.log,
+		db:  tx,
+	}
+}
+
+// Create adds an API key owned by the user from the claims, or by the service
+// when one is named, which requires the permission to manage API keys. The
+// scopes can't exceed the permissions of the claims, and a caller using an
+// API key can't create another one, which could outlive its own. The
+// returned key is handed to the client, it can't be retrieved later.
+func (s Store) Create(ctx context.Context, claims auth.Claims, nk NewAPIKeyDTO, now time.Time) (APIKey, string, error) {
+	if err := validate.Check(nk); err != nil {
+		return APIKey{}, "", fmt.Errorf("validating data: %w", err)
+	}
+
+	if nk.DateExpires != nil && !nk.DateExpires.After(now) {
+		return APIKey{}, "", validate.FieldErrors{{Field: "date_expires", Error: "date_expires must be in the future"}}
+	}
+
+	if claims.APIKeyID != "" || !claims.HasPermission(nk.Scopes...) {
+		return APIKey{}, "", database.ErrForbidden
+	}
+
+	key := APIKey{
+		ID:          validate.GenerateID(),
+		Name:        nk.Name,
+		Scopes:      nk.Scopes,
+		DateCreated: now,
+		DateUpdated: now,
+		DateExpires: nk.DateExpires,
+	}
+
+	switch {
+	case nk.Service != "":
+		if !claims.HasPermission(auth.PermAPIKeysManage) {
+			return APIKey{}, "", database.ErrForbidden
+		}
+		key.Service = &nk.Service
+
+	default:
+		if err := validate.CheckID(claims.Subject); err != nil {
+			return APIKey{}, "", database.ErrForbidden
+		}
+		key.UserID = &claims.Subject
+	}
+
+	prefix, secret, err := generate()
+	if err != nil {
+		return APIKey{}, "", fmt.Errorf("generating api key: %w", err)
+	}
+	key.Prefix = prefix
+	key.KeyHash = hash(secret)
+
+	const q = `
+	INSERT INTO api_keys
+		(key_id, user_id, service, name, prefix, key_hash, scopes, date_created, date_updated, date_expires)
+	VALUES
+		(:key_id, :user_id, :service, :name, :prefix, :key_hash, :scopes, :date_created, :date_updated, :date_expires)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, key); err != nil {
+		return APIKey{}, "", fmt.Errorf("inserting api key: %w", err)
+	}
+
+	return key, KeyPrefix + "_" + prefix + "_" + secret, nil
+}
+
+// Update modifies the name and scopes of an API key. The scopes can't exceed
+// the permissions of the claims.
+func (s Store) Update(ctx context.Context, claims auth.Claims, keyID string, uk UpdateAPIKeyDTO, now time.Time) error {
+	if err := validate.Check(uk); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	key, err := s.GetByID(ctx, claims, keyID)
+	if err != nil {
+		return fmt.Errorf("updating api key keyID %s: %w", keyID, err)
+	}
+
+	if uk.Name != nil {
+		key.Name = *uk.Name
+	}
+	if uk.Scopes != nil {
+		if !claims.HasPermission(uk.Scopes...) {
+			return database.ErrForbidden
+		}
+		key.Scopes = uk.Scopes
+	}
+	key.DateUpdated = now
+
+	const q = `
+	UPDATE
+		api_keys
+	SET
+		"name" = :name,
+		"scopes" = :scopes,
+		"date_updated" = :date_updated
+	WHERE
+		key_id = :key_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, key); err != nil {
+		return fmt.Errorf("updating keyID[%s]: %w", keyID, err)
+	}
+
+	return nil
+}
+
+// Delete removes an API key, which stops working immediately.
+func (s Store) Delete(ctx context.Context, claims auth.Claims, keyID string) error {
+	if _, err := s.GetByID(ctx, claims, keyID); err != nil {
+		return fmt.Errorf("deleting api key keyID %s: %w", keyID, err)
+	}
+
+	data := struct {
+		KeyID string `db:"key_id"`
+	}{
+		KeyID: keyID,
+	}
+
+	const q = `
+	DELETE FROM
+		api_keys
+	WHERE
+		key_id = :key_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting keyID[%s]: %w", keyID, err)
+	}
+
+	return nil
+}
+
+// GetAll returns the API keys of the user from the claims, or every API key
+// when the claims grant the permission to manage them.
+func (s Store) GetAll(ctx context.Context, claims auth.Claims) ([]APIKey, error) {
+	q := `
+	SELECT
+		*
+	FROM
+		api_keys
+	`
+
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: claims.Subject,
+	}
+
+	if !claims.HasPermission(auth.PermAPIKeysManage) {
+		// Services don't own keys of their own.
+		if err := validate.CheckID(claims.Subject); err != nil {
+			return nil, nil
+		}
+		q += `WHERE
+		user_id = :user_id
+	`
+	}
+
+	q += `ORDER BY
+		date_created, key_id`
+
+	var keys []APIKey
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, data, &keys); err != nil {
+		return nil, fmt.Errorf("selecting api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// GetByID returns an API key. Only its owner or a user allowed to manage API
+// keys can see it.
+func (s Store) GetByID(ctx context.Context, claims auth.Claims, keyID string) (APIKey, error) {
+	if err := validate.CheckID(keyID); err != nil {
+		return APIKey{}, database.ErrInvalidID
+	}
+
+	data := struct {
+		KeyID string `db:"key_id"`
+	}{
+		KeyID: keyID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		api_keys
+	WHERE
+		key_id = :key_id`
+
+	var key APIKey
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &key); err != nil {
+		return APIKey{}, fmt.Errorf("selecting keyID[%q]: %w", keyID, err)
+	}
+
+	owner := key.UserID != nil && *key.UserID == claims.Subject
+	if !owner && !claims.HasPermission(auth.PermAPIKeysManage) {
+		return APIKey{}, database.ErrForbidden
+	}
+
+	return key, nil
+}
+
+// QueryByKey returns the API key matching the value presented by a client.
+// Malformed and unknown keys fail with ErrInvalidAPIKey.
+func (s Store) QueryByKey(ctx context.Context, value string) (APIKey, error) {
+	parts := strings.SplitN(value, "_", 3)
+	if len(parts) != 3 || parts[0] != KeyPrefix {
+		return APIKey{}, auth.ErrInvalidAPIKey
+	}
+
+	data := struct {
+		Prefix string `db:"prefix"`
+	}{
+		Prefix: parts[1],
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		api_keys
+	WHERE
+		prefix = :prefix`
+
+	var key APIKey
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &key); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return APIKey{}, auth.ErrInvalidAPIKey
+		}
+		return APIKey{}, fmt.Errorf("selecting api key prefix[%q]: %w", parts[1], err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hash(parts[2]))) != 1 {
+		return APIKey{}, auth.ErrInvalidAPIKey
+	}
+
+	return key, nil
+}
+
+// Touch records the use of an API key.
+func (s Store) Touch(ctx context.Context, keyID string, now time.Time) error {
+	data := struct {
+		KeyID string    `db:"key_id"`
+		Now   time.Time `db:"now"`
+	}{
+		KeyID: keyID,
+		Now:   now,
+	}
+
+	const q = `
+	UPDATE
+		api_keys
+	SET
+		"date_last_used" = :now
+	WHERE
+		key_id = :key_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("touching keyID[%s]: %w", keyID, err)
+	}
+
+	return nil
+}
+
+// generate returns the public prefix and the secret of a new key.
+func generate() (string, string, error) {
+	p := make([]byte, 5)
+	if _, err := rand.Read(p); err != nil {
+		return "", "", err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	prefix := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(p))
+	return prefix, base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hash returns the value stored in place of the secret of a key.
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,109 @@
+package apikey_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/data/store/apikey"
+	"github.com/dimashiro/service/business/data/tests"
+	"github.com/dimashiro/service/business/database"
+	"github.com/dimashiro/service/foundation/docker"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = tests.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer tests.StopDB(c)
+
+	m.Run()
+}
+
+func TestAPIKey(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t, c, "testapikey")
+	t.Cleanup(teardown)
+
+	store := apikey.NewStore(log, db)
+
+	t.Log("Given the need to work with API keys.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single API key.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+			// User Gopher from the seed data.
+			claims := auth.Claims{
+				StandardClaims: jwt.StandardClaims{
+					Subject: "45b5fbd3-755f-4379-8f07-a58d4a30fa2f",
+				},
+				Roles:       []string{auth.RoleUser},
+				Permissions: []string{auth.PermProductsRead, auth.PermSalesRead},
+			}
+
+			nk := apikey.NewAPIKeyDTO{
+				Name:   "reporting",
+				Scopes: []string{auth.PermProductsRead},
+			}
+
+			key, value, err := store.Create(ctx, claims, nk, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an api key : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create an api key.", tests.Success, testID)
+
+			found, err := store.QueryByKey(ctx, value)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to find the api key : %s.", tests.Failed, testID, err)
+			}
+			if found.ID != key.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould find the same api key : got %s.", tests.Failed, testID, found.ID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to find the api key.", tests.Success, testID)
+
+			if _, err := store.QueryByKey(ctx, value+"x"); !errors.Is(err, auth.ErrInvalidAPIKey) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept a wrong secret : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept a wrong secret.", tests.Success, testID)
+
+			nk.Scopes = []string{auth.PermUsersWrite}
+			if _, _, err := store.Create(ctx, claims, nk, now); !errors.Is(err, database.ErrForbidden) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT grant more than the caller has : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT grant more than the caller has.", tests.Success, testID)
+
+			nk.Scopes = []string{auth.PermProductsRead}
+			keyClaims := claims
+			keyClaims.APIKeyID = key.ID
+			if _, _, err := store.Create(ctx, keyClaims, nk, now); !errors.Is(err, database.ErrForbidden) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT create a key with an api key : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT create a key with an api key.", tests.Success, testID)
+
+			nk = apikey.NewAPIKeyDTO{Name: "billing", Service: "billing", Scopes: []string{auth.PermSalesRead}}
+			if _, _, err := store.Create(ctx, claims, nk, now); !errors.Is(err, database.ErrForbidden) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT create a service key without the permission : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT create a service key without the permission.", tests.Success, testID)
+
+			if err := store.Delete(ctx, claims, key.ID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete the api key : %s.", tests.Failed, testID, err)
+			}
+			if _, err := store.QueryByKey(ctx, value); !errors.Is(err, auth.ErrInvalidAPIKey) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT find a deleted api key : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT find a deleted api key.", tests.Success, testID)
+		}
+	}
+}
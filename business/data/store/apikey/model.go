@@ -0,0 +1,40 @@
+package apikey
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// APIKey is the stored form of an API key. Only the hash of the secret part
+// of the key is persisted, the prefix identifies the key. A key is owned
+// either by a user or by a service.
+type APIKey struct {
+	ID           string         `db:"key_id" json:"id"`
+	UserID       *string        `db:"user_id" json:"user_id,omitempty"`
+	Service      *string        `db:"service" json:"service,omitempty"`
+	Name         string         `db:"name" json:"name"`
+	Prefix       string         `db:"prefix" json:"prefix"`
+	KeyHash      string         `db:"key_hash" json:"-" log:"redact"`
+	Scopes       pq.StringArray `db:"scopes" json:"scopes"`
+	DateCreated  time.Time      `db:"date_created" json:"date_created"`
+	DateUpdated  time.Time      `db:"date_updated" json:"date_updated"`
+	DateExpires  *time.Time     `db:"date_expires" json:"date_expires,omitempty"`
+	DateLastUsed *time.Time     `db:"date_last_used" json:"date_last_used,omitempty"`
+}
+
+// NewAPIKeyDTO is what we require from clients when adding an API key. A key
+// is owned by the caller unless a service is named.
+type NewAPIKeyDTO struct {
+	Name        string     `json:"name" validate:"required"`
+	Service     string     `json:"service"`
+	Scopes      []string   `json:"scopes" validate:"required,min=1"`
+	DateExpires *time.Time `json:"date_expires"`
+}
+
+// UpdateAPIKeyDTO defines what information may be provided to modify an
+// existing API key. The secret of a key can't be changed.
+type UpdateAPIKeyDTO struct {
+	Name   *string  `json:"name"`
+	Scopes []string `json:"scopes" validate:"omitempty,min=1"`
+}
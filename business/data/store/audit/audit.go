@@ -0,0 +1,197 @@
+// Package audit records who changed what in the append-only audit log.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/dimashiro/service/business/database"
+	"github.com/dimashiro/service/business/validate"
+	"github.com/dimashiro/service/foundation/webapp"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of API's for audit access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Tran returns a copy of the Store that runs its queries inside the
+// provided transaction. Events should be recorded in the transaction of the
+// change they describe, so both are committed or neither is.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log: s.log,
+		db:  tx,
+	}
+}
+
+// Record appends an event to the audit log. The trace id is taken from the
+// context.
+func (s Store) Record(ctx context.Context, ne NewEvent, now time.Time) error {
+	changes, err := Diff(ne.Before, ne.After)
+	if err != nil {
+		return fmt.Errorf("diffing %s[%s]: %w", ne.TargetType, ne.TargetID, err)
+	}
+
+	evt := Event{
+		ID:          validate.GenerateID(),
+		Actor:       ne.Actor,
+		Action:      ne.Action,
+		TargetType:  ne.TargetType,
+		TargetID:    ne.TargetID,
+		Changes:     changes,
+		TraceID:     webapp.GetTraceID(ctx),
+		DateCreated: now,
+	}
+
+	const q = `
+	INSERT INTO audit_events
+		(event_id, actor, action, target_type, target_id, changes, trace_id, date_created)
+	VALUES
+		(:event_id, :actor, :action, :target_type, :target_id, :changes, :trace_id, :date_created)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, evt); err != nil {
+		return fmt.Errorf("inserting audit event: %w", err)
+	}
+
+	return nil
+}
+
+// DefaultLimit is the number of events returned when no limit is provided.
+const DefaultLimit = 100
+
+// orderByFields maps the fields events are ordered by to their columns.
+var orderByFields = map[string]string{
+	"created": "date_created",
+}
+
+// GetAll returns a page of the events matching the filter, the most recent
+// first, along with the cursor of the next page. The cursor is empty on the
+// last page.
+func (s Store) GetAll(ctx context.Context, qf QueryFilter) ([]Event, string, error) {
+	if err := validate.Check(qf); err != nil {
+		return nil, "", fmt.Errorf("validating filter: %w", err)
+	}
+
+	limit := qf.Limit
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+
+	filter := database.NewFilter()
+	if qf.Actor != "" {
+		filter.Where("actor = :actor", "actor", qf.Actor)
+	}
+	if qf.Action != "" {
+		filter.Where("action = :action", "action", qf.Action)
+	}
+	if qf.TargetType != "" {
+		filter.Where("target_type = :target_type", "target_type", qf.TargetType)
+	}
+	if qf.TargetID != "" {
+		filter.Where("target_id = :target_id", "target_id", qf.TargetID)
+	}
+	if qf.Since != nil {
+		filter.Where("date_created >= :since", "since", *qf.Since)
+	}
+	if qf.Until != nil {
+		filter.Where("date_created < :until", "until", *qf.Until)
+	}
+
+	orderBy := database.OrderBy{Field: "created", Direction: database.DESC}
+
+	page, err := filter.Page(orderByFields, "event_id", orderBy, qf.Cursor, limit)
+	if err != nil {
+		return nil, "", validate.FieldErrors{{Field: "cursor", Error: err.Error()}}
+	}
+
+	q := `
+	SELECT
+		*
+	FROM
+		audit_events
+	` + page
+
+	var evts []Event
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, filter.Data(), &evts); err != nil {
+		return nil, "", fmt.Errorf("selecting audit events: %w", err)
+	}
+
+	if len(evts) <= limit {
+		return evts, "", nil
+	}
+
+	evts = evts[:limit]
+	last := evts[limit-1]
+
+	return evts, database.NextCursor(orderBy, last.DateCreated.Format(time.RFC3339Nano), last.ID), nil
+}
+
+// change is the value of a field before and after an operation.
+type change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Diff returns the fields whose value differs between the JSON forms of
+// before and after. A nil value stands for a record that doesn't exist, so
+// every field of the other one is reported.
+func Diff(before interface{}, after interface{}) (json.RawMessage, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]change)
+	for k, bv := range b {
+		if av, exists := a[k]; !exists || !reflect.DeepEqual(bv, av) {
+			changes[k] = change{Before: bv, After: a[k]}
+		}
+	}
+	for k, av := range a {
+		if _, exists := b[k]; !exists {
+			changes[k] = change{After: av}
+		}
+	}
+
+	// The keys of a map are sorted when marshaled.
+	return json.Marshal(changes)
+}
+
+// fields returns the JSON fields of the value.
+func fields(v interface{}) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	if v == nil {
+		return m, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
@@ -0,0 +1,107 @@
+package audit_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dimashiro/service/business/data/store/audit"
+	"github.com/dimashiro/service/business/data/tests"
+	"github.com/dimashiro/service/foundation/docker"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = tests.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer tests.StopDB(c)
+
+	m.Run()
+}
+
+func TestAudit(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t, c, "testaudit")
+	t.Cleanup(teardown)
+
+	store := audit.NewStore(log, db)
+
+	t.Log("Given the need to record who changed what.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen recording and querying events.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+			type record struct {
+				Name  string `json:"name"`
+				Email string `json:"email"`
+			}
+
+			ne := audit.NewEvent{
+				Actor:      "5cf37266-3473-4006-984f-9325122678b7",
+				Action:     audit.ActionUserUpdate,
+				TargetType: audit.TargetUser,
+				TargetID:   "45b5fbd3-755f-4379-8f07-a58d4a30fa2f",
+				Before:     record{Name: "User Gopher", Email: "user@example.com"},
+				After:      record{Name: "Jacob Walker", Email: "user@example.com"},
+			}
+
+			for i := 0; i < 3; i++ {
+				if err := store.Record(ctx, ne, now.Add(time.Duration(i)*time.Second)); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to record an event : %s.", tests.Failed, testID, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to record an event.", tests.Success, testID)
+
+			evts, next, err := store.GetAll(ctx, audit.QueryFilter{TargetID: ne.TargetID, Limit: 2})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query events : %s.", tests.Failed, testID, err)
+			}
+			if len(evts) != 2 || next == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get a page of events and a cursor : got %d, %q.", tests.Failed, testID, len(evts), next)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get a page of events and a cursor.", tests.Success, testID)
+
+			if !evts[0].DateCreated.After(evts[1].DateCreated) {
+				t.Fatalf("\t%s\tTest %d:\tShould get the most recent events first.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the most recent events first.", tests.Success, testID)
+
+			var changes map[string]map[string]string
+			if err := json.Unmarshal(evts[0].Changes, &changes); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the changes : %s.", tests.Failed, testID, err)
+			}
+			if len(changes) != 1 || changes["name"]["before"] != "User Gopher" || changes["name"]["after"] != "Jacob Walker" {
+				t.Fatalf("\t%s\tTest %d:\tShould only record the changed fields : got %v.", tests.Failed, testID, changes)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only record the changed fields.", tests.Success, testID)
+
+			evts, next, err = store.GetAll(ctx, audit.QueryFilter{TargetID: ne.TargetID, Cursor: next, Limit: 2})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query the next page : %s.", tests.Failed, testID, err)
+			}
+			if len(evts) != 1 || next != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the last page : got %d, %q.", tests.Failed, testID, len(evts), next)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the last page.", tests.Success, testID)
+
+			if _, err := db.ExecContext(ctx, "DELETE FROM audit_events"); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to delete events.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to delete events.", tests.Success, testID)
+
+			if _, err := db.ExecContext(ctx, "TRUNCATE audit_events"); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to truncate events.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to truncate events.", tests.Success, testID)
+		}
+	}
+}
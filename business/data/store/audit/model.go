@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Set of actions recorded in the audit log.
+const (
+	ActionUserCreate      = "user.create"
+	ActionUserUpdate      = "user.update"
+	ActionUserDelete      = "user.delete"
+	ActionUserPassword    = "user.password"
+	ActionUserVerifyEmail = "user.verify_email"
+	ActionUserMFAEnable   = "user.mfa_enable"
+	ActionUserMFADisable  = "user.mfa_disable"
+	ActionTokenIssue      = "token.issue"
+	ActionTokenRefresh    = "token.refresh"
+	ActionAPIKeyCreate    = "apikey.create"
+	ActionAPIKeyUpdate    = "apikey.update"
+	ActionAPIKeyDelete    = "apikey.delete"
+	ActionProductCreate   = "product.create"
+	ActionProductUpdate   = "product.update"
+	ActionProductDelete   = "product.delete"
+	ActionSaleCreate      = "sale.create"
+)
+
+// Set of types of the records an action applies to.
+const (
+	TargetUser    = "user"
+	TargetAPIKey  = "apikey"
+	TargetProduct = "product"
+	TargetSale    = "sale"
+)
+
+// Event is a state-changing operation recorded in the audit log. Changes
+// holds the fields that changed with their value before and after.
+type Event struct {
+	ID          string          `db:"event_id" json:"id"`
+	Actor       string          `db:"actor" json:"actor"`
+	Action      string          `db:"action" json:"action"`
+	TargetType  string          `db:"target_type" json:"target_type"`
+	TargetID    string          `db:"target_id" json:"target_id"`
+	Changes     json.RawMessage `db:"changes" json:"changes"`
+	TraceID     string          `db:"trace_id" json:"trace_id"`
+	DateCreated time.Time       `db:"date_created" json:"date_created"`
+}
+
+// NewEvent is what the core layer provides to record an event. Before and
+// After are the target before and after the operation, nil when it didn't
+// exist. Their JSON form is compared, so fields hidden from JSON never end up
+// in the audit log.
+type NewEvent struct {
+	Actor      string
+	Action     string
+	TargetType string
+	TargetID   string
+	Before     interface{}
+	After      interface{}
+}
+
+// QueryFilter holds the fields events can be listed by. Empty fields don't
+// filter, Cursor is the next_cursor returned with the previous page.
+type QueryFilter struct {
+	Actor      string     `validate:"omitempty"`
+	Action     string     `validate:"omitempty"`
+	TargetType string     `validate:"omitempty"`
+	TargetID   string     `validate:"omitempty"`
+	Since      *time.Time `validate:"omitempty"`
+	Until      *time.Time `validate:"omitempty"`
+	Cursor     string     `validate:"omitempty"`
+	Limit      int        `validate:"omitempty,min=1,max=1000"`
+}
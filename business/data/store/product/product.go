@@ -0,0 +1,204 @@
+package product
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/database"
+	"github.com/dimashiro/service/business/validate"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of API's for product access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Tran returns a copy of the Store that runs its queries inside the
+// provided transaction.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log: s.log,
+		db:  tx,
+	}
+}
+
+// Create adds a product to the database owned by the user from the claims.
+func (s Store) Create(ctx context.Context, claims auth.Claims, np NewProductDTO, now time.Time) (Product, error) {
+	if err := validate.Check(np); err != nil {
+		return Product{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	prd := Product{
+		ID:          validate.GenerateID(),
+		Name:        np.Name,
+		Cost:        np.Cost,
+		Quantity:    np.Quantity,
+		UserID:      claims.Subject,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	const q = `
+	INSERT INTO products
+		(product_id, user_id, name, cost, quantity, date_created, date_updated)
+	VALUES
+		(:product_id, :user_id, :name, :cost, :quantity, :date_created, :date_updated)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, prd); err != nil {
+		return Product{}, fmt.Errorf("inserting product: %w", err)
+	}
+
+	return prd, nil
+}
+
+// Update modifies data about a product. Only the owner of the product or a
+// user allowed to manage products can change it.
+func (s Store) Update(ctx context.Context, claims auth.Claims, productID string, up UpdateProductDTO, now time.Time) error {
+	if err := validate.CheckID(productID); err != nil {
+		return database.ErrInvalidID
+	}
+
+	if err := validate.Check(up); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	prd, err := s.GetByID(ctx, productID)
+	if err != nil {
+		return fmt.Errorf("updating product productID %s: %w", productID, err)
+	}
+
+	if !claims.HasPermission(auth.PermProductsManage) && prd.UserID != claims.Subject {
+		return database.ErrForbidden
+	}
+
+	if up.Name != nil {
+		prd.Name = *up.Name
+	}
+	if up.Cost != nil {
+		prd.Cost = *up.Cost
+	}
+	if up.Quantity != nil {
+		prd.Quantity = *up.Quantity
+	}
+	prd.DateUpdated = now
+
+	const q = `
+	UPDATE
+		products
+	SET
+		"name" = :name,
+		"cost" = :cost,
+		"quantity" = :quantity,
+		"date_updated" = :date_updated
+	WHERE
+		product_id = :product_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, prd); err != nil {
+		return fmt.Errorf("updating productID[%s]: %w", prd.ID, err)
+	}
+
+	return nil
+}
+
+// Delete removes a product from the database. Only the owner of the product
+// or a user allowed to manage products can remove it.
+func (s Store) Delete(ctx context.Context, claims auth.Claims, productID string) error {
+	if err := validate.CheckID(productID); err != nil {
+		return database.ErrInvalidID
+	}
+
+	prd, err := s.GetByID(ctx, productID)
+	if err != nil {
+		return fmt.Errorf("deleting product productID %s: %w", productID, err)
+	}
+
+	if !claims.HasPermission(auth.PermProductsManage) && prd.UserID != claims.Subject {
+		return database.ErrForbidden
+	}
+
+	data := struct {
+		ProductID string `db:"product_id"`
+	}{
+		ProductID: productID,
+	}
+
+	const q = `
+	DELETE FROM
+		products
+	WHERE
+		product_id = :product_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting productID[%s]: %w", productID, err)
+	}
+
+	return nil
+}
+
+// GetAll retrieves a list of existing products from the database.
+func (s Store) GetAll(ctx context.Context, pageNumber int, rowsPerPage int) ([]Product, error) {
+	data := struct {
+		Offset      int `db:"offset"`
+		RowsPerPage int `db:"rows_per_page"`
+	}{
+		Offset:      (pageNumber - 1) * rowsPerPage,
+		RowsPerPage: rowsPerPage,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		products
+	ORDER BY
+		product_id
+	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY`
+
+	var prds []Product
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, data, &prds); err != nil {
+		return nil, fmt.Errorf("selecting products: %w", err)
+	}
+
+	return prds, nil
+}
+
+// GetByID finds the product identified by a given ID.
+func (s Store) GetByID(ctx context.Context, productID string) (Product, error) {
+	if err := validate.CheckID(productID); err != nil {
+		return Product{}, database.ErrInvalidID
+	}
+
+	data := struct {
+		ProductID string `db:"product_id"`
+	}{
+		ProductID: productID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		products
+	WHERE
+		product_id = :product_id`
+
+	var prd Product
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &prd); err != nil {
+		return Product{}, fmt.Errorf("selecting productID[%q]: %w", productID, err)
+	}
+
+	return prd, nil
+}
@@ -0,0 +1,143 @@
+package product_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/data/store/product"
+	"github.com/dimashiro/service/business/data/tests"
+	"github.com/dimashiro/service/business/database"
+	"github.com/dimashiro/service/foundation/docker"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/go-cmp/cmp"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = tests.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer tests.StopDB(c)
+
+	m.Run()
+}
+
+func TestProduct(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t, c, "testproduct")
+	t.Cleanup(teardown)
+
+	store := product.NewStore(log, db)
+
+	t.Log("Given the need to work with Product records.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single Product.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+			owner := auth.Claims{
+				StandardClaims: jwt.StandardClaims{
+					Issuer:    "this service",
+					Subject:   "45b5fbd3-755f-4379-8f07-a58d4a30fa2f",
+					ExpiresAt: time.Now().Add(time.Hour).Unix(),
+					IssuedAt:  time.Now().UTC().Unix(),
+				},
+				Roles: []string{auth.RoleUser},
+			}
+
+			np := product.NewProductDTO{
+				Name:     "Comic Books",
+				Cost:     10,
+				Quantity: 55,
+			}
+
+			prd, err := store.Create(ctx, owner, np, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a product.", tests.Success, testID)
+
+			saved, err := store.GetByID(ctx, prd.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product by ID: %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve product by ID.", tests.Success, testID)
+
+			if diff := cmp.Diff(prd, saved); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the same product. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same product.", tests.Success, testID)
+
+			upd := product.UpdateProductDTO{
+				Name:     tests.StringPointer("Comics"),
+				Cost:     tests.IntPointer(50),
+				Quantity: tests.IntPointer(40),
+			}
+
+			stranger := auth.Claims{
+				StandardClaims: jwt.StandardClaims{
+					Issuer:    "this service",
+					Subject:   "5cf37266-3473-4006-984f-9325122678b7",
+					ExpiresAt: time.Now().Add(time.Hour).Unix(),
+					IssuedAt:  time.Now().UTC().Unix(),
+				},
+				Roles: []string{auth.RoleUser},
+			}
+
+			if err := store.Update(ctx, stranger, prd.ID, upd, now); !errors.Is(err, database.ErrForbidden) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to update another user's product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to update another user's product.", tests.Success, testID)
+
+			if err := store.Update(ctx, owner, prd.ID, upd, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to update product.", tests.Success, testID)
+
+			saved, err = store.GetByID(ctx, prd.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve updated product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve updated product.", tests.Success, testID)
+
+			want := prd
+			want.Name = *upd.Name
+			want.Cost = *upd.Cost
+			want.Quantity = *upd.Quantity
+
+			if diff := cmp.Diff(want, saved); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the updated product. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the updated product.", tests.Success, testID)
+
+			if err := store.Delete(ctx, stranger, prd.ID); !errors.Is(err, database.ErrForbidden) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to delete another user's product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to delete another user's product.", tests.Success, testID)
+
+			admin := stranger
+			admin.Roles = []string{auth.RoleAdmin}
+			admin.Permissions = []string{auth.PermProductsManage}
+
+			if err := store.Delete(ctx, admin, prd.ID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete product.", tests.Success, testID)
+
+			_, err = store.GetByID(ctx, prd.ID)
+			if !errors.Is(err, database.ErrDBNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve deleted product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve deleted product.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+package role
+
+// Role is a named set of permissions.
+type Role struct {
+	Name        string `db:"name" json:"name"`
+	Description string `db:"description" json:"description"`
+}
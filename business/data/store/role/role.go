@@ -0,0 +1,87 @@
+// Package role manages the roles and the permissions they grant.
+package role
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dimashiro/service/business/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of API's for role access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Tran returns a copy of the Store that runs its queries inside the
+// provided transaction.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log: s.log,
+		db:  tx,
+	}
+}
+
+// GetAll returns every role.
+func (s Store) GetAll(ctx context.Context) ([]Role, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		roles
+	ORDER BY
+		name`
+
+	var roles []Role
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, struct{}{}, &roles); err != nil {
+		return nil, fmt.Errorf("selecting roles: %w", err)
+	}
+
+	return roles, nil
+}
+
+// Permissions returns the permissions granted by any of the roles. Unknown
+// roles grant nothing.
+func (s Store) Permissions(ctx context.Context, roles []string) ([]string, error) {
+	data := struct {
+		Roles pq.StringArray `db:"roles"`
+	}{
+		Roles: roles,
+	}
+
+	const q = `
+	SELECT DISTINCT
+		permission
+	FROM
+		role_permissions
+	WHERE
+		role_name = ANY(:roles)
+	ORDER BY
+		permission`
+
+	var rps []struct {
+		Permission string `db:"permission"`
+	}
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, data, &rps); err != nil {
+		return nil, fmt.Errorf("selecting permissions: %w", err)
+	}
+
+	perms := make([]string, len(rps))
+	for i, rp := range rps {
+		perms[i] = rp.Permission
+	}
+
+	return perms, nil
+}
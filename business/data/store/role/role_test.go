@@ -0,0 +1,73 @@
+package role_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/data/store/role"
+	"github.com/dimashiro/service/business/data/tests"
+	"github.com/dimashiro/service/foundation/docker"
+	"github.com/google/go-cmp/cmp"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = tests.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer tests.StopDB(c)
+
+	m.Run()
+}
+
+func TestRole(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t, c, "testrole")
+	t.Cleanup(teardown)
+
+	store := role.NewStore(log, db)
+
+	t.Log("Given the need to resolve the permissions of roles.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the default roles.", testID)
+		{
+			ctx := context.Background()
+
+			perms, err := store.Permissions(ctx, []string{auth.RoleUser})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to get the permissions : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to get the permissions.", tests.Success, testID)
+
+			exp := []string{auth.PermProductsRead, auth.PermProductsWrite, auth.PermSalesCreate, auth.PermSalesRead}
+			if diff := cmp.Diff(exp, perms); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the permissions of the role. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the permissions of the role.", tests.Success, testID)
+
+			perms, err = store.Permissions(ctx, []string{auth.RoleAdmin, auth.RoleUser})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to get the permissions : %s.", tests.Failed, testID, err)
+			}
+			if len(perms) != 9 {
+				t.Fatalf("\t%s\tTest %d:\tShould merge the permissions of the roles : got %v.", tests.Failed, testID, perms)
+			}
+			t.Logf("\t%s\tTest %d:\tShould merge the permissions of the roles.", tests.Success, testID)
+
+			perms, err = store.Permissions(ctx, []string{"UNKNOWN"})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to get the permissions : %s.", tests.Failed, testID, err)
+			}
+			if len(perms) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT grant anything to an unknown role : got %v.", tests.Failed, testID, perms)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT grant anything to an unknown role.", tests.Success, testID)
+		}
+	}
+}
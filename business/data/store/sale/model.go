@@ -0,0 +1,18 @@
+package sale
+
+import (
+	"time"
+)
+
+type Sale struct {
+	ID          string    `db:"sale_id" json:"id"`
+	UserID      string    `db:"user_id" json:"user_id"`
+	ProductID   string    `db:"product_id" json:"product_id"`
+	Quantity    int       `db:"quantity" json:"quantity"`
+	Paid        int       `db:"paid" json:"paid"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+}
+
+type NewSaleDTO struct {
+	Quantity int `json:"quantity" validate:"required,gte=1"`
+}
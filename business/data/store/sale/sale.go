@@ -0,0 +1,159 @@
+package sale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/database"
+	"github.com/dimashiro/service/business/validate"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// ErrInsufficientStock is returned when a sale asks for more items than the
+// product has left.
+var ErrInsufficientStock = validate.NewStatusError(http.StatusConflict, "insufficient_stock", "insufficient stock")
+
+// Store manages the set of API's for sale access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Tran returns a copy of the Store that runs its queries inside the
+// provided transaction.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log: s.log,
+		db:  tx,
+	}
+}
+
+// Create records a sale of a product for the user from the claims. The sale
+// is inserted and the product quantity is decremented in a single statement,
+// so both changes are committed or rolled back together. The amount paid is
+// computed from the current product cost.
+func (s Store) Create(ctx context.Context, claims auth.Claims, productID string, ns NewSaleDTO, now time.Time) (Sale, error) {
+	if err := validate.CheckID(productID); err != nil {
+		return Sale{}, database.ErrInvalidID
+	}
+
+	if err := validate.Check(ns); err != nil {
+		return Sale{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	data := Sale{
+		ID:          validate.GenerateID(),
+		UserID:      claims.Subject,
+		ProductID:   productID,
+		Quantity:    ns.Quantity,
+		DateCreated: now,
+	}
+
+	const q = `
+	WITH prd AS (
+		UPDATE
+			products
+		SET
+			"quantity" = quantity - :quantity,
+			"date_updated" = :date_created
+		WHERE
+			product_id = :product_id AND quantity >= :quantity
+		RETURNING
+			cost
+	)
+	INSERT INTO sales
+		(sale_id, user_id, product_id, quantity, paid, date_created)
+	SELECT
+		CAST(:sale_id AS UUID), CAST(:user_id AS UUID), CAST(:product_id AS UUID),
+		CAST(:quantity AS INT), prd.cost * CAST(:quantity AS INT), CAST(:date_created AS TIMESTAMP)
+	FROM
+		prd
+	RETURNING
+		*`
+
+	var sl Sale
+	err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &sl)
+	switch {
+	case err == nil:
+		return sl, nil
+	case !errors.Is(err, database.ErrDBNotFound):
+		return Sale{}, fmt.Errorf("inserting sale: %w", err)
+	}
+
+	// Nothing was inserted, either the product does not exist or it does not
+	// have enough items left.
+	if err := s.productExists(ctx, productID); err != nil {
+		return Sale{}, fmt.Errorf("inserting sale: %w", err)
+	}
+
+	return Sale{}, ErrInsufficientStock
+}
+
+// GetByProductID retrieves the list of sales for the specified product.
+func (s Store) GetByProductID(ctx context.Context, productID string) ([]Sale, error) {
+	if err := validate.CheckID(productID); err != nil {
+		return nil, database.ErrInvalidID
+	}
+
+	data := struct {
+		ProductID string `db:"product_id"`
+	}{
+		ProductID: productID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		sales
+	WHERE
+		product_id = :product_id
+	ORDER BY
+		date_created`
+
+	var sls []Sale
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, data, &sls); err != nil {
+		return nil, fmt.Errorf("selecting sales for productID[%q]: %w", productID, err)
+	}
+
+	return sls, nil
+}
+
+// productExists returns ErrDBNotFound when there is no product for the ID.
+func (s Store) productExists(ctx context.Context, productID string) error {
+	data := struct {
+		ProductID string `db:"product_id"`
+	}{
+		ProductID: productID,
+	}
+
+	const q = `
+	SELECT
+		product_id
+	FROM
+		products
+	WHERE
+		product_id = :product_id`
+
+	var prd struct {
+		ID string `db:"product_id"`
+	}
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &prd); err != nil {
+		return fmt.Errorf("selecting productID[%q]: %w", productID, err)
+	}
+
+	return nil
+}
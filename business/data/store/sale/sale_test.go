@@ -0,0 +1,123 @@
+package sale_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/data/store/product"
+	"github.com/dimashiro/service/business/data/store/sale"
+	"github.com/dimashiro/service/business/data/tests"
+	"github.com/dimashiro/service/business/database"
+	"github.com/dimashiro/service/foundation/docker"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = tests.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer tests.StopDB(c)
+
+	m.Run()
+}
+
+func TestSale(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t, c, "testsale")
+	t.Cleanup(teardown)
+
+	store := sale.NewStore(log, db)
+	prdStore := product.NewStore(log, db)
+
+	t.Log("Given the need to work with Sale records.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen selling a seeded Product.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+			// Comic Books from the seed data: cost 50, quantity 42.
+			const productID = "a2b0639f-2cc6-44b8-b97b-15d69dbb511e"
+
+			claims := auth.Claims{
+				StandardClaims: jwt.StandardClaims{
+					Issuer:    "this service",
+					Subject:   "5cf37266-3473-4006-984f-9325122678b7",
+					ExpiresAt: time.Now().Add(time.Hour).Unix(),
+					IssuedAt:  time.Now().UTC().Unix(),
+				},
+				Roles: []string{auth.RoleUser},
+			}
+
+			sl, err := store.Create(ctx, claims, productID, sale.NewSaleDTO{Quantity: 2}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a sale : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a sale.", tests.Success, testID)
+
+			if sl.Paid != 100 {
+				t.Errorf("\t%s\tTest %d:\tShould compute the paid amount from the product cost.", tests.Failed, testID)
+				t.Logf("\t\tTest %d:\tGot: %v", testID, sl.Paid)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, 100)
+			} else {
+				t.Logf("\t%s\tTest %d:\tShould compute the paid amount from the product cost.", tests.Success, testID)
+			}
+
+			prd, err := prdStore.GetByID(ctx, productID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product by ID : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve product by ID.", tests.Success, testID)
+
+			if prd.Quantity != 40 {
+				t.Errorf("\t%s\tTest %d:\tShould decrement the product quantity.", tests.Failed, testID)
+				t.Logf("\t\tTest %d:\tGot: %v", testID, prd.Quantity)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, 40)
+			} else {
+				t.Logf("\t%s\tTest %d:\tShould decrement the product quantity.", tests.Success, testID)
+			}
+
+			_, err = store.Create(ctx, claims, productID, sale.NewSaleDTO{Quantity: 41}, now)
+			if !errors.Is(err, sale.ErrInsufficientStock) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to sell more than the stock : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to sell more than the stock.", tests.Success, testID)
+
+			prd, err = prdStore.GetByID(ctx, productID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product by ID : %s.", tests.Failed, testID, err)
+			}
+			if prd.Quantity != 40 {
+				t.Fatalf("\t%s\tTest %d:\tShould leave the quantity untouched on a rejected sale : got %d.", tests.Failed, testID, prd.Quantity)
+			}
+			t.Logf("\t%s\tTest %d:\tShould leave the quantity untouched on a rejected sale.", tests.Success, testID)
+
+			_, err = store.Create(ctx, claims, "f47b1b5f-1c0d-4b8e-9d2f-2e6f0b7c8a11", sale.NewSaleDTO{Quantity: 1}, now)
+			if !errors.Is(err, database.ErrDBNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to sell an unknown product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to sell an unknown product.", tests.Success, testID)
+
+			sls, err := store.GetByProductID(ctx, productID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve sales for the product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve sales for the product.", tests.Success, testID)
+
+			// Two seeded sales plus the one created above.
+			if len(sls) != 3 {
+				t.Fatalf("\t%s\tTest %d:\tShould get back 3 sales : got %d.", tests.Failed, testID, len(sls))
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back 3 sales.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package token
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RefreshToken is the stored form of a refresh token. Only the hash of the
+// token value is persisted.
+type RefreshToken struct {
+	ID          string       `db:"token_id"`
+	UserID      string       `db:"user_id"`
+	TokenHash   string       `db:"token_hash" log:"redact"`
+	DateCreated time.Time    `db:"date_created"`
+	DateExpires time.Time    `db:"date_expires"`
+	DateRevoked sql.NullTime `db:"date_revoked"`
+}
+
+// RevokedToken is an access token revoked before its expiration.
+type RevokedToken struct {
+	JTI         string    `db:"jti"`
+	DateExpires time.Time `db:"date_expires"`
+	DateRevoked time.Time `db:"date_revoked"`
+}
+
+// Set of purposes a user token can be issued for.
+const (
+	PurposeReset  = "password_reset"
+	PurposeVerify = "email_verification"
+	PurposeMFA    = "mfa_challenge"
+)
+
+// UserToken is the stored form of a single use token mailed to a user. The
+// email is the address the token was sent to. Only the hash of the token
+// value is persisted.
+type UserToken struct {
+	ID          string       `db:"token_id"`
+	UserID      string       `db:"user_id"`
+	Purpose     string       `db:"purpose"`
+	Email       string       `db:"email" log:"redact"`
+	TokenHash   string       `db:"token_hash" log:"redact"`
+	DateCreated time.Time    `db:"date_created"`
+	DateExpires time.Time    `db:"date_expires"`
+	DateUsed    sql.NullTime `db:"date_used"`
+}
+
+// RecoveryCode is a single use code a user can log in with when they lost
+// their authenticator app. Only the hash of the code is persisted.
+type RecoveryCode struct {
+	ID          string       `db:"code_id"`
+	UserID      string       `db:"user_id"`
+	CodeHash    string       `db:"code_hash" log:"redact"`
+	DateCreated time.Time    `db:"date_created"`
+	DateUsed    sql.NullTime `db:"date_used"`
+}
@@ -0,0 +1,486 @@
+// Package token manages refresh tokens, revoked access tokens, the single
+// use tokens handed to users and their recovery codes.
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dimashiro/service/business/database"
+	"github.com/dimashiro/service/business/validate"
+	"github.com/dimashiro/service/foundation/webapp"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidToken is returned when a user token is unknown, expired, already
+// used or issued for another purpose.
+var ErrInvalidToken = validate.NewStatusError(http.StatusBadRequest, "invalid_token", "invalid or expired token")
+
+// ErrRefreshReused is returned when a refresh token that was already consumed
+// is presented again. It's an authentication failure.
+var ErrRefreshReused = fmt.Errorf("%w: refresh token reused", database.ErrAuthenticationFailure)
+
+// Store manages the set of API's for token access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Tran returns a copy of the Store that runs its queries inside the
+// provided transaction.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log: s.log,
+		db:  tx,
+	}
+}
+
+// CreateRefresh issues a new refresh token for the user. The returned value
+// is handed to the client, only its hash is stored.
+func (s Store) CreateRefresh(ctx context.Context, userID string, now time.Time, ttl time.Duration) (string, error) {
+	value, err := generate()
+	if err != nil {
+		return "", fmt.Errorf("generating refresh token: %w", err)
+	}
+
+	rt := RefreshToken{
+		ID:          validate.GenerateID(),
+		UserID:      userID,
+		TokenHash:   hash(value),
+		DateCreated: now,
+		DateExpires: now.Add(ttl),
+	}
+
+	const q = `
+	INSERT INTO refresh_tokens
+		(token_id, user_id, token_hash, date_created, date_expires)
+	VALUES
+		(:token_id, :user_id, :token_hash, :date_created, :date_expires)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, rt); err != nil {
+		return "", fmt.Errorf("inserting refresh token: %w", err)
+	}
+
+	return value, nil
+}
+
+// UseRefresh consumes a refresh token so it can't be used again. When a token
+// that was already consumed is presented, it fails with ErrRefreshReused and
+// returns the token so the caller can revoke every refresh token of its
+// owner, since the token has most likely been stolen. The revocation must be
+// committed on its own: the transaction the token was used in is rolled back
+// on that error.
+func (s Store) UseRefresh(ctx context.Context, value string, now time.Time) (RefreshToken, error) {
+	data := struct {
+		TokenHash string    `db:"token_hash"`
+		Now       time.Time `db:"now"`
+	}{
+		TokenHash: hash(value),
+		Now:       now,
+	}
+
+	const q = `
+	UPDATE
+		refresh_tokens
+	SET
+		"date_revoked" = :now
+	WHERE
+		token_hash = :token_hash AND date_revoked IS NULL AND date_expires > :now
+	RETURNING
+		*`
+
+	var rt RefreshToken
+	err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &rt)
+	switch {
+	case err == nil:
+		return rt, nil
+	case !errors.Is(err, database.ErrDBNotFound):
+		return RefreshToken{}, fmt.Errorf("using refresh token: %w", err)
+	}
+
+	const qReuse = `
+	SELECT
+		*
+	FROM
+		refresh_tokens
+	WHERE
+		token_hash = :token_hash AND date_revoked IS NOT NULL`
+
+	err = database.NamedQueryStruct(ctx, s.log, s.db, qReuse, data, &rt)
+	switch {
+	case err == nil:
+		s.log.Infow("refresh token reused", "traceid", webapp.GetTraceID(ctx), "userid", rt.UserID)
+		return rt, ErrRefreshReused
+	case !errors.Is(err, database.ErrDBNotFound):
+		return RefreshToken{}, fmt.Errorf("selecting refresh token: %w", err)
+	}
+
+	return RefreshToken{}, database.ErrAuthenticationFailure
+}
+
+// RevokeRefresh revokes a single refresh token owned by the user.
+func (s Store) RevokeRefresh(ctx context.Context, userID string, value string, now time.Time) error {
+	data := struct {
+		UserID    string    `db:"user_id"`
+		TokenHash string    `db:"token_hash"`
+		Now       time.Time `db:"now"`
+	}{
+		UserID:    userID,
+		TokenHash: hash(value),
+		Now:       now,
+	}
+
+	const q = `
+	UPDATE
+		refresh_tokens
+	SET
+		"date_revoked" = :now
+	WHERE
+		user_id = :user_id AND token_hash = :token_hash AND date_revoked IS NULL`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("revoking refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeAllRefresh revokes every active refresh token owned by the user.
+func (s Store) RevokeAllRefresh(ctx context.Context, userID string, now time.Time) error {
+	data := struct {
+		UserID string    `db:"user_id"`
+		Now    time.Time `db:"now"`
+	}{
+		UserID: userID,
+		Now:    now,
+	}
+
+	const q = `
+	UPDATE
+		refresh_tokens
+	SET
+		"date_revoked" = :now
+	WHERE
+		user_id = :user_id AND date_revoked IS NULL`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("revoking refresh tokens for userID[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// CreateUserToken issues a single use token for the purpose, mailed to the
+// user at the email. The returned value is handed to the user, only its hash
+// is stored.
+func (s Store) CreateUserToken(ctx context.Context, userID string, email string, purpose string, now time.Time, ttl time.Duration) (string, error) {
+	value, err := generate()
+	if err != nil {
+		return "", fmt.Errorf("generating user token: %w", err)
+	}
+
+	ut := UserToken{
+		ID:          validate.GenerateID(),
+		UserID:      userID,
+		Purpose:     purpose,
+		Email:       email,
+		TokenHash:   hash(value),
+		DateCreated: now,
+		DateExpires: now.Add(ttl),
+	}
+
+	const q = `
+	INSERT INTO user_tokens
+		(token_id, user_id, purpose, email, token_hash, date_created, date_expires)
+	VALUES
+		(:token_id, :user_id, :purpose, :email, :token_hash, :date_created, :date_expires)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, ut); err != nil {
+		return "", fmt.Errorf("inserting user token: %w", err)
+	}
+
+	return value, nil
+}
+
+// UseUserToken consumes a user token issued for the purpose so it can't be
+// used again. Unknown, expired and used tokens fail with ErrInvalidToken.
+func (s Store) UseUserToken(ctx context.Context, purpose string, value string, now time.Time) (UserToken, error) {
+	data := struct {
+		Purpose   string    `db:"purpose"`
+		TokenHash string    `db:"token_hash"`
+		Now       time.Time `db:"now"`
+	}{
+		Purpose:   purpose,
+		TokenHash: hash(value),
+		Now:       now,
+	}
+
+	const q = `
+	UPDATE
+		user_tokens
+	SET
+		"date_used" = :now
+	WHERE
+		token_hash = :token_hash AND purpose = :purpose AND date_used IS NULL AND date_expires > :now
+	RETURNING
+		*`
+
+	var ut UserToken
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &ut); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return UserToken{}, ErrInvalidToken
+		}
+		return UserToken{}, fmt.Errorf("using user token: %w", err)
+	}
+
+	return ut, nil
+}
+
+// QueryUserToken returns a usable user token issued for the purpose without
+// consuming it. Unknown, expired and used tokens fail with ErrInvalidToken.
+func (s Store) QueryUserToken(ctx context.Context, purpose string, value string, now time.Time) (UserToken, error) {
+	data := struct {
+		Purpose   string    `db:"purpose"`
+		TokenHash string    `db:"token_hash"`
+		Now       time.Time `db:"now"`
+	}{
+		Purpose:   purpose,
+		TokenHash: hash(value),
+		Now:       now,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		user_tokens
+	WHERE
+		token_hash = :token_hash AND purpose = :purpose AND date_used IS NULL AND date_expires > :now`
+
+	var ut UserToken
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &ut); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return UserToken{}, ErrInvalidToken
+		}
+		return UserToken{}, fmt.Errorf("selecting user token: %w", err)
+	}
+
+	return ut, nil
+}
+
+// RevokeUserTokens invalidates the unused tokens issued to the user for the
+// purpose, so only the most recent one mailed remains usable.
+func (s Store) RevokeUserTokens(ctx context.Context, userID string, purpose string, now time.Time) error {
+	data := struct {
+		UserID  string    `db:"user_id"`
+		Purpose string    `db:"purpose"`
+		Now     time.Time `db:"now"`
+	}{
+		UserID:  userID,
+		Purpose: purpose,
+		Now:     now,
+	}
+
+	const q = `
+	UPDATE
+		user_tokens
+	SET
+		"date_used" = :now
+	WHERE
+		user_id = :user_id AND purpose = :purpose AND date_used IS NULL`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("revoking user tokens for userID[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// RecoveryCodes is the number of recovery codes issued to a user.
+const RecoveryCodes = 10
+
+// CreateRecoveryCodes replaces the recovery codes of the user with new ones.
+// The returned codes are handed to the user, only their hashes are stored.
+func (s Store) CreateRecoveryCodes(ctx context.Context, userID string, now time.Time) ([]string, error) {
+	if err := s.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	const q = `
+	INSERT INTO recovery_codes
+		(code_id, user_id, code_hash, date_created)
+	VALUES
+		(:code_id, :user_id, :code_hash, :date_created)`
+
+	codes := make([]string, RecoveryCodes)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("generating recovery code: %w", err)
+		}
+
+		// 16 base32 characters, shown in two groups to be easier to type.
+		code := strings.ToLower(b32.EncodeToString(b))
+		codes[i] = code[:8] + "-" + code[8:]
+
+		rc := RecoveryCode{
+			ID:          validate.GenerateID(),
+			UserID:      userID,
+			CodeHash:    hash(normalizeCode(codes[i])),
+			DateCreated: now,
+		}
+
+		if err := database.NamedExecContext(ctx, s.log, s.db, q, rc); err != nil {
+			return nil, fmt.Errorf("inserting recovery code: %w", err)
+		}
+	}
+
+	return codes, nil
+}
+
+// UseRecoveryCode consumes a recovery code of the user so it can't be used
+// again. Unknown and used codes fail with ErrInvalidToken.
+func (s Store) UseRecoveryCode(ctx context.Context, userID string, code string, now time.Time) error {
+	data := struct {
+		UserID   string    `db:"user_id"`
+		CodeHash string    `db:"code_hash"`
+		Now      time.Time `db:"now"`
+	}{
+		UserID:   userID,
+		CodeHash: hash(normalizeCode(code)),
+		Now:      now,
+	}
+
+	const q = `
+	UPDATE
+		recovery_codes
+	SET
+		"date_used" = :now
+	WHERE
+		user_id = :user_id AND code_hash = :code_hash AND date_used IS NULL
+	RETURNING
+		*`
+
+	var rc RecoveryCode
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &rc); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return ErrInvalidToken
+		}
+		return fmt.Errorf("using recovery code: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteRecoveryCodes removes every recovery code of the user.
+func (s Store) DeleteRecoveryCodes(ctx context.Context, userID string) error {
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID,
+	}
+
+	const q = `
+	DELETE FROM
+		recovery_codes
+	WHERE
+		user_id = :user_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting recovery codes for userID[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// RevokeAccess marks the access token identified by jti as revoked. The row
+// can be pruned once the token expires.
+func (s Store) RevokeAccess(ctx context.Context, jti string, expires time.Time, now time.Time) error {
+	rt := RevokedToken{
+		JTI:         jti,
+		DateExpires: expires,
+		DateRevoked: now,
+	}
+
+	const q = `
+	INSERT INTO revoked_tokens
+		(jti, date_expires, date_revoked)
+	VALUES
+		(:jti, :date_expires, :date_revoked)
+	ON CONFLICT DO NOTHING`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, rt); err != nil {
+		return fmt.Errorf("revoking access token jti[%s]: %w", jti, err)
+	}
+
+	return nil
+}
+
+// IsRevoked reports if the access token identified by jti was revoked.
+func (s Store) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	data := struct {
+		JTI string `db:"jti"`
+	}{
+		JTI: jti,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		revoked_tokens
+	WHERE
+		jti = :jti`
+
+	var rt RevokedToken
+	err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &rt)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, database.ErrDBNotFound):
+		return false, nil
+	default:
+		return false, fmt.Errorf("selecting jti[%s]: %w", jti, err)
+	}
+}
+
+// generate returns a new random token value.
+func generate() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// b32 encodes the recovery codes, avoiding characters that are easy to mix up.
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// normalizeCode drops the formatting of a recovery code typed by a user.
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// hash returns the value stored in place of a token.
+func hash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,216 @@
+package token_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimashiro/service/business/data/store/token"
+	"github.com/dimashiro/service/business/data/tests"
+	"github.com/dimashiro/service/business/database"
+	"github.com/dimashiro/service/foundation/docker"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = tests.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer tests.StopDB(c)
+
+	m.Run()
+}
+
+func TestToken(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t, c, "testtoken")
+	t.Cleanup(teardown)
+
+	store := token.NewStore(log, db)
+
+	t.Log("Given the need to work with Token records.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen rotating refresh tokens.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+			// User Gopher from the seed data.
+			const userID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+
+			first, err := store.CreateRefresh(ctx, userID, now, time.Hour)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a refresh token : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a refresh token.", tests.Success, testID)
+
+			rt, err := store.UseRefresh(ctx, first, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to use the refresh token : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to use the refresh token.", tests.Success, testID)
+
+			if rt.UserID != userID {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the owner of the token : got %s.", tests.Failed, testID, rt.UserID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the owner of the token.", tests.Success, testID)
+
+			second, err := store.CreateRefresh(ctx, userID, now, time.Hour)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a refresh token : %s.", tests.Failed, testID, err)
+			}
+
+			reused, err := store.UseRefresh(ctx, first, now)
+			if !errors.Is(err, token.ErrRefreshReused) || !errors.Is(err, database.ErrAuthenticationFailure) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to use a refresh token twice : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to use a refresh token twice.", tests.Success, testID)
+
+			if reused.UserID != userID {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the owner of the reused token : got %s.", tests.Failed, testID, reused.UserID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the owner of the reused token.", tests.Success, testID)
+
+			if err := store.RevokeAllRefresh(ctx, reused.UserID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke every token of the user : %s.", tests.Failed, testID, err)
+			}
+
+			if _, err := store.UseRefresh(ctx, second, now); !errors.Is(err, database.ErrAuthenticationFailure) {
+				t.Fatalf("\t%s\tTest %d:\tShould revoke every token of the user : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould revoke every token of the user.", tests.Success, testID)
+
+			expired, err := store.CreateRefresh(ctx, userID, now, time.Hour)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a refresh token : %s.", tests.Failed, testID, err)
+			}
+
+			if _, err := store.UseRefresh(ctx, expired, now.Add(2*time.Hour)); !errors.Is(err, database.ErrAuthenticationFailure) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to use an expired refresh token : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to use an expired refresh token.", tests.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen revoking access tokens.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+			const jti = "9a3c1a43-3b4e-4d0e-a1f4-4bb1f0b1c6a2"
+
+			revoked, err := store.IsRevoked(ctx, jti)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to check revocation : %s.", tests.Failed, testID, err)
+			}
+			if revoked {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT report an unknown token as revoked.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT report an unknown token as revoked.", tests.Success, testID)
+
+			if err := store.RevokeAccess(ctx, jti, now.Add(time.Hour), now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke a token : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to revoke a token.", tests.Success, testID)
+
+			revoked, err = store.IsRevoked(ctx, jti)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to check revocation : %s.", tests.Failed, testID, err)
+			}
+			if !revoked {
+				t.Fatalf("\t%s\tTest %d:\tShould report the token as revoked.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report the token as revoked.", tests.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen using user tokens.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+			// User Gopher from the seed data.
+			const userID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+			const email = "user@example.com"
+
+			value, err := store.CreateUserToken(ctx, userID, email, token.PurposeReset, now, time.Hour)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a user token : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a user token.", tests.Success, testID)
+
+			if _, err := store.UseUserToken(ctx, token.PurposeVerify, value, now); !errors.Is(err, token.ErrInvalidToken) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to use a token for another purpose : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to use a token for another purpose.", tests.Success, testID)
+
+			ut, err := store.UseUserToken(ctx, token.PurposeReset, value, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to use the user token : %s.", tests.Failed, testID, err)
+			}
+			if ut.UserID != userID || ut.Email != email {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the owner of the token : got %s, %s.", tests.Failed, testID, ut.UserID, ut.Email)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to use the user token.", tests.Success, testID)
+
+			if _, err := store.UseUserToken(ctx, token.PurposeReset, value, now); !errors.Is(err, token.ErrInvalidToken) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to use a user token twice : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to use a user token twice.", tests.Success, testID)
+
+			expired, err := store.CreateUserToken(ctx, userID, email, token.PurposeReset, now, time.Hour)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a user token : %s.", tests.Failed, testID, err)
+			}
+			if _, err := store.UseUserToken(ctx, token.PurposeReset, expired, now.Add(time.Hour)); !errors.Is(err, token.ErrInvalidToken) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to use an expired user token : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to use an expired user token.", tests.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen using recovery codes.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+			// User Gopher from the seed data.
+			const userID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+
+			codes, err := store.CreateRecoveryCodes(ctx, userID, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create recovery codes : %s.", tests.Failed, testID, err)
+			}
+			if len(codes) != token.RecoveryCodes {
+				t.Fatalf("\t%s\tTest %d:\tShould get %d recovery codes : got %d.", tests.Failed, testID, token.RecoveryCodes, len(codes))
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create recovery codes.", tests.Success, testID)
+
+			typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", " "))
+			if err := store.UseRecoveryCode(ctx, userID, typed, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to use a recovery code as typed : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to use a recovery code as typed.", tests.Success, testID)
+
+			if err := store.UseRecoveryCode(ctx, userID, codes[0], now); !errors.Is(err, token.ErrInvalidToken) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to use a recovery code twice : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to use a recovery code twice.", tests.Success, testID)
+
+			if _, err := store.CreateRecoveryCodes(ctx, userID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to replace recovery codes : %s.", tests.Failed, testID, err)
+			}
+			if err := store.UseRecoveryCode(ctx, userID, codes[1], now); !errors.Is(err, token.ErrInvalidToken) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to use a replaced recovery code : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to use a replaced recovery code.", tests.Success, testID)
+		}
+	}
+}
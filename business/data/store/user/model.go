@@ -1,19 +1,27 @@
 package user
 
 import (
+	"database/sql"
 	"time"
 
 	"github.com/lib/pq"
 )
 
 type User struct {
-	ID           string         `db:"user_id" json:"id"`
-	Name         string         `db:"name" json:"name"`
-	Email        string         `db:"email" json:"email"`
-	Roles        pq.StringArray `db:"roles" json:"roles"`
-	PasswordHash []byte         `db:"password_hash" json:"-"`
-	DateCreated  time.Time      `db:"date_created" json:"date_created"`
-	DateUpdated  time.Time      `db:"date_updated" json:"date_updated"`
+	ID            string         `db:"user_id" json:"id"`
+	Name          string         `db:"name" json:"name"`
+	Email         string         `db:"email" json:"email" log:"redact"`
+	EmailVerified bool           `db:"email_verified" json:"email_verified"`
+	Roles         pq.StringArray `db:"roles" json:"roles"`
+	PasswordHash  []byte         `db:"password_hash" json:"-" log:"redact"`
+	DateCreated   time.Time      `db:"date_created" json:"date_created"`
+	DateUpdated   time.Time      `db:"date_updated" json:"date_updated"`
+	FailedLogins  int            `db:"failed_logins" json:"-"`
+	LockedUntil   sql.NullTime   `db:"locked_until" json:"-"`
+	MFASecret     sql.NullString `db:"mfa_secret" json:"-" log:"redact"`
+	MFAEnabled    bool           `db:"mfa_enabled" json:"mfa_enabled"`
+	MFARequired   bool           `db:"mfa_required" json:"mfa_required"`
+	MFALastStep   int64          `db:"mfa_last_step" json:"-"`
 }
 
 type NewUserDTO struct {
@@ -30,4 +38,33 @@ type UpdateUserDTO struct {
 	Roles           []string `json:"roles"`
 	Password        *string  `json:"password"`
 	PasswordConfirm *string  `json:"password_confirm" validate:"omitempty,eqfield=Password"`
+	MFARequired     *bool    `json:"mfa_required"`
+}
+
+// ResetPasswordDTO is the new password of a user along with the reset token
+// mailed to them.
+type ResetPasswordDTO struct {
+	Token           string `json:"token" validate:"required"`
+	Password        string `json:"password" validate:"required"`
+	PasswordConfirm string `json:"password_confirm" validate:"eqfield=Password"`
+}
+
+// QueryFilter holds the fields users can be listed by. Empty fields don't
+// filter, Cursor is the next_cursor returned with the previous page.
+type QueryFilter struct {
+	Name         string     `validate:"omitempty"`
+	Email        string     `validate:"omitempty,email"`
+	Role         string     `validate:"omitempty"`
+	CreatedAfter *time.Time `validate:"omitempty"`
+	OrderBy      string     `validate:"omitempty"`
+	Cursor       string     `validate:"omitempty"`
+	Limit        int        `validate:"omitempty,min=1,max=1000"`
+}
+
+// Lockout is the policy applied to failed logins. After MaxFailures failed
+// attempts in a row the account is locked for Duration. A zero MaxFailures
+// disables the lockout.
+type Lockout struct {
+	MaxFailures int
+	Duration    time.Duration
 }
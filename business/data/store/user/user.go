@@ -2,18 +2,36 @@ package user
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/dimashiro/service/business/auth"
 	"github.com/dimashiro/service/business/database"
 	"github.com/dimashiro/service/business/validate"
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/dimashiro/service/foundation/webapp"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrLocked is returned when a user tries to log in while the account is
+// locked after too many failed logins.
+var ErrLocked = errors.New("account locked")
+
+// Set of errors returned when MFA is enrolled in the wrong state.
+var (
+	ErrMFAEnabled     = validate.NewStatusError(http.StatusConflict, "mfa_enabled", "mfa already enabled")
+	ErrMFANotEnrolled = validate.NewStatusError(http.StatusConflict, "mfa_not_enrolled", "mfa not enrolled")
+)
+
+// dummyHash is compared against the password of unknown users so they take
+// as long to reject as known users.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
 // Store manages the set of API's for user access.
 type Store struct {
 	log *zap.SugaredLogger
@@ -28,6 +46,15 @@ func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
 	}
 }
 
+// Tran returns a copy of the Store that runs its queries inside the
+// provided transaction.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log: s.log,
+		db:  tx,
+	}
+}
+
 func (s Store) Create(ctx context.Context, nu NewUserDTO, now time.Time) (User, error) {
 	if err := validate.Check(nu); err != nil {
 		return User{}, fmt.Errorf("validating data: %w", err)
@@ -79,12 +106,16 @@ func (s Store) Update(ctx context.Context, claims auth.Claims, userID string, uu
 	if uu.Name != nil {
 		usr.Name = *uu.Name
 	}
-	if uu.Email != nil {
+	if uu.Email != nil && *uu.Email != usr.Email {
 		usr.Email = *uu.Email
+		usr.EmailVerified = false
 	}
 	if uu.Roles != nil {
 		usr.Roles = uu.Roles
 	}
+	if uu.MFARequired != nil {
+		usr.MFARequired = *uu.MFARequired
+	}
 	if uu.Password != nil {
 		pw, err := bcrypt.GenerateFromPassword([]byte(*uu.Password), bcrypt.DefaultCost)
 		if err != nil {
@@ -100,7 +131,9 @@ func (s Store) Update(ctx context.Context, claims auth.Claims, userID string, uu
 	SET 
 		"name" = :name,
 		"email" = :email,
+		"email_verified" = :email_verified,
 		"roles" = :roles,
+		"mfa_required" = :mfa_required,
 		"password_hash" = :password_hash,
 		"date_updated" = :date_updated
 	WHERE
@@ -119,7 +152,7 @@ func (s Store) Delete(ctx context.Context, claims auth.Claims, userID string) er
 		return database.ErrInvalidID
 	}
 
-	if !claims.Authorized(auth.RoleAdmin) && claims.Subject != userID {
+	if !claims.HasPermission(auth.PermUsersWrite) && claims.Subject != userID {
 		return database.ErrForbidden
 	}
 
@@ -142,30 +175,85 @@ func (s Store) Delete(ctx context.Context, claims auth.Claims, userID string) er
 	return nil
 }
 
-func (s Store) GetAll(ctx context.Context, pageNumber int, rowsPerPage int) ([]User, error) {
-	data := struct {
-		Offset      int `db:"offset"`
-		RowsPerPage int `db:"rows_per_page"`
-	}{
-		Offset:      (pageNumber - 1) * rowsPerPage,
-		RowsPerPage: rowsPerPage,
+// orderByFields maps the fields users can be ordered by to their columns.
+var orderByFields = map[string]string{
+	"id":      "user_id",
+	"name":    "name",
+	"email":   "email",
+	"created": "date_created",
+}
+
+// DefaultLimit is the number of users returned when no limit is provided.
+const DefaultLimit = 50
+
+// GetAll returns a page of the users matching the filter, along with the
+// cursor of the next page. The cursor is empty on the last page.
+func (s Store) GetAll(ctx context.Context, qf QueryFilter) ([]User, string, error) {
+	if err := validate.Check(qf); err != nil {
+		return nil, "", fmt.Errorf("validating filter: %w", err)
 	}
 
-	const q = `
+	orderBy, err := database.ParseOrderBy(orderByFields, qf.OrderBy, database.OrderBy{Field: "id", Direction: database.ASC})
+	if err != nil {
+		return nil, "", validate.FieldErrors{{Field: "order_by", Error: err.Error()}}
+	}
+
+	limit := qf.Limit
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+
+	filter := database.NewFilter()
+	if qf.Name != "" {
+		filter.Where("name ILIKE :name", "name", "%"+escapeLike(qf.Name)+"%")
+	}
+	if qf.Email != "" {
+		filter.Where("email = :email", "email", qf.Email)
+	}
+	if qf.Role != "" {
+		filter.Where(":role = ANY(roles)", "role", qf.Role)
+	}
+	if qf.CreatedAfter != nil {
+		filter.Where("date_created > :created_after", "created_after", *qf.CreatedAfter)
+	}
+
+	page, err := filter.Page(orderByFields, "user_id", orderBy, qf.Cursor, limit)
+	if err != nil {
+		return nil, "", validate.FieldErrors{{Field: "cursor", Error: err.Error()}}
+	}
+
+	q := `
 	SELECT
 		*
 	FROM
 		users
-	ORDER BY
-		user_id
-	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY`
+	` + page
 
 	var usrs []User
-	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, data, &usrs); err != nil {
-		return nil, fmt.Errorf("selecting users: %w", err)
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, filter.Data(), &usrs); err != nil {
+		return nil, "", fmt.Errorf("selecting users: %w", err)
 	}
 
-	return usrs, nil
+	if len(usrs) <= limit {
+		return usrs, "", nil
+	}
+
+	usrs = usrs[:limit]
+	last := usrs[limit-1]
+
+	var value string
+	switch orderBy.Field {
+	case "id":
+		value = last.ID
+	case "name":
+		value = last.Name
+	case "email":
+		value = last.Email
+	case "created":
+		value = last.DateCreated.Format(time.RFC3339Nano)
+	}
+
+	return usrs, database.NextCursor(orderBy, value, last.ID), nil
 }
 
 func (s Store) GetByID(ctx context.Context, claims auth.Claims, userID string) (User, error) {
@@ -173,7 +261,7 @@ func (s Store) GetByID(ctx context.Context, claims auth.Claims, userID string) (
 		return User{}, database.ErrInvalidID
 	}
 
-	if !claims.Authorized(auth.RoleAdmin) && claims.Subject != userID {
+	if !claims.HasPermission(auth.PermUsersRead) && claims.Subject != userID {
 		return User{}, database.ErrForbidden
 	}
 
@@ -199,9 +287,10 @@ func (s Store) GetByID(ctx context.Context, claims auth.Claims, userID string) (
 	return usr, nil
 }
 
-func (s Store) Authenticate(ctx context.Context, now time.Time, email, password string) (auth.Claims, error) {
+// QueryByEmail gets the specified user from the database by email.
+func (s Store) QueryByEmail(ctx context.Context, email string) (User, error) {
 	data := struct {
-		Email string `db:"email"`
+		Email string `db:"email" log:"redact"`
 	}{
 		Email: email,
 	}
@@ -212,26 +301,292 @@ func (s Store) Authenticate(ctx context.Context, now time.Time, email, password
 	FROM
 		users
 	WHERE
-		email=:email`
+		email = :email`
 
 	var usr User
 	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &usr); err != nil {
-		return auth.Claims{}, fmt.Errorf("selecting userID[%q]: %w", email, err)
+		return User{}, fmt.Errorf("selecting email[%q]: %w", email, err)
+	}
+
+	return usr, nil
+}
+
+// Authenticate finds a user by their email and verifies their password. On
+// success it returns the user so the caller can issue tokens. Unknown emails
+// and wrong passwords fail the same way and take about the same time, so the
+// result doesn't reveal which accounts exist. Failed attempts are counted
+// and lock the account according to the lockout policy.
+func (s Store) Authenticate(ctx context.Context, now time.Time, email, password string, lockout Lockout) (User, error) {
+	usr, err := s.QueryByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+			return User{}, database.ErrAuthenticationFailure
+		}
+		return User{}, err
+	}
+
+	if usr.LockedUntil.Valid && now.Before(usr.LockedUntil.Time) {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		s.log.Warnw("security", "event", "login_rejected", "reason", "account locked", "traceid", webapp.GetTraceID(ctx),
+			"userid", usr.ID, "until", usr.LockedUntil.Time)
+		return User{}, ErrLocked
 	}
 
 	if err := bcrypt.CompareHashAndPassword(usr.PasswordHash, []byte(password)); err != nil {
-		return auth.Claims{}, database.ErrAuthenticationFailure
+		if lockout.MaxFailures > 0 {
+			if err := s.recordFailure(ctx, usr.ID, now, lockout); err != nil {
+				return User{}, err
+			}
+		}
+		return User{}, database.ErrAuthenticationFailure
+	}
+
+	if usr.FailedLogins > 0 || usr.LockedUntil.Valid {
+		if err := s.resetFailures(ctx, usr.ID); err != nil {
+			return User{}, err
+		}
+		usr.FailedLogins = 0
+		usr.LockedUntil = sql.NullTime{}
 	}
 
-	claims := auth.Claims{
-		StandardClaims: jwt.StandardClaims{
-			Issuer:    "service project",
-			Subject:   usr.ID,
-			ExpiresAt: time.Now().Add(time.Hour).Unix(),
-			IssuedAt:  time.Now().UTC().Unix(),
-		},
-		Roles: usr.Roles,
+	return usr, nil
+}
+
+// recordFailure counts a failed login and locks the account once the policy
+// limit is reached. The counter starts over after a lockout.
+func (s Store) recordFailure(ctx context.Context, userID string, now time.Time, lockout Lockout) error {
+	data := struct {
+		UserID      string    `db:"user_id"`
+		MaxFailures int       `db:"max_failures"`
+		LockedUntil time.Time `db:"locked_until"`
+	}{
+		UserID:      userID,
+		MaxFailures: lockout.MaxFailures,
+		LockedUntil: now.Add(lockout.Duration),
 	}
 
-	return claims, nil
+	const q = `
+	UPDATE
+		users
+	SET
+		failed_logins = CASE WHEN failed_logins + 1 >= :max_failures THEN 0 ELSE failed_logins + 1 END,
+		locked_until = CASE WHEN failed_logins + 1 >= :max_failures THEN :locked_until ELSE locked_until END
+	WHERE
+		user_id = :user_id
+	RETURNING
+		*`
+
+	var usr User
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &usr); err != nil {
+		return fmt.Errorf("recording failed login userID[%q]: %w", userID, err)
+	}
+
+	if usr.FailedLogins == 0 {
+		s.log.Warnw("security", "event", "account_locked", "traceid", webapp.GetTraceID(ctx),
+			"userid", userID, "failures", lockout.MaxFailures, "until", usr.LockedUntil.Time)
+	}
+
+	return nil
+}
+
+// resetFailures clears the failed logins after a successful one.
+func (s Store) resetFailures(ctx context.Context, userID string) error {
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID,
+	}
+
+	const q = `
+	UPDATE
+		users
+	SET
+		failed_logins = 0,
+		locked_until = NULL
+	WHERE
+		user_id = :user_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("resetting failed logins userID[%q]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// SetPassword replaces the password of the user and lifts any lockout, as
+// done when a forgotten password is reset.
+func (s Store) SetPassword(ctx context.Context, userID string, password string, now time.Time) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("generating password hash: %w", err)
+	}
+
+	data := struct {
+		UserID       string    `db:"user_id"`
+		PasswordHash []byte    `db:"password_hash"`
+		DateUpdated  time.Time `db:"date_updated"`
+	}{
+		UserID:       userID,
+		PasswordHash: hash,
+		DateUpdated:  now,
+	}
+
+	const q = `
+	UPDATE
+		users
+	SET
+		"password_hash" = :password_hash,
+		"failed_logins" = 0,
+		"locked_until" = NULL,
+		"date_updated" = :date_updated
+	WHERE
+		user_id = :user_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("setting password userID[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// VerifyEmail marks the email of the user as verified. It fails with
+// ErrDBNotFound when the user no longer has that email.
+func (s Store) VerifyEmail(ctx context.Context, userID string, email string, now time.Time) error {
+	data := struct {
+		UserID      string    `db:"user_id"`
+		Email       string    `db:"email" log:"redact"`
+		DateUpdated time.Time `db:"date_updated"`
+	}{
+		UserID:      userID,
+		Email:       email,
+		DateUpdated: now,
+	}
+
+	const q = `
+	UPDATE
+		users
+	SET
+		"email_verified" = TRUE,
+		"date_updated" = :date_updated
+	WHERE
+		user_id = :user_id AND email = :email
+	RETURNING
+		*`
+
+	var usr User
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &usr); err != nil {
+		return fmt.Errorf("verifying email userID[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// SetMFASecret stores a new TOTP secret for the user. The secret is pending
+// until EnableMFA is called, once the user proved their app has it.
+func (s Store) SetMFASecret(ctx context.Context, userID string, secret string, now time.Time) error {
+	data := struct {
+		UserID      string    `db:"user_id"`
+		Secret      string    `db:"mfa_secret"`
+		DateUpdated time.Time `db:"date_updated"`
+	}{
+		UserID:      userID,
+		Secret:      secret,
+		DateUpdated: now,
+	}
+
+	const q = `
+	UPDATE
+		users
+	SET
+		"mfa_secret" = :mfa_secret,
+		"mfa_enabled" = FALSE,
+		"mfa_last_step" = 0,
+		"date_updated" = :date_updated
+	WHERE
+		user_id = :user_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("setting mfa secret userID[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// EnableMFA requires the pending TOTP secret on every login of the user.
+func (s Store) EnableMFA(ctx context.Context, userID string, now time.Time) error {
+	return s.setMFAEnabled(ctx, userID, true, now)
+}
+
+// DisableMFA removes the TOTP secret of the user.
+func (s Store) DisableMFA(ctx context.Context, userID string, now time.Time) error {
+	return s.setMFAEnabled(ctx, userID, false, now)
+}
+
+// setMFAEnabled switches MFA on or off. Switching it off drops the secret.
+func (s Store) setMFAEnabled(ctx context.Context, userID string, enabled bool, now time.Time) error {
+	data := struct {
+		UserID      string    `db:"user_id"`
+		Enabled     bool      `db:"mfa_enabled"`
+		DateUpdated time.Time `db:"date_updated"`
+	}{
+		UserID:      userID,
+		Enabled:     enabled,
+		DateUpdated: now,
+	}
+
+	const q = `
+	UPDATE
+		users
+	SET
+		"mfa_enabled" = :mfa_enabled,
+		"mfa_secret" = CASE WHEN :mfa_enabled THEN mfa_secret ELSE NULL END,
+		"mfa_last_step" = CASE WHEN :mfa_enabled THEN mfa_last_step ELSE 0 END,
+		"date_updated" = :date_updated
+	WHERE
+		user_id = :user_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("setting mfa enabled[%t] userID[%s]: %w", enabled, userID, err)
+	}
+
+	return nil
+}
+
+// UseMFAStep records the time step of a TOTP code the user logged in with,
+// so the same code can't be used again. It fails with ErrAuthenticationFailure
+// when the step, or a later one, was already used.
+func (s Store) UseMFAStep(ctx context.Context, userID string, step int64) error {
+	data := struct {
+		UserID string `db:"user_id"`
+		Step   int64  `db:"mfa_last_step"`
+	}{
+		UserID: userID,
+		Step:   step,
+	}
+
+	const q = `
+	UPDATE
+		users
+	SET
+		"mfa_last_step" = :mfa_last_step
+	WHERE
+		user_id = :user_id AND mfa_last_step < :mfa_last_step
+	RETURNING
+		*`
+
+	var usr User
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &usr); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return database.ErrAuthenticationFailure
+		}
+		return fmt.Errorf("using mfa step userID[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
 }
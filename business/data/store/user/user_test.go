@@ -11,6 +11,7 @@ import (
 	"github.com/dimashiro/service/business/data/store/user"
 	"github.com/dimashiro/service/business/data/tests"
 	"github.com/dimashiro/service/business/database"
+	"github.com/dimashiro/service/business/validate"
 	"github.com/dimashiro/service/foundation/docker"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/go-cmp/cmp"
@@ -89,7 +90,8 @@ func TestUser(t *testing.T) {
 					ExpiresAt: time.Now().Add(time.Hour).Unix(),
 					IssuedAt:  time.Now().UTC().Unix(),
 				},
-				Roles: []string{auth.RoleAdmin},
+				Roles:       []string{auth.RoleAdmin},
+				Permissions: []string{auth.PermUsersRead, auth.PermUsersWrite},
 			}
 
 			if err := store.Update(ctx, claims, usr.ID, upd, now); err != nil {
@@ -130,5 +132,131 @@ func TestUser(t *testing.T) {
 			}
 			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve user.", tests.Success, testID)
 		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen listing Users.", testID)
+		{
+			ctx := context.Background()
+
+			// Both seeded users share the same creation date, the id breaks the tie.
+			qf := user.QueryFilter{OrderBy: "created,desc", Limit: 1}
+
+			first, next, err := store.GetAll(ctx, qf)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to list users : %s.", tests.Failed, testID, err)
+			}
+			if len(first) != 1 || next == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get a page with a next cursor : got %d users, cursor %q.", tests.Failed, testID, len(first), next)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get a page with a next cursor.", tests.Success, testID)
+
+			qf.Cursor = next
+			second, next, err := store.GetAll(ctx, qf)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to list the next page : %s.", tests.Failed, testID, err)
+			}
+			if len(second) != 1 || next != "" || second[0].ID == first[0].ID {
+				t.Fatalf("\t%s\tTest %d:\tShould get the last page : got %d users, cursor %q.", tests.Failed, testID, len(second), next)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the last page.", tests.Success, testID)
+
+			qf = user.QueryFilter{OrderBy: "name", Cursor: qf.Cursor}
+			if _, _, err := store.GetAll(ctx, qf); !validate.IsFieldErrors(err) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept a cursor of another order : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept a cursor of another order.", tests.Success, testID)
+
+			admins, _, err := store.GetAll(ctx, user.QueryFilter{Role: auth.RoleAdmin})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to filter users by role : %s.", tests.Failed, testID, err)
+			}
+			if len(admins) != 1 || admins[0].Email != "admin@example.com" {
+				t.Fatalf("\t%s\tTest %d:\tShould only get the admins : got %+v.", tests.Failed, testID, admins)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only get the admins.", tests.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen logging in with wrong credentials.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+			lockout := user.Lockout{MaxFailures: 2, Duration: time.Hour}
+
+			if _, err := store.Authenticate(ctx, now, "unknown@example.com", "gophers", lockout); !errors.Is(err, database.ErrAuthenticationFailure) {
+				t.Fatalf("\t%s\tTest %d:\tShould fail the same way for an unknown email : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fail the same way for an unknown email.", tests.Success, testID)
+
+			for i := 0; i < lockout.MaxFailures; i++ {
+				if _, err := store.Authenticate(ctx, now, "user@example.com", "wrong", lockout); !errors.Is(err, database.ErrAuthenticationFailure) {
+					t.Fatalf("\t%s\tTest %d:\tShould NOT accept a wrong password : %v.", tests.Failed, testID, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept a wrong password.", tests.Success, testID)
+
+			if _, err := store.Authenticate(ctx, now, "user@example.com", "gophers", lockout); !errors.Is(err, user.ErrLocked) {
+				t.Fatalf("\t%s\tTest %d:\tShould lock the account after too many failures : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould lock the account after too many failures.", tests.Success, testID)
+
+			usr, err := store.Authenticate(ctx, now.Add(lockout.Duration), "user@example.com", "gophers", lockout)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould unlock the account after the lockout : %v.", tests.Failed, testID, err)
+			}
+			if usr.FailedLogins != 0 || usr.LockedUntil.Valid {
+				t.Fatalf("\t%s\tTest %d:\tShould reset the failures on success : %d, %v.", tests.Failed, testID, usr.FailedLogins, usr.LockedUntil)
+			}
+			t.Logf("\t%s\tTest %d:\tShould unlock the account after the lockout.", tests.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen enabling MFA.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+			usr, err := store.QueryByEmail(ctx, "admin@example.com")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve user by email : %s.", tests.Failed, testID, err)
+			}
+
+			if err := store.SetMFASecret(ctx, usr.ID, "JBSWY3DPEHPK3PXP", now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to set the MFA secret : %s.", tests.Failed, testID, err)
+			}
+			if err := store.EnableMFA(ctx, usr.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to enable MFA : %s.", tests.Failed, testID, err)
+			}
+
+			usr, err = store.QueryByEmail(ctx, "admin@example.com")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve user by email : %s.", tests.Failed, testID, err)
+			}
+			if !usr.MFAEnabled || usr.MFASecret.String != "JBSWY3DPEHPK3PXP" {
+				t.Fatalf("\t%s\tTest %d:\tShould see MFA enabled : %v, %v.", tests.Failed, testID, usr.MFAEnabled, usr.MFASecret)
+			}
+			t.Logf("\t%s\tTest %d:\tShould see MFA enabled.", tests.Success, testID)
+
+			step := now.Unix() / 30
+			if err := store.UseMFAStep(ctx, usr.ID, step); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to use a time step : %s.", tests.Failed, testID, err)
+			}
+			if err := store.UseMFAStep(ctx, usr.ID, step); !errors.Is(err, database.ErrAuthenticationFailure) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to use a time step twice : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to use a time step twice.", tests.Success, testID)
+
+			if err := store.DisableMFA(ctx, usr.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to disable MFA : %s.", tests.Failed, testID, err)
+			}
+			usr, err = store.QueryByEmail(ctx, "admin@example.com")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve user by email : %s.", tests.Failed, testID, err)
+			}
+			if usr.MFAEnabled || usr.MFASecret.Valid {
+				t.Fatalf("\t%s\tTest %d:\tShould drop the secret when MFA is disabled.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould drop the secret when MFA is disabled.", tests.Success, testID)
+		}
 	}
 }
@@ -5,6 +5,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"fmt"
@@ -12,7 +13,10 @@ import (
 	"time"
 
 	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/core/apikey"
 	"github.com/dimashiro/service/business/data/schema"
+	"github.com/dimashiro/service/business/data/store/role"
+	"github.com/dimashiro/service/business/data/store/token"
 	"github.com/dimashiro/service/business/data/store/user"
 	"github.com/dimashiro/service/business/database"
 	"github.com/dimashiro/service/foundation/docker"
@@ -86,27 +90,27 @@ func NewUnit(t *testing.T, c *docker.Container, dbName string) (*zap.SugaredLogg
 		t.Fatalf("Opening database connection: %v", err)
 	}
 
+	var buf bytes.Buffer
+	encoder := zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+	writer := bufio.NewWriter(&buf)
+	log := zap.New(
+		zapcore.NewCore(encoder, zapcore.AddSync(writer), zapcore.DebugLevel)).
+		Sugar()
+
 	t.Log("Migrate and seed database ...")
 
-	if err := schema.Migrate(ctx, db); err != nil {
+	if err := schema.Migrate(ctx, log, db); err != nil {
 		docker.DumpContainerLogs(t, c.ID)
 		t.Fatalf("Migrating error: %s", err)
 	}
 
-	if err := schema.Seed(ctx, db); err != nil {
+	if err := schema.Seed(ctx, log, db, schema.SeedDev); err != nil {
 		docker.DumpContainerLogs(t, c.ID)
 		t.Fatalf("Seeding error: %s", err)
 	}
 
 	t.Log("Ready for testing ...")
 
-	var buf bytes.Buffer
-	encoder := zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
-	writer := bufio.NewWriter(&buf)
-	log := zap.New(
-		zapcore.NewCore(encoder, zapcore.AddSync(writer), zapcore.DebugLevel)).
-		Sugar()
-
 	// teardown is the function that should be invoked when the caller is done
 	// with the database.
 	teardown := func() {
@@ -129,6 +133,7 @@ type Test struct {
 	DB       *sqlx.DB
 	Log      *zap.SugaredLogger
 	Auth     *auth.Auth
+	Keys     *keystore.KeyStore
 	Teardown func()
 
 	t *testing.T
@@ -145,7 +150,8 @@ func NewIntegration(t *testing.T, c *docker.Container, dbName string) *Test {
 	}
 
 	// Build an authenticator using this private key and id for the key store.
-	auth, err := auth.New(keyID, keystore.NewMap(map[string]*rsa.PrivateKey{keyID: privateKey}))
+	ks := keystore.NewMap(map[string]crypto.Signer{keyID: privateKey})
+	auth, err := auth.New("http://localhost:3000", keyID, ks, token.NewStore(log, db), apikey.NewCore(log, db))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -154,6 +160,7 @@ func NewIntegration(t *testing.T, c *docker.Container, dbName string) *Test {
 		DB:       db,
 		Log:      log,
 		Auth:     auth,
+		Keys:     ks,
 		t:        t,
 		Teardown: teardown,
 	}
@@ -167,17 +174,25 @@ func (test *Test) Token(email, pass string) string {
 	test.t.Log("Generating token for test ...")
 
 	store := user.NewStore(test.Log, test.DB)
-	claims, err := store.Authenticate(context.Background(), time.Now(), email, pass)
+	usr, err := store.Authenticate(context.Background(), time.Now(), email, pass, user.Lockout{})
 	if err != nil {
 		test.t.Fatal(err)
 	}
 
-	token, err := test.Auth.GenerateToken(claims)
+	perms, err := role.NewStore(test.Log, test.DB).Permissions(context.Background(), usr.Roles)
+	if err != nil {
+		test.t.Fatal(err)
+	}
+
+	claims := auth.NewClaims(usr.ID, usr.Roles, time.Now(), time.Hour)
+	claims.Permissions = perms
+
+	tkn, err := test.Auth.GenerateToken(claims)
 	if err != nil {
 		test.t.Fatal(err)
 	}
 
-	return token
+	return tkn
 }
 
 // StringPointer is a helper to get a *string from a string. It is in the tests
@@ -2,16 +2,20 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"net/url"
 	"reflect"
-	"strings"
 	"time"
 
 	"github.com/dimashiro/service/foundation/webapp"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -88,24 +92,90 @@ func StatusCheck(ctx context.Context, db *sqlx.DB) error {
 	return db.QueryRowContext(ctx, q).Scan(&tmp)
 }
 
+// Transactor is the behavior required to begin a transaction. It is
+// implemented by *sqlx.DB.
+type Transactor interface {
+	BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error)
+}
+
+// WithinTran runs the function inside a transaction. The transaction is
+// committed when the function returns without error and rolled back when it
+// fails, panics or the context is cancelled. A panic is re-raised after the
+// rollback.
+func WithinTran(ctx context.Context, log *zap.SugaredLogger, db Transactor, fn func(tx sqlx.ExtContext) error) error {
+	traceID := webapp.GetTraceID(ctx)
+
+	logTran(log, "begin tran", "traceid", traceID)
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tran: %w", err)
+	}
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			logTran(log, "rollback tran", "traceid", traceID, "reason", "panic")
+			if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+				log.Errorw("rollback tran", "traceid", traceID, "ERROR", rbErr)
+			}
+			panic(rec)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		logTran(log, "rollback tran", "traceid", traceID)
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return fmt.Errorf("rollback tran: %v: %w", rbErr, err)
+		}
+		return err
+	}
+
+	// The driver already rolled back the transaction if the context was
+	// cancelled, don't report a commit failure in that case.
+	if err := ctx.Err(); err != nil {
+		logTran(log, "rollback tran", "traceid", traceID, "reason", "context done")
+		tx.Rollback()
+		return fmt.Errorf("commit tran: %w", err)
+	}
+
+	logTran(log, "commit tran", "traceid", traceID)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tran: %w", err)
+	}
+
+	return nil
+}
+
 // NamedExecContext is a helper function to execute a CUD operation with
 // logging and tracing.
-func NamedExecContext(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data interface{}) error {
-	q := queryString(query, data)
-	log.Infow("database.NamedExecContext", "traceid", webapp.GetTraceID(ctx), "query", q)
+func NamedExecContext(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data interface{}) (err error) {
+	name, start := caller(), time.Now()
+	var n int64
+	defer func() { observe(ctx, log, "database.NamedExecContext", name, query, data, start, n, err) }()
+
+	ctx, span := startSpan(ctx, "database.NamedExecContext", query)
+	defer func() { endSpan(span, err) }()
 
-	if _, err := sqlx.NamedExecContext(ctx, db, query, data); err != nil {
+	res, err := sqlx.NamedExecContext(ctx, db, query, data)
+	if err != nil {
 		return err
 	}
 
+	// The count is only used for instrumentation, a driver that can't
+	// report it leaves it at zero.
+	n, _ = res.RowsAffected()
+
 	return nil
 }
 
 // NamedQuerySlice is a helper function for executing queries that return a
 // collection of data to be unmarshaled into a slice.
-func NamedQuerySlice(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data interface{}, dest interface{}) error {
-	q := queryString(query, data)
-	log.Infow("database.NamedQuerySlice", "traceid", webapp.GetTraceID(ctx), "query", q)
+func NamedQuerySlice(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data interface{}, dest interface{}) (err error) {
+	name, start := caller(), time.Now()
+	var n int64
+	defer func() { observe(ctx, log, "database.NamedQuerySlice", name, query, data, start, n, err) }()
+
+	ctx, span := startSpan(ctx, "database.NamedQuerySlice", query)
+	defer func() { endSpan(span, err) }()
 
 	val := reflect.ValueOf(dest)
 	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Slice {
@@ -116,6 +186,7 @@ func NamedQuerySlice(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtCon
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
 	slice := val.Elem()
 	for rows.Next() {
@@ -124,54 +195,60 @@ func NamedQuerySlice(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtCon
 			return err
 		}
 		slice.Set(reflect.Append(slice, v.Elem()))
+		n++
 	}
 
-	return nil
+	return rows.Err()
 }
 
 // NamedQueryStruct is a helper function for executing queries that return a
 // single value to be unmarshalled into a struct type.
-func NamedQueryStruct(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data interface{}, dest interface{}) error {
-	q := queryString(query, data)
-	log.Infow("database.NamedQueryStruct", "traceid", webapp.GetTraceID(ctx), "query", q)
+func NamedQueryStruct(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data interface{}, dest interface{}) (err error) {
+	name, start := caller(), time.Now()
+	var n int64
+	defer func() { observe(ctx, log, "database.NamedQueryStruct", name, query, data, start, n, err) }()
+
+	ctx, span := startSpan(ctx, "database.NamedQueryStruct", query)
+	defer func() { endSpan(span, err) }()
 
 	rows, err := sqlx.NamedQueryContext(ctx, db, query, data)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
+
 	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
 		return ErrDBNotFound
 	}
 
 	if err := rows.StructScan(dest); err != nil {
 		return err
 	}
+	n = 1
 
 	return nil
 }
 
-// queryString provides a pretty print version of the query and parameters.
-func queryString(query string, args ...interface{}) string {
-	query, params, err := sqlx.Named(query, args)
-	if err != nil {
-		return err.Error()
-	}
-
-	for _, param := range params {
-		var value string
-		switch v := param.(type) {
-		case string:
-			value = fmt.Sprintf("%q", v)
-		case []byte:
-			value = fmt.Sprintf("%q", string(v))
-		default:
-			value = fmt.Sprintf("%v", v)
-		}
-		query = strings.Replace(query, "?", value, 1)
-	}
-
-	query = strings.ReplaceAll(query, "\t", "")
-	query = strings.ReplaceAll(query, "\n", " ")
+// startSpan starts a client span for a database call. The statement is
+// recorded without its parameters.
+func startSpan(ctx context.Context, name string, query string) (context.Context, trace.Span) {
+	return otel.GetTracerProvider().Tracer("database").Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBStatementKey.String(query),
+		),
+	)
+}
 
-	return strings.Trim(query, " ")
+// endSpan ends the span, recording the error unless no rows were found.
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, ErrDBNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
 }
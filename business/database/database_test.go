@@ -0,0 +1,136 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/data/store/user"
+	"github.com/dimashiro/service/business/data/tests"
+	"github.com/dimashiro/service/business/database"
+	"github.com/dimashiro/service/foundation/docker"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jmoiron/sqlx"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = tests.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer tests.StopDB(c)
+
+	m.Run()
+}
+
+func TestWithinTran(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t, c, "testtran")
+	t.Cleanup(teardown)
+
+	store := user.NewStore(log, db)
+
+	ctx := context.Background()
+	now := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	admin := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "this service",
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			IssuedAt:  time.Now().UTC().Unix(),
+		},
+		Roles: []string{auth.RoleAdmin},
+	}
+
+	newUser := func(email string) user.NewUserDTO {
+		return user.NewUserDTO{
+			Name:            "Tran Gopher",
+			Email:           email,
+			Roles:           []string{auth.RoleUser},
+			Password:        "gopher",
+			PasswordConfirm: "gopher",
+		}
+	}
+
+	t.Log("Given the need to run several store calls in one transaction.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the function succeeds.", testID)
+		{
+			var usr user.User
+			err := database.WithinTran(ctx, log, db, func(tx sqlx.ExtContext) error {
+				var err error
+				usr, err = store.Tran(tx).Create(ctx, newUser("commit@example.com"), now)
+				return err
+			})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to commit the transaction : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to commit the transaction.", tests.Success, testID)
+
+			if _, err := store.GetByID(ctx, admin, usr.ID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the committed user : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve the committed user.", tests.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen the function fails.", testID)
+		{
+			errAbort := errors.New("abort")
+
+			var usr user.User
+			err := database.WithinTran(ctx, log, db, func(tx sqlx.ExtContext) error {
+				var err error
+				usr, err = store.Tran(tx).Create(ctx, newUser("rollback@example.com"), now)
+				if err != nil {
+					return err
+				}
+				return errAbort
+			})
+			if !errors.Is(err, errAbort) {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the function error : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the function error.", tests.Success, testID)
+
+			if _, err := store.GetByID(ctx, admin, usr.ID); !errors.Is(err, database.ErrDBNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve the rolled back user : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve the rolled back user.", tests.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen the function panics.", testID)
+		{
+			var usr user.User
+			func() {
+				defer func() {
+					if rec := recover(); rec == nil {
+						t.Fatalf("\t%s\tTest %d:\tShould re-raise the panic.", tests.Failed, testID)
+					}
+					t.Logf("\t%s\tTest %d:\tShould re-raise the panic.", tests.Success, testID)
+				}()
+
+				database.WithinTran(ctx, log, db, func(tx sqlx.ExtContext) error {
+					var err error
+					usr, err = store.Tran(tx).Create(ctx, newUser("panic@example.com"), now)
+					if err != nil {
+						return err
+					}
+					panic("boom")
+				})
+			}()
+
+			if _, err := store.GetByID(ctx, admin, usr.ID); !errors.Is(err, database.ErrDBNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve the rolled back user : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve the rolled back user.", tests.Success, testID)
+		}
+	}
+}
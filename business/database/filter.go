@@ -0,0 +1,166 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Set of directions for ordering results.
+const (
+	ASC  = "ASC"
+	DESC = "DESC"
+)
+
+// OrderBy is a validated field and direction to order results by.
+type OrderBy struct {
+	Field     string
+	Direction string
+}
+
+// ParseOrderBy parses an order of the form "field" or "field,desc". The field
+// must be a key of the fields map, which maps the names exposed by the API to
+// their columns. An empty order returns the default.
+func ParseOrderBy(fields map[string]string, orderBy string, defaultOrder OrderBy) (OrderBy, error) {
+	if orderBy == "" {
+		return defaultOrder, nil
+	}
+
+	parts := strings.Split(orderBy, ",")
+	if len(parts) > 2 {
+		return OrderBy{}, fmt.Errorf("unknown order %q", orderBy)
+	}
+
+	field := strings.TrimSpace(parts[0])
+	if _, exists := fields[field]; !exists {
+		return OrderBy{}, fmt.Errorf("unknown order field %q", field)
+	}
+
+	direction := ASC
+	if len(parts) == 2 {
+		direction = strings.ToUpper(strings.TrimSpace(parts[1]))
+		if direction != ASC && direction != DESC {
+			return OrderBy{}, fmt.Errorf("unknown order direction %q", parts[1])
+		}
+	}
+
+	return OrderBy{Field: field, Direction: direction}, nil
+}
+
+// String returns the order in the format accepted by ParseOrderBy.
+func (ob OrderBy) String() string {
+	return ob.Field + "," + strings.ToLower(ob.Direction)
+}
+
+// =============================================================================
+
+// Cursor is the position of the last row of a page. The next page starts
+// right after it, which keeps paging stable under concurrent inserts. The
+// order is kept in the cursor so it can't be reused with another one.
+type Cursor struct {
+	OrderBy string `json:"o"`
+	Value   string `json:"v"`
+	ID      string `json:"id"`
+}
+
+// EncodeCursor returns the opaque representation of the cursor.
+func EncodeCursor(c Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses an opaque cursor returned by EncodeCursor.
+func DecodeCursor(s string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, errors.New("malformed cursor")
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Cursor{}, errors.New("malformed cursor")
+	}
+
+	return c, nil
+}
+
+// =============================================================================
+
+// Filter builds the WHERE, ORDER BY and LIMIT clauses of a list query along
+// with the named parameters they use.
+type Filter struct {
+	conds []string
+	data  map[string]interface{}
+}
+
+// NewFilter constructs an empty filter.
+func NewFilter() *Filter {
+	return &Filter{
+		data: make(map[string]interface{}),
+	}
+}
+
+// Where adds a condition to the filter. The condition refers to the value
+// with a named parameter, like "email = :email".
+func (f *Filter) Where(cond string, name string, value interface{}) {
+	f.conds = append(f.conds, cond)
+	f.data[name] = value
+}
+
+// Data returns the named parameters of the query.
+func (f *Filter) Data() map[string]interface{} {
+	return f.data
+}
+
+// Page adds the keyset condition for the cursor and returns the clauses to
+// append to the SELECT. The id column breaks ties between rows with the same
+// value. One extra row is fetched so the caller knows if there is a next page.
+func (f *Filter) Page(fields map[string]string, idColumn string, orderBy OrderBy, cursor string, limit int) (string, error) {
+	column, exists := fields[orderBy.Field]
+	if !exists {
+		return "", fmt.Errorf("unknown order field %q", orderBy.Field)
+	}
+
+	op := ">"
+	if orderBy.Direction == DESC {
+		op = "<"
+	}
+
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+		if err != nil {
+			return "", err
+		}
+		if c.OrderBy != orderBy.String() {
+			return "", errors.New("cursor doesn't match the order")
+		}
+
+		cond := fmt.Sprintf("(%s, %s) %s (:cursor_value, :cursor_id)", column, idColumn, op)
+		f.Where(cond, "cursor_value", c.Value)
+		f.data["cursor_id"] = c.ID
+	}
+
+	f.data["limit"] = limit + 1
+
+	var b strings.Builder
+	if len(f.conds) > 0 {
+		b.WriteString("WHERE\n\t\t")
+		b.WriteString(strings.Join(f.conds, "\n\t\tAND "))
+		b.WriteString("\n\t")
+	}
+	fmt.Fprintf(&b, "ORDER BY\n\t\t%s %s, %s %s\n\tLIMIT :limit", column, orderBy.Direction, idColumn, orderBy.Direction)
+
+	return b.String(), nil
+}
+
+// NextCursor returns the cursor of the page starting after the row with the
+// value and id. It is only needed when Page fetched the extra row.
+func NextCursor(orderBy OrderBy, value string, id string) string {
+	return EncodeCursor(Cursor{
+		OrderBy: orderBy.String(),
+		Value:   value,
+		ID:      id,
+	})
+}
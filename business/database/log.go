@@ -0,0 +1,244 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/dimashiro/service/business/metrics"
+	"github.com/dimashiro/service/foundation/webapp"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultRedact is the set of columns whose values are never logged, whatever
+// struct or map they are bound from. The cursor value of a page repeats the
+// value of the column the page is ordered by, which can be an email.
+var DefaultRedact = []string{"password_hash", "token_hash", "key_hash", "code_hash", "mfa_secret", "email", "cursor_value"}
+
+// LogConfig controls how the queries are logged. Values of the columns listed
+// in Redact, and of the struct fields tagged `log:"redact"`, are replaced in
+// the logged query. With NamesOnly no value is logged at all, the query is
+// logged with its named parameters. Queries slower than SlowThreshold are
+// logged as warnings, a zero threshold disables the detection.
+type LogConfig struct {
+	Level         zapcore.Level
+	NamesOnly     bool
+	Redact        []string
+	SlowThreshold time.Duration
+}
+
+// defaultLogConfig applies to the loggers without a LogConfig of their own.
+var defaultLogConfig = LogConfig{
+	Level:  zapcore.InfoLevel,
+	Redact: DefaultRedact,
+}
+
+// configCore carries the LogConfig of a logger along with its core.
+type configCore struct {
+	zapcore.Core
+	cfg LogConfig
+}
+
+// With keeps the LogConfig on the loggers derived with fields.
+func (c configCore) With(fields []zapcore.Field) zapcore.Core {
+	return configCore{
+		Core: c.Core.With(fields),
+		cfg:  c.cfg,
+	}
+}
+
+// WithLogConfig returns a copy of the logger whose queries are logged as
+// configured. The stores log their queries following the configuration of
+// the logger they are constructed with, the default one logs them at info
+// level with DefaultRedact.
+func WithLogConfig(log *zap.SugaredLogger, cfg LogConfig) *zap.SugaredLogger {
+	wrap := func(core zapcore.Core) zapcore.Core {
+		return configCore{
+			Core: core,
+			cfg:  cfg,
+		}
+	}
+	return log.Desugar().WithOptions(zap.WrapCore(wrap)).Sugar()
+}
+
+// logConfig returns the LogConfig of the logger.
+func logConfig(log *zap.SugaredLogger) LogConfig {
+	if c, ok := log.Desugar().Core().(configCore); ok {
+		return c.cfg
+	}
+	return defaultLogConfig
+}
+
+// redacted replaces the value of a redacted parameter in the logs.
+type redacted struct{}
+
+func (redacted) String() string { return "[REDACTED]" }
+
+// mapper maps the struct fields to their columns the way sqlx binds them.
+var mapper = reflectx.NewMapperFunc("db", sqlx.NameMapper)
+
+// observe records the metrics of a query run by the named store method that
+// started at the provided time, and logs it. A query slower than the
+// threshold is logged as a warning whatever the level of the query logs.
+func observe(ctx context.Context, log *zap.SugaredLogger, msg string, name string, query string, data interface{}, start time.Time, rows int64, err error) {
+	since := time.Since(start)
+	failed := err != nil && !errors.Is(err, ErrDBNotFound)
+	metrics.ObserveQuery(name, failed, rows, since)
+
+	cfg := logConfig(log)
+
+	level := cfg.Level
+	if cfg.SlowThreshold > 0 && since >= cfg.SlowThreshold && level < zapcore.WarnLevel {
+		level, msg = zapcore.WarnLevel, "database slow query"
+	}
+	if !log.Desugar().Core().Enabled(level) {
+		return
+	}
+
+	var q string
+	switch {
+	case cfg.NamesOnly:
+		q = compact(query)
+	default:
+		q = queryString(query, redact(data, cfg.Redact))
+	}
+
+	kv := []interface{}{"traceid", webapp.GetTraceID(ctx), "name", name, "query", q, "duration", since.String(), "rows", rows}
+	if failed {
+		kv = append(kv, "ERROR", err)
+	}
+	logw(log, level, msg, kv...)
+}
+
+// caller returns the name of the function that called the database helper,
+// like user.Store.QueryByID. It names the query in the metrics.
+func caller() string {
+	pc, _, _, ok := runtime.Caller(2)
+	if !ok {
+		return "unknown"
+	}
+
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return "unknown"
+	}
+
+	name := fn.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	return name
+}
+
+// logTran logs a transaction event at the level of the queries.
+func logTran(log *zap.SugaredLogger, msg string, keysAndValues ...interface{}) {
+	cfg := logConfig(log)
+	logw(log, cfg.Level, msg, keysAndValues...)
+}
+
+// logw logs the message at the provided level.
+func logw(log *zap.SugaredLogger, level zapcore.Level, msg string, keysAndValues ...interface{}) {
+	switch level {
+	case zapcore.DebugLevel:
+		log.Debugw(msg, keysAndValues...)
+	case zapcore.WarnLevel:
+		log.Warnw(msg, keysAndValues...)
+	case zapcore.ErrorLevel:
+		log.Errorw(msg, keysAndValues...)
+	default:
+		log.Infow(msg, keysAndValues...)
+	}
+}
+
+// redact returns the parameters of the query with the values of the redacted
+// columns replaced. Values other than maps and structs are returned as is.
+func redact(data interface{}, columns []string) interface{} {
+	isRedacted := func(name string) bool {
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			name = name[i+1:]
+		}
+		for _, c := range columns {
+			if strings.EqualFold(c, name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if m, ok := data.(map[string]interface{}); ok {
+		params := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			if isRedacted(k) {
+				v = redacted{}
+			}
+			params[k] = v
+		}
+		return params
+	}
+
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return data
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return data
+	}
+
+	tm := mapper.TypeMap(v.Type())
+	params := make(map[string]interface{}, len(tm.Names))
+	for name, f := range mapper.FieldMap(v) {
+		if !f.CanInterface() {
+			continue
+		}
+		fi := tm.GetByPath(name)
+		if (fi != nil && fi.Field.Tag.Get("log") == "redact") || isRedacted(name) {
+			params[name] = redacted{}
+			continue
+		}
+		params[name] = f.Interface()
+	}
+
+	return params
+}
+
+// queryString provides a pretty print version of the query and parameters.
+func queryString(query string, data interface{}) string {
+	query, params, err := sqlx.Named(query, data)
+	if err != nil {
+		return err.Error()
+	}
+
+	for _, param := range params {
+		var value string
+		switch v := param.(type) {
+		case string:
+			value = fmt.Sprintf("%q", v)
+		case []byte:
+			value = fmt.Sprintf("%q", string(v))
+		default:
+			value = fmt.Sprintf("%v", v)
+		}
+		query = strings.Replace(query, "?", value, 1)
+	}
+
+	return compact(query)
+}
+
+// compact puts the query on a single line.
+func compact(query string) string {
+	query = strings.ReplaceAll(query, "\t", "")
+	query = strings.ReplaceAll(query, "\n", " ")
+
+	return strings.Trim(query, " ")
+}
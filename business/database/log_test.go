@@ -0,0 +1,147 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestQueryString(t *testing.T) {
+	const q = `
+	INSERT INTO users
+		(user_id, email, password_hash, name)
+	VALUES
+		(:user_id, :email, :password_hash, :name)`
+
+	type user struct {
+		ID           string `db:"user_id"`
+		Email        string `db:"email" log:"redact"`
+		PasswordHash []byte `db:"password_hash"`
+		Name         string `db:"name"`
+	}
+
+	usr := user{
+		ID:           "45b5fbd3-755f-4379-8f07-a58d4a30fa2f",
+		Email:        "user@example.com",
+		PasswordHash: []byte("$2a$10$1ggfMVZV6Js0ybvJufLRUOWHS5f6KneuP0XwwHpJ8L8ipdry9f2/a"),
+		Name:         "User Gopher",
+	}
+
+	t.Log("Given the need to keep secrets out of the query logs.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen logging the parameters of a struct.", testID)
+		{
+			got := queryString(q, redact(usr, DefaultRedact))
+
+			for _, secret := range []string{usr.Email, string(usr.PasswordHash)} {
+				if strings.Contains(got, secret) {
+					t.Fatalf("\t%s\tTest %d:\tShould redact the secret values : %s", failed, testID, got)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould redact the secret values.", success, testID)
+
+			if !strings.Contains(got, usr.ID) || !strings.Contains(got, usr.Name) {
+				t.Fatalf("\t%s\tTest %d:\tShould log the other values : %s", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould log the other values.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen logging the parameters of a map.", testID)
+		{
+			data := map[string]interface{}{
+				"user_id":       usr.ID,
+				"email":         usr.Email,
+				"password_hash": usr.PasswordHash,
+				"name":          usr.Name,
+				"cursor_value":  usr.Email,
+			}
+
+			// A page of users filtered by email and ordered by email.
+			const page = `
+			SELECT * FROM users
+			WHERE email = :email AND name = :name AND password_hash = :password_hash
+				AND (email, user_id) > (:cursor_value, :user_id)`
+
+			got := queryString(page, redact(data, DefaultRedact))
+
+			for _, secret := range []string{usr.Email, string(usr.PasswordHash)} {
+				if strings.Contains(got, secret) {
+					t.Fatalf("\t%s\tTest %d:\tShould redact the denied columns : %s", failed, testID, got)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould redact the denied columns.", success, testID)
+
+			if !strings.Contains(got, usr.ID) || !strings.Contains(got, usr.Name) {
+				t.Fatalf("\t%s\tTest %d:\tShould log the other values : %s", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould log the other values.", success, testID)
+		}
+	}
+}
+
+// helper stands for a database helper, the caller is the function using it.
+func helper() string {
+	return caller()
+}
+
+func TestCaller(t *testing.T) {
+	t.Log("Given the need to name the queries after the store methods.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a function calls a database helper.", testID)
+		{
+			if got, exp := helper(), "database.TestCaller"; got != exp {
+				t.Fatalf("\t%s\tTest %d:\tShould name the calling function %q : got %q", failed, testID, exp, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould name the calling function.", success, testID)
+		}
+	}
+}
+
+func TestLogConfig(t *testing.T) {
+	cfg := LogConfig{
+		Level:         zapcore.DebugLevel,
+		NamesOnly:     true,
+		SlowThreshold: time.Second,
+	}
+
+	t.Log("Given the need to configure the query logs of a logger.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the logger has no configuration.", testID)
+		{
+			got := logConfig(zap.NewNop().Sugar())
+			if got.Level != zapcore.InfoLevel || got.NamesOnly || len(got.Redact) != len(DefaultRedact) {
+				t.Fatalf("\t%s\tTest %d:\tShould get the default configuration : got %+v", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the default configuration.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen the logger holds a configuration.", testID)
+		{
+			log := WithLogConfig(zap.NewNop().Sugar(), cfg)
+
+			if got := logConfig(log); got.Level != cfg.Level || !got.NamesOnly || got.SlowThreshold != cfg.SlowThreshold {
+				t.Fatalf("\t%s\tTest %d:\tShould get its configuration : got %+v", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get its configuration.", success, testID)
+
+			if got := logConfig(log.With("service", "retail-api")); !got.NamesOnly {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the configuration on derived loggers : got %+v", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the configuration on derived loggers.", success, testID)
+		}
+	}
+}
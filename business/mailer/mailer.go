@@ -0,0 +1,233 @@
+// Package mailer sends the emails of the service. The SMTP mailer is meant
+// for production, the file and log mailers for tests and local development.
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dimashiro/service/foundation/webapp"
+	"go.uber.org/zap"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer is the behavior required to send emails.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// format renders the message in the internet message format.
+func format(from string, msg Message, now time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// validate rejects header injection through the recipient or the subject.
+func validate(msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return fmt.Errorf("invalid message header")
+	}
+	return nil
+}
+
+// =============================================================================
+
+// SMTPConfig holds the settings of the SMTP server. Timeout bounds the whole
+// exchange with the server, dial included, when the context has no earlier
+// deadline.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	From     string
+	Timeout  time.Duration
+}
+
+// SMTP sends emails through an SMTP server.
+type SMTP struct {
+	cfg SMTPConfig
+}
+
+// NewSMTP constructs a mailer for the SMTP server. The connection is upgraded
+// with STARTTLS when the server supports it, credentials are only sent over
+// an encrypted connection.
+func NewSMTP(cfg SMTPConfig) *SMTP {
+	return &SMTP{
+		cfg: cfg,
+	}
+}
+
+// Send sends the message. The exchange is aborted when the context is done.
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+
+	if s.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(s.cfg.Host, fmt.Sprint(s.cfg.Port)))
+	if err != nil {
+		return fmt.Errorf("dialing smtp server: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return fmt.Errorf("setting smtp deadline: %w", err)
+		}
+	}
+
+	// Closing the connection unblocks the exchange when the context is
+	// canceled before its deadline.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err := s.send(conn, msg); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("sending mail: %w", ctx.Err())
+		}
+		return fmt.Errorf("sending mail: %w", err)
+	}
+
+	return nil
+}
+
+// send runs the SMTP exchange for the message over the connection.
+func (s *SMTP) send(conn net.Conn, msg Message) error {
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.cfg.Host}); err != nil {
+			return err
+		}
+	}
+
+	if s.cfg.User != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.cfg.User, s.cfg.Password, s.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(s.cfg.From); err != nil {
+		return err
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(format(s.cfg.From, msg, time.Now())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// =============================================================================
+
+// File writes every email to its own file in a directory.
+type File struct {
+	dir  string
+	from string
+	seq  uint64
+}
+
+// NewFile constructs a mailer writing the emails in the directory.
+func NewFile(dir string, from string) (*File, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating mail directory: %w", err)
+	}
+
+	f := File{
+		dir:  dir,
+		from: from,
+	}
+
+	return &f, nil
+}
+
+// Send writes the message to a new .eml file.
+func (f *File) Send(ctx context.Context, msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("%s-%06d.eml", now.UTC().Format("20060102T150405"), atomic.AddUint64(&f.seq, 1))
+
+	if err := os.WriteFile(filepath.Join(f.dir, name), format(f.from, msg, now), 0o600); err != nil {
+		return fmt.Errorf("writing mail: %w", err)
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// Log writes every email, body included, to the log. The body carries
+// secrets like reset tokens, use it for local development only.
+type Log struct {
+	log *zap.SugaredLogger
+}
+
+// NewLog constructs a mailer writing the emails to the log.
+func NewLog(log *zap.SugaredLogger) *Log {
+	return &Log{
+		log: log,
+	}
+}
+
+// Send logs the message.
+func (l *Log) Send(ctx context.Context, msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+
+	l.log.Infow("mail", "traceid", webapp.GetTraceID(ctx), "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
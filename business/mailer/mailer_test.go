@@ -0,0 +1,153 @@
+package mailer_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimashiro/service/business/mailer"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestFile(t *testing.T) {
+	t.Log("Given the need to write emails to files.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen sending a message.", testID)
+		{
+			dir := t.TempDir()
+
+			m, err := mailer.NewFile(dir, "no-reply@example.com")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to construct the mailer : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to construct the mailer.", success, testID)
+
+			msg := mailer.Message{
+				To:      "user@example.com",
+				Subject: "Verify your email",
+				Body:    "Hello\nUse this token.",
+			}
+			if err := m.Send(context.Background(), msg); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to send the message : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to send the message.", success, testID)
+
+			files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+			if err != nil || len(files) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould write a single file : %v, %v.", failed, testID, files, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould write a single file.", success, testID)
+
+			data, err := os.ReadFile(files[0])
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to read the file : %s.", failed, testID, err)
+			}
+			for _, want := range []string{"From: no-reply@example.com\r\n", "To: user@example.com\r\n", "Subject: Verify your email\r\n", "\r\n\r\nHello\r\nUse this token."} {
+				if !strings.Contains(string(data), want) {
+					t.Fatalf("\t%s\tTest %d:\tShould find %q in the message :\n%s", failed, testID, want, data)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould write the headers and the body.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen sending a message with an injected header.", testID)
+		{
+			m, err := mailer.NewFile(t.TempDir(), "no-reply@example.com")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to construct the mailer : %s.", failed, testID, err)
+			}
+
+			msg := mailer.Message{
+				To:      "user@example.com\r\nBcc: victim@example.com",
+				Subject: "Verify your email",
+			}
+			if err := m.Send(context.Background(), msg); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to send the message.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to send the message.", success, testID)
+		}
+	}
+}
+
+func TestSMTPTimeout(t *testing.T) {
+	// The server accepts connections but never greets the client.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Should be able to listen : %s.", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	msg := mailer.Message{
+		To:      "user@example.com",
+		Subject: "Verify your email",
+		Body:    "Hello",
+	}
+
+	t.Log("Given the need to bound the time spent on an unresponsive SMTP server.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the mailer has a timeout.", testID)
+		{
+			m := mailer.NewSMTP(mailer.SMTPConfig{
+				Host:    addr.IP.String(),
+				Port:    addr.Port,
+				From:    "no-reply@example.com",
+				Timeout: 100 * time.Millisecond,
+			})
+
+			start := time.Now()
+			if err := m.Send(context.Background(), msg); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to send the message.", failed, testID)
+			}
+			if d := time.Since(start); d > 5*time.Second {
+				t.Fatalf("\t%s\tTest %d:\tShould give up after the timeout : took %v.", failed, testID, d)
+			}
+			t.Logf("\t%s\tTest %d:\tShould give up after the timeout.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen the context is canceled.", testID)
+		{
+			m := mailer.NewSMTP(mailer.SMTPConfig{
+				Host: addr.IP.String(),
+				Port: addr.Port,
+				From: "no-reply@example.com",
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(100*time.Millisecond, cancel)
+
+			start := time.Now()
+			if err := m.Send(ctx, msg); !errors.Is(err, context.Canceled) {
+				t.Fatalf("\t%s\tTest %d:\tShould report the cancellation : %v.", failed, testID, err)
+			}
+			if d := time.Since(start); d > 5*time.Second {
+				t.Fatalf("\t%s\tTest %d:\tShould give up when canceled : took %v.", failed, testID, d)
+			}
+			t.Logf("\t%s\tTest %d:\tShould give up when canceled.", success, testID)
+		}
+	}
+}
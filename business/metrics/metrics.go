@@ -4,24 +4,77 @@ package metrics
 import (
 	"context"
 	"expvar"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 )
 
 var m *metrics
 
+// metrics keeps the expvar counters published on /debug/vars next to the
+// labelled series published in the Prometheus format on /metrics.
 type metrics struct {
-	goroutines *expvar.Int
-	requests   *expvar.Int
-	errors     *expvar.Int
-	panics     *expvar.Int
+	requests *expvar.Int
+	errors   *expvar.Int
+	panics   *expvar.Int
+	keystore *expvar.Map
+
+	httpRequests   *prometheus.CounterVec
+	httpDuration   *prometheus.HistogramVec
+	keystoreEvents *prometheus.CounterVec
+	dbQueries      *prometheus.CounterVec
+	dbDuration     *prometheus.HistogramVec
+	dbRows         *prometheus.CounterVec
 }
 
 func init() {
+	expvar.Publish("goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+
 	m = &metrics{
-		goroutines: expvar.NewInt("goroutines"),
-		requests:   expvar.NewInt("requests"),
-		errors:     expvar.NewInt("errors"),
-		panics:     expvar.NewInt("panics"),
+		requests: expvar.NewInt("requests"),
+		errors:   expvar.NewInt("errors"),
+		panics:   expvar.NewInt("panics"),
+		keystore: expvar.NewMap("keystore"),
+
+		httpRequests:   newCounterVec("http_requests_total", "Total number of handled requests.", "route", "method", "status"),
+		httpDuration:   newHistogramVec("http_request_duration_seconds", "Latency of handled requests.", "route", "method"),
+		keystoreEvents: newCounterVec("keystore_events_total", "Total number of changes applied to the keystore.", "kind"),
+		dbQueries:      newCounterVec("db_queries_total", "Total number of executed queries.", "query", "status"),
+		dbDuration:     newHistogramVec("db_query_duration_seconds", "Latency of executed queries.", "query"),
+		dbRows:         newCounterVec("db_query_rows_total", "Total number of rows returned or affected by queries.", "query"),
 	}
+
+	registry.MustRegister(
+		m.httpRequests,
+		m.httpDuration,
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "http_errors_total",
+			Help: "Total number of requests that failed.",
+		}, func() float64 { return float64(m.errors.Value()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "http_panics_total",
+			Help: "Total number of requests that panicked.",
+		}, func() float64 { return float64(m.panics.Value()) }),
+		m.keystoreEvents,
+		m.dbQueries,
+		m.dbDuration,
+		m.dbRows,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+func newCounterVec(name string, help string, labels ...string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)
+}
+
+func newHistogramVec(name string, help string, labels ...string) *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: DefaultBuckets}, labels)
 }
 
 type ctxKey int
@@ -32,17 +85,18 @@ func Set(ctx context.Context) context.Context {
 	return context.WithValue(ctx, key, m)
 }
 
-func AddGoroutines(ctx context.Context) {
+func AddRequests(ctx context.Context) {
 	if v, ok := ctx.Value(key).(*metrics); ok {
-		if v.requests.Value()%100 == 0 {
-			v.goroutines.Add(1)
-		}
+		v.requests.Add(1)
 	}
 }
 
-func AddRequests(ctx context.Context) {
+// ObserveRequest records a handled request against its route pattern, so
+// the number of series doesn't grow with the ids found in the paths.
+func ObserveRequest(ctx context.Context, route string, method string, status int, since time.Duration) {
 	if v, ok := ctx.Value(key).(*metrics); ok {
-		v.requests.Add(1)
+		v.httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+		v.httpDuration.WithLabelValues(route, method).Observe(since.Seconds())
 	}
 }
 
@@ -57,3 +111,24 @@ func AddPanics(ctx context.Context) {
 		v.panics.Add(1)
 	}
 }
+
+// AddKeyStoreEvent counts a change applied to the keystore by its kind. It is
+// not tied to a request so it doesn't need the context.
+func AddKeyStoreEvent(kind string) {
+	m.keystore.Add(kind, 1)
+	m.keystoreEvents.WithLabelValues(kind).Inc()
+}
+
+// ObserveQuery records an executed query against its name, the store method
+// that ran it. Queries run outside of requests too so it doesn't need the
+// context.
+func ObserveQuery(name string, failed bool, rows int64, since time.Duration) {
+	status := "ok"
+	if failed {
+		status = "error"
+	}
+
+	m.dbQueries.WithLabelValues(name, status).Inc()
+	m.dbDuration.WithLabelValues(name).Observe(since.Seconds())
+	m.dbRows.WithLabelValues(name).Add(float64(rows))
+}
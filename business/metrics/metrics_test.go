@@ -0,0 +1,87 @@
+package metrics_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimashiro/service/business/metrics"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestHandler(t *testing.T) {
+	t.Log("Given the need to scrape metrics in the Prometheus format.")
+	{
+		t.Logf("\tTest:\tWhen requests have been observed.")
+		{
+			ctx := metrics.Set(context.Background())
+			metrics.ObserveRequest(ctx, "/v1/products/:id", http.MethodGet, http.StatusOK, 30*time.Millisecond)
+			metrics.ObserveRequest(ctx, "/v1/products/:id", http.MethodGet, http.StatusNotFound, 2*time.Second)
+
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			w := httptest.NewRecorder()
+			metrics.Handler(nil).ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest:\tShould receive a status code of 200 : %d", failed, w.Code)
+			}
+			t.Logf("\t%s\tTest:\tShould receive a status code of 200.", success)
+
+			exp := []string{
+				"# TYPE http_requests_total counter",
+				`http_requests_total{method="GET",route="/v1/products/:id",status="200"} 1`,
+				`http_requests_total{method="GET",route="/v1/products/:id",status="404"} 1`,
+				"# TYPE http_request_duration_seconds histogram",
+				`http_request_duration_seconds_bucket{method="GET",route="/v1/products/:id",le="0.05"} 1`,
+				`http_request_duration_seconds_bucket{method="GET",route="/v1/products/:id",le="+Inf"} 2`,
+				`http_request_duration_seconds_count{method="GET",route="/v1/products/:id"} 2`,
+				"# TYPE go_goroutines gauge",
+			}
+			body := w.Body.String()
+			for _, line := range exp {
+				if !strings.Contains(body, line+"\n") {
+					t.Fatalf("\t%s\tTest:\tShould expose %q : got\n%s", failed, line, body)
+				}
+			}
+			t.Logf("\t%s\tTest:\tShould expose the request counters and latency histograms.", success)
+		}
+	}
+}
+
+func TestQueryMetrics(t *testing.T) {
+	t.Log("Given the need to scrape the metrics of the database queries.")
+	{
+		t.Logf("\tTest:\tWhen queries have been observed.")
+		{
+			metrics.ObserveQuery("user.Store.QueryByID", false, 1, 3*time.Millisecond)
+			metrics.ObserveQuery("user.Store.QueryByID", true, 0, 40*time.Millisecond)
+
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			w := httptest.NewRecorder()
+			metrics.Handler(nil).ServeHTTP(w, r)
+
+			exp := []string{
+				`db_queries_total{query="user.Store.QueryByID",status="error"} 1`,
+				`db_queries_total{query="user.Store.QueryByID",status="ok"} 1`,
+				`db_query_duration_seconds_bucket{query="user.Store.QueryByID",le="0.005"} 1`,
+				`db_query_duration_seconds_count{query="user.Store.QueryByID"} 2`,
+				`db_query_rows_total{query="user.Store.QueryByID"} 1`,
+			}
+			body := w.Body.String()
+			for _, line := range exp {
+				if !strings.Contains(body, line+"\n") {
+					t.Fatalf("\t%s\tTest:\tShould expose %q : got\n%s", failed, line, body)
+				}
+			}
+			t.Logf("\t%s\tTest:\tShould expose the query counters and latency histograms.", success)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultBuckets are the latency histogram buckets in seconds.
+var DefaultBuckets = prometheus.DefBuckets
+
+// registry holds the collectors of the service. It's not the default
+// registry of the client library, so a dependency can't add series to it.
+var registry = prometheus.NewRegistry()
+
+// Handler serves every metric in the Prometheus text exposition format. The
+// database is optional, when provided its connection pool stats are added.
+func Handler(db *sql.DB) http.Handler {
+	gatherers := prometheus.Gatherers{registry}
+
+	if db != nil {
+		dbRegistry := prometheus.NewRegistry()
+		dbRegistry.MustRegister(collectors.NewDBStatsCollector(db, "retail"))
+		gatherers = append(gatherers, dbRegistry)
+	}
+
+	return promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{})
+}
@@ -8,32 +8,60 @@ import (
 	"strings"
 
 	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/database"
 	"github.com/dimashiro/service/business/validate"
 	"github.com/dimashiro/service/foundation/webapp"
 )
 
-// Authenticate validates a JWT from the `Authorization` header.
+// ErrInvalidCredentials is reported to clients whose token or API key can't be
+// trusted.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Authenticate validates a JWT or an API key from the `Authorization` header.
+// Both produce the claims the handlers work with.
 func Authenticate(a *auth.Auth) webapp.Middleware {
 
 	m := func(handler webapp.Handler) webapp.Handler {
 
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 
-			// Expecting: bearer <token>
+			// Expecting: bearer <token> or apikey <key>
 			authStr := r.Header.Get("authorization")
 
 			// Parse the authorization header.
 			parts := strings.Split(authStr, " ")
-			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-				err := errors.New("expected authorization header format: bearer <token>")
+			if len(parts) != 2 {
+				err := errors.New("expected authorization header format: bearer <token> or apikey <key>")
 				return validate.NewRequestError(err, http.StatusUnauthorized)
 			}
 
-			// Validate the token is signed by us.
-			claims, err := a.ValidateToken(parts[1])
-			if err != nil {
+			var claims auth.Claims
+			var err error
+
+			switch strings.ToLower(parts[0]) {
+			case "bearer":
+				// Validate the token is signed by us.
+				claims, err = a.ValidateToken(ctx, parts[1])
+
+			case "apikey":
+				// Validate the key is known and resolve its owner.
+				claims, err = a.ValidateAPIKey(ctx, parts[1])
+
+			default:
+				err := errors.New("expected authorization header format: bearer <token> or apikey <key>")
 				return validate.NewRequestError(err, http.StatusUnauthorized)
 			}
+			switch {
+			case errors.Is(err, auth.ErrInvalidToken),
+				errors.Is(err, auth.ErrInvalidAPIKey),
+				errors.Is(err, database.ErrAuthenticationFailure):
+
+				// The reason is not sent to clients, it would help guessing.
+				return validate.NewRequestError(ErrInvalidCredentials, http.StatusUnauthorized)
+
+			case err != nil:
+				return fmt.Errorf("authenticate: %w", err)
+			}
 
 			ctx = auth.SetClaims(ctx, claims)
 
@@ -46,8 +74,9 @@ func Authenticate(a *auth.Auth) webapp.Middleware {
 	return m
 }
 
-// Authorize validates that an authenticated user has at least one role
-func Authorize(roles ...string) webapp.Middleware {
+// RequirePermission validates that an authenticated user was granted every
+// one of the permissions.
+func RequirePermission(perms ...string) webapp.Middleware {
 
 	m := func(handler webapp.Handler) webapp.Handler {
 
@@ -61,9 +90,9 @@ func Authorize(roles ...string) webapp.Middleware {
 				)
 			}
 
-			if !claims.Authorized(roles...) {
+			if !claims.HasPermission(perms...) {
 				return validate.NewRequestError(
-					fmt.Errorf("you are not authorized for that action, claims[%v] roles[%v]", claims.Roles, roles),
+					fmt.Errorf("you are not authorized for that action, permissions%v required", perms),
 					http.StatusForbidden,
 				)
 			}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns the address of the client that made the request.
+type ClientIP func(r *http.Request) string
+
+// RemoteAddr returns the address of the peer of the connection. Behind an
+// ingress, a load balancer or a NAT, every client shares the address of the
+// proxy, use ForwardedFor there.
+func RemoteAddr(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// ForwardedFor returns a ClientIP reading the address of the client from the
+// header the trusted proxies append the address of their peer to, usually
+// X-Forwarded-For. The client is the rightmost address that is not a trusted
+// proxy: the addresses on its left are sent by the client and can be forged.
+// Requests that don't come from a trusted proxy use their remote address.
+func ForwardedFor(header string, trusted []*net.IPNet) ClientIP {
+	isTrusted := func(addr string) bool {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return false
+		}
+		for _, n := range trusted {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	f := func(r *http.Request) string {
+		ip := RemoteAddr(r)
+		if !isTrusted(ip) {
+			return ip
+		}
+
+		hops := strings.Split(strings.Join(r.Header.Values(header), ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop == "" {
+				continue
+			}
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			ip = hop
+			if !isTrusted(hop) {
+				break
+			}
+		}
+
+		return ip
+	}
+
+	return f
+}
+
+// ParseNetworks parses a list of CIDR blocks, a single address is a block of
+// its own.
+func ParseNetworks(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", s)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			s = fmt.Sprintf("%s/%d", s, bits)
+		}
+
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", s, err)
+		}
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
@@ -2,13 +2,44 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
+	"github.com/dimashiro/service/business/database"
 	"github.com/dimashiro/service/business/validate"
 	"github.com/dimashiro/service/foundation/webapp"
 	"go.uber.org/zap"
 )
 
+// problemContentType is the media type of problem details (RFC 7807).
+const problemContentType = "application/problem+json"
+
+// sentinelErrors maps the errors of the database layer to the status and
+// code reported to clients, so handlers can return them as is. The errors of
+// the stores carry their own, see validate.StatusError.
+var sentinelErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{database.ErrInvalidID, http.StatusBadRequest, "invalid_id"},
+	{database.ErrDBNotFound, http.StatusNotFound, "not_found"},
+	{database.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{database.ErrAuthenticationFailure, http.StatusUnauthorized, "authentication_failed"},
+}
+
+// statusCodes are the codes used for request errors that don't wrap one of
+// the sentinel errors.
+var statusCodes = map[int]string{
+	http.StatusBadRequest:          "bad_request",
+	http.StatusUnauthorized:        "unauthenticated",
+	http.StatusForbidden:           "forbidden",
+	http.StatusNotFound:            "not_found",
+	http.StatusConflict:            "conflict",
+	http.StatusTooManyRequests:     "too_many_requests",
+	http.StatusInternalServerError: "internal_error",
+}
+
 func Errors(log *zap.SugaredLogger) webapp.Middleware {
 
 	m := func(handler webapp.Handler) webapp.Handler {
@@ -23,32 +54,13 @@ func Errors(log *zap.SugaredLogger) webapp.Middleware {
 				// Log the error.
 				log.Errorw("ERROR", "traceid", v.TraceID, "ERROR", err)
 
-				// build error response
-				var er validate.ErrorResponse
-				var status int
-				switch {
-				case validate.IsFieldErrors(err):
-					er = validate.ErrorResponse{
-						Error:  "data validation error",
-						Fields: err.Error(),
-					}
-					status = http.StatusBadRequest
-				case validate.IsRequestError(err):
-					reqErr := validate.GetRequestError(err)
-					er = validate.ErrorResponse{
-						Error: reqErr.Error(),
-					}
-					status = reqErr.Status
-
-				default:
-					er = validate.ErrorResponse{
-						Error: http.StatusText(http.StatusInternalServerError),
-					}
-					status = http.StatusInternalServerError
-				}
+				// Build the problem details response.
+				er := problem(err)
+				er.TraceID = v.TraceID
 
 				// Respond back to the client.
-				if err := webapp.Respond(ctx, w, er, status); err != nil {
+				w.Header().Set("Content-Type", problemContentType)
+				if err := webapp.Respond(ctx, w, er, er.Status); err != nil {
 					return err
 				}
 
@@ -64,3 +76,59 @@ func Errors(log *zap.SugaredLogger) webapp.Middleware {
 	}
 	return m
 }
+
+// problem builds the response for the error. The detail of unexpected
+// errors is not sent to clients.
+func problem(err error) validate.ErrorResponse {
+	er := validate.ErrorResponse{
+		Type: "about:blank",
+	}
+
+	switch {
+	case validate.IsFieldErrors(err):
+		er.Status = http.StatusBadRequest
+		er.Code = "validation_failed"
+		er.Detail = "data validation error"
+		er.Fields = validate.GetFieldErrors(err)
+
+	case validate.IsRequestError(err):
+		reqErr := validate.GetRequestError(err)
+		er.Status = reqErr.Status
+		er.Code = statusCodes[reqErr.Status]
+		er.Detail = reqErr.Error()
+		if se := validate.GetStatusError(reqErr.Err); se != nil {
+			er.Code = se.Code
+		}
+		for _, se := range sentinelErrors {
+			if errors.Is(reqErr.Err, se.err) {
+				er.Code = se.code
+				break
+			}
+		}
+
+	case validate.GetStatusError(err) != nil:
+		se := validate.GetStatusError(err)
+		er.Status = se.Status
+		er.Code = se.Code
+		er.Detail = se.Error()
+
+	default:
+		er.Status = http.StatusInternalServerError
+		er.Code = statusCodes[http.StatusInternalServerError]
+		for _, se := range sentinelErrors {
+			if errors.Is(err, se.err) {
+				er.Status = se.status
+				er.Code = se.code
+				er.Detail = se.err.Error()
+				break
+			}
+		}
+	}
+
+	if er.Code == "" {
+		er.Code = "error"
+	}
+	er.Title = http.StatusText(er.Status)
+
+	return er
+}
@@ -0,0 +1,183 @@
+package middleware_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimashiro/service/business/auth"
+	"github.com/dimashiro/service/business/data/store/sale"
+	"github.com/dimashiro/service/business/data/store/token"
+	"github.com/dimashiro/service/business/database"
+	"github.com/dimashiro/service/business/middleware"
+	"github.com/dimashiro/service/business/validate"
+	"github.com/dimashiro/service/foundation/keystore"
+	"github.com/dimashiro/service/foundation/webapp"
+	"go.uber.org/zap"
+)
+
+func TestErrors(t *testing.T) {
+	log := zap.NewNop().Sugar()
+
+	tt := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{"fields", validate.FieldErrors{{Field: "name", Error: "name is a required field"}}, http.StatusBadRequest, "validation_failed", "data validation error"},
+		{"request", validate.NewRequestError(errors.New("invalid page format [x]"), http.StatusBadRequest), http.StatusBadRequest, "bad_request", "invalid page format [x]"},
+		{"request sentinel", validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest), http.StatusBadRequest, "invalid_id", "invalid id"},
+		{"request status", validate.NewRequestError(token.ErrInvalidToken, http.StatusBadRequest), http.StatusBadRequest, "invalid_token", "invalid or expired token"},
+		{"database sentinel", fmt.Errorf("ID[1]: %w", database.ErrDBNotFound), http.StatusNotFound, "not_found", "not found"},
+		{"status", fmt.Errorf("create sale: %w", sale.ErrInsufficientStock), http.StatusConflict, "insufficient_stock", "insufficient stock"},
+		{"unexpected", errors.New("pq: connection refused"), http.StatusInternalServerError, "internal_error", ""},
+	}
+
+	app := webapp.NewApp(make(chan os.Signal, 1), middleware.Errors(log))
+	for _, tc := range tt {
+		err := tc.err
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			return err
+		}
+		app.Handle(http.MethodGet, "v1", "/"+strings.ReplaceAll(tc.name, " ", "-"), h)
+	}
+
+	t.Log("Given the need to report errors as problem details.")
+	{
+		for testID, tc := range tt {
+			t.Logf("\tTest %d:\tWhen a handler fails with a %s error.", testID, tc.name)
+			{
+				r := httptest.NewRequest(http.MethodGet, "/v1/"+strings.ReplaceAll(tc.name, " ", "-"), nil)
+				w := httptest.NewRecorder()
+				app.ServeHTTP(w, r)
+
+				if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+					t.Fatalf("\t%s\tTest %d:\tShould respond with problem+json : got %q.", failed, testID, ct)
+				}
+				t.Logf("\t%s\tTest %d:\tShould respond with problem+json.", success, testID)
+
+				var got validate.ErrorResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to decode the response : %v.", failed, testID, err)
+				}
+
+				if w.Code != tc.status || got.Status != tc.status || got.Code != tc.code {
+					t.Fatalf("\t%s\tTest %d:\tShould map the error to %d %s : got %d %s.", failed, testID, tc.status, tc.code, got.Status, got.Code)
+				}
+				t.Logf("\t%s\tTest %d:\tShould map the error to %d %s.", success, testID, tc.status, tc.code)
+
+				if got.Detail != tc.detail || got.Title != http.StatusText(tc.status) || got.TraceID == "" {
+					t.Fatalf("\t%s\tTest %d:\tShould describe the error : got %+v.", failed, testID, got)
+				}
+				t.Logf("\t%s\tTest %d:\tShould describe the error.", success, testID)
+			}
+		}
+	}
+}
+
+// revocations fails every revocation lookup with its error.
+type revocations struct {
+	err error
+}
+
+func (rv revocations) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	return false, rv.err
+}
+
+func TestAuthenticate(t *testing.T) {
+	log := zap.NewNop().Sugar()
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Should be able to create a private key: %v", err)
+	}
+	keys := keystore.NewMap(map[string]crypto.Signer{"kid": privateKey})
+
+	healthy, err := auth.New("http://localhost:3000", "kid", keys, revocations{}, nil)
+	if err != nil {
+		t.Fatalf("Should be able to construct auth: %v", err)
+	}
+	broken, err := auth.New("http://localhost:3000", "kid", keys, revocations{errors.New("pq: connection refused")}, nil)
+	if err != nil {
+		t.Fatalf("Should be able to construct auth: %v", err)
+	}
+
+	tkn, err := healthy.GenerateToken(auth.NewClaims("45b5fbd3-755f-4379-8f07-a58d4a30fa2f", []string{auth.RoleUser}, time.Now(), time.Hour))
+	if err != nil {
+		t.Fatalf("Should be able to generate a token: %v", err)
+	}
+
+	ok := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return webapp.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+
+	app := webapp.NewApp(make(chan os.Signal, 1), middleware.Errors(log))
+	app.Handle(http.MethodGet, "v1", "/healthy", ok, middleware.Authenticate(healthy))
+	app.Handle(http.MethodGet, "v1", "/broken", ok, middleware.Authenticate(broken))
+
+	tt := []struct {
+		name          string
+		path          string
+		authorization string
+		status        int
+		code          string
+	}{
+		{"a valid token", "/v1/healthy", "Bearer " + tkn, http.StatusNoContent, ""},
+		{"a tampered token", "/v1/healthy", "Bearer " + tkn + "x", http.StatusUnauthorized, "unauthenticated"},
+		{"an api key without a lookup", "/v1/healthy", "ApiKey abc", http.StatusUnauthorized, "unauthenticated"},
+		{"a failing revocation lookup", "/v1/broken", "Bearer " + tkn, http.StatusInternalServerError, "internal_error"},
+	}
+
+	t.Log("Given the need to authenticate requests.")
+	{
+		for testID, tc := range tt {
+			t.Logf("\tTest %d:\tWhen a request comes with %s.", testID, tc.name)
+			{
+				r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+				r.Header.Set("Authorization", tc.authorization)
+				w := httptest.NewRecorder()
+				app.ServeHTTP(w, r)
+
+				if w.Code != tc.status {
+					t.Fatalf("\t%s\tTest %d:\tShould receive a status code of %d : got %d.", failed, testID, tc.status, w.Code)
+				}
+				t.Logf("\t%s\tTest %d:\tShould receive a status code of %d.", success, testID, tc.status)
+
+				if tc.code == "" {
+					continue
+				}
+
+				var got validate.ErrorResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to decode the response : %v.", failed, testID, err)
+				}
+
+				if got.Code != tc.code {
+					t.Fatalf("\t%s\tTest %d:\tShould report the %s code : got %s.", failed, testID, tc.code, got.Code)
+				}
+				t.Logf("\t%s\tTest %d:\tShould report the %s code.", success, testID, tc.code)
+
+				if tc.status == http.StatusUnauthorized && got.Detail != middleware.ErrInvalidCredentials.Error() {
+					t.Fatalf("\t%s\tTest %d:\tShould not tell why the credentials were rejected : got %q.", failed, testID, got.Detail)
+				}
+				if tc.status == http.StatusInternalServerError && got.Detail != "" {
+					t.Fatalf("\t%s\tTest %d:\tShould not leak the internal error : got %q.", failed, testID, got.Detail)
+				}
+				t.Logf("\t%s\tTest %d:\tShould not leak why the request failed.", success, testID)
+			}
+		}
+	}
+}
@@ -3,26 +3,34 @@ package middleware
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/dimashiro/service/business/metrics"
 	"github.com/dimashiro/service/foundation/webapp"
 )
 
+// Metrics records every request against its route, method and status. It
+// must wrap the Errors middleware so the final status code is known.
 func Metrics() webapp.Middleware {
 
 	m := func(handler webapp.Handler) webapp.Handler {
 
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 
+			v, err := webapp.GetValues(ctx)
+			if err != nil {
+				return err // web.NewShutdownError("web value missing from context")
+			}
+
 			ctx = metrics.Set(ctx)
 
-			err := handler(ctx, w, r)
+			err = handler(ctx, w, r)
 
-			// Increment the request and goroutines counter.
+			// Increment the request counters.
 			metrics.AddRequests(ctx)
-			metrics.AddGoroutines(ctx)
+			metrics.ObserveRequest(ctx, v.Route, r.Method, v.StatusCode, time.Since(v.Now))
 
-			if err != nil {
+			if err != nil || v.StatusCode >= http.StatusBadRequest {
 				metrics.AddErrors(ctx)
 			}
 
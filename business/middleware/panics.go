@@ -11,7 +11,7 @@ import (
 )
 
 // Panics recovers from panics and converts the panic to an error so it is
-// reported in Metrics and handled in Errors.
+// handled in Errors and reported in Metrics.
 func Panics() webapp.Middleware {
 
 	m := func(handler webapp.Handler) webapp.Handler {
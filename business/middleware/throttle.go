@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dimashiro/service/business/validate"
+	"github.com/dimashiro/service/foundation/webapp"
+	"go.uber.org/zap"
+)
+
+// window counts the requests of a client in the current time window.
+type window struct {
+	start time.Time
+	count int
+}
+
+// Throttle limits the number of requests a single IP address, as returned by
+// the client IP, can make within the window. Requests over the limit are
+// rejected with 429 until the window ends. A zero limit disables throttling.
+//
+// The windows are kept in memory: the limit applies to each replica of the
+// service, a client reaching N replicas can make N times as many requests.
+func Throttle(log *zap.SugaredLogger, limit int, per time.Duration, clientIP ClientIP) webapp.Middleware {
+	var mu sync.Mutex
+	windows := make(map[string]*window)
+
+	// allow counts the request and reports how long to wait when it is over
+	// the limit.
+	allow := func(ip string, now time.Time) (bool, time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// Forget the clients whose window ended so the map doesn't grow with
+		// every address ever seen.
+		if len(windows) > 10000 {
+			for ip, w := range windows {
+				if now.Sub(w.start) >= per {
+					delete(windows, ip)
+				}
+			}
+		}
+
+		w, found := windows[ip]
+		if !found || now.Sub(w.start) >= per {
+			w = &window{start: now}
+			windows[ip] = w
+		}
+
+		w.count++
+		if w.count > limit {
+			return false, per - now.Sub(w.start)
+		}
+
+		return true, 0
+	}
+
+	m := func(handler webapp.Handler) webapp.Handler {
+
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			if limit <= 0 {
+				return handler(ctx, w, r)
+			}
+
+			v, err := webapp.GetValues(ctx)
+			if err != nil {
+				return webapp.NewShutdownError("web value missing from context")
+			}
+
+			ip := clientIP(r)
+
+			ok, wait := allow(ip, v.Now)
+			if !ok {
+				log.Warnw("security", "event", "throttled", "traceid", v.TraceID, "remoteaddr", ip,
+					"method", r.Method, "path", r.URL.Path)
+
+				seconds := int(wait.Round(time.Second) / time.Second)
+				if seconds < 1 {
+					seconds = 1
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+
+				return validate.NewRequestError(errors.New("too many requests"), http.StatusTooManyRequests)
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
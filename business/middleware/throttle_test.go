@@ -0,0 +1,106 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dimashiro/service/business/middleware"
+	"github.com/dimashiro/service/foundation/webapp"
+	"go.uber.org/zap"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestThrottle(t *testing.T) {
+	log := zap.NewNop().Sugar()
+
+	app := webapp.NewApp(make(chan os.Signal, 1), middleware.Errors(log))
+	ok := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return webapp.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+	app.Handle(http.MethodGet, "v1", "/token", ok, middleware.Throttle(log, 2, time.Minute, middleware.RemoteAddr))
+
+	call := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/v1/token", nil)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Log("Given the need to throttle requests by IP address.")
+	{
+		t.Logf("\tTest:\tWhen a client makes too many requests.")
+		{
+			for i := 0; i < 2; i++ {
+				if w := call("10.0.0.1:1234"); w.Code != http.StatusNoContent {
+					t.Fatalf("\t%s\tTest:\tShould allow requests under the limit : %d", failed, w.Code)
+				}
+			}
+			t.Logf("\t%s\tTest:\tShould allow requests under the limit.", success)
+
+			w := call("10.0.0.1:5678")
+			if w.Code != http.StatusTooManyRequests {
+				t.Fatalf("\t%s\tTest:\tShould reject requests over the limit : %d", failed, w.Code)
+			}
+			if w.Header().Get("Retry-After") == "" {
+				t.Fatalf("\t%s\tTest:\tShould tell the client when to retry.", failed)
+			}
+			t.Logf("\t%s\tTest:\tShould reject requests over the limit.", success)
+
+			if w := call("10.0.0.2:1234"); w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest:\tShould NOT throttle other clients : %d", failed, w.Code)
+			}
+			t.Logf("\t%s\tTest:\tShould NOT throttle other clients.", success)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := middleware.ParseNetworks([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("Should be able to parse the trusted proxies: %v", err)
+	}
+	clientIP := middleware.ForwardedFor("X-Forwarded-For", proxies)
+
+	tt := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		exp        string
+	}{
+		{"a direct client", "203.0.113.7:1234", "", "203.0.113.7"},
+		{"a direct client forging the header", "203.0.113.7:1234", "198.51.100.1", "203.0.113.7"},
+		{"a client behind a proxy", "10.1.2.3:1234", "198.51.100.1", "198.51.100.1"},
+		{"a client behind two proxies", "10.1.2.3:1234", "198.51.100.1, 192.168.1.1", "198.51.100.1"},
+		{"a client forging the header behind a proxy", "10.1.2.3:1234", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		{"a proxy without the header", "10.1.2.3:1234", "", "10.1.2.3"},
+	}
+
+	t.Log("Given the need to find the address of the client behind proxies.")
+	{
+		for testID, tc := range tt {
+			t.Logf("\tTest %d:\tWhen the request comes from %s.", testID, tc.name)
+			{
+				r := httptest.NewRequest(http.MethodGet, "/v1/token", nil)
+				r.RemoteAddr = tc.remoteAddr
+				if tc.forwarded != "" {
+					r.Header.Set("X-Forwarded-For", tc.forwarded)
+				}
+
+				if got := clientIP(r); got != tc.exp {
+					t.Fatalf("\t%s\tTest %d:\tShould find the client address : got %s, exp %s", failed, testID, got, tc.exp)
+				}
+				t.Logf("\t%s\tTest %d:\tShould find the client address.", success, testID)
+			}
+		}
+	}
+}
@@ -5,9 +5,17 @@ import (
 	"errors"
 )
 
+// ErrorResponse is the problem details (RFC 7807) document sent to clients
+// when a request fails. Code is stable and meant for programs, Title and
+// Detail are meant for people.
 type ErrorResponse struct {
-	Fields string `json:"fields,omitempty"`
-	Error  string `json:"error"`
+	Type    string      `json:"type"`
+	Title   string      `json:"title"`
+	Status  int         `json:"status"`
+	Code    string      `json:"code"`
+	Detail  string      `json:"detail,omitempty"`
+	TraceID string      `json:"trace_id,omitempty"`
+	Fields  FieldErrors `json:"fields,omitempty"`
 }
 
 // FieldError is used to indicate an error with a specific request field.
@@ -31,20 +39,64 @@ func IsFieldErrors(err error) bool {
 	return errors.As(err, &fe)
 }
 
+// GetFieldErrors returns the field errors wrapped by the error.
+func GetFieldErrors(err error) FieldErrors {
+	var fe FieldErrors
+	if !errors.As(err, &fe) {
+		return nil
+	}
+	return fe
+}
+
+// StatusError is an error of the business layer carrying the status and the
+// stable code it's reported to clients with, so the errors middleware doesn't
+// need to know the packages declaring them.
+type StatusError struct {
+	Status int
+	Code   string
+	msg    string
+}
+
+// NewStatusError constructs an error reported with the status and the code.
+func NewStatusError(status int, code string, msg string) *StatusError {
+	return &StatusError{
+		Status: status,
+		Code:   code,
+		msg:    msg,
+	}
+}
+
+func (se *StatusError) Error() string {
+	return se.msg
+}
+
+// GetStatusError returns the status error wrapped by the error.
+func GetStatusError(err error) *StatusError {
+	var se *StatusError
+	if !errors.As(err, &se) {
+		return nil
+	}
+	return se
+}
+
 type RequestError struct {
 	Err    error
 	Status int
-	Fields error
 }
 
 func NewRequestError(err error, status int) error {
-	return &RequestError{err, status, nil}
+	return &RequestError{err, status}
 }
 
 func (re RequestError) Error() string {
 	return re.Err.Error()
 }
 
+// Unwrap gives access to the error the request failed with.
+func (re RequestError) Unwrap() error {
+	return re.Err
+}
+
 func IsRequestError(err error) bool {
 	var re *RequestError
 	return errors.As(err, &re)
@@ -0,0 +1,234 @@
+// Package health provides a registry of named health checks run concurrently
+// to report the readiness of a service and of the components it depends on.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Set of statuses of a component and of a report.
+const (
+	StatusOK       = "ok"
+	StatusDown     = "down"
+	StatusNotReady = "not ready"
+)
+
+// ErrTimeout is returned for a check that didn't complete within its timeout.
+var ErrTimeout = errors.New("check timed out")
+
+// Checker checks the health of a component, returning nil when it's healthy.
+type Checker func(ctx context.Context) error
+
+// Check is a named checker along with the options it runs with. A zero
+// Timeout or TTL uses the one of the registry, a negative TTL disables the
+// caching of its results.
+type Check struct {
+	Name    string
+	Checker Checker
+	Timeout time.Duration
+	TTL     time.Duration
+}
+
+// Config is the default options the checks run with.
+type Config struct {
+	Timeout time.Duration
+	TTL     time.Duration
+}
+
+// Component is the status of a component as reported by its check. The last
+// error is kept after the component recovers, to help diagnose flapping.
+type Component struct {
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	Latency     string     `json:"latency"`
+	CheckedAt   time.Time  `json:"checked_at"`
+	Cached      bool       `json:"cached"`
+	Error       string     `json:"error,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// Report is the status of every registered component, ordered by name. The
+// service is ready when every component is.
+type Report struct {
+	Status     string      `json:"status"`
+	Components []Component `json:"components"`
+}
+
+// Ready reports whether every component is healthy.
+func (r Report) Ready() bool {
+	return r.Status == StatusOK
+}
+
+// Registry holds the checks of a service.
+type Registry struct {
+	cfg Config
+
+	mu     sync.RWMutex
+	checks map[string]*entry
+}
+
+// New constructs an empty registry running the checks with the options of
+// the configuration by default.
+func New(cfg Config) *Registry {
+	return &Registry{
+		cfg:    cfg,
+		checks: make(map[string]*entry),
+	}
+}
+
+// Register adds the check to the registry. Its name must be unique.
+func (r *Registry) Register(c Check) error {
+	if c.Name == "" || c.Checker == nil {
+		return errors.New("check needs a name and a checker")
+	}
+
+	if c.Timeout == 0 {
+		c.Timeout = r.cfg.Timeout
+	}
+	if c.TTL == 0 {
+		c.TTL = r.cfg.TTL
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.checks[c.Name]; exists {
+		return fmt.Errorf("check %q is already registered", c.Name)
+	}
+	r.checks[c.Name] = &entry{check: c}
+
+	return nil
+}
+
+// Run runs the registered checks concurrently and reports the status of
+// every component. A check whose result is younger than its TTL isn't run
+// again, the cached result is reported instead.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	entries := make([]*entry, 0, len(r.checks))
+	for _, e := range r.checks {
+		entries = append(entries, e)
+	}
+	r.mu.RUnlock()
+
+	components := make([]Component, len(entries))
+
+	var wg sync.WaitGroup
+	wg.Add(len(entries))
+	for i, e := range entries {
+		go func(i int, e *entry) {
+			defer wg.Done()
+			components[i] = e.run(ctx)
+		}(i, e)
+	}
+	wg.Wait()
+
+	sort.Slice(components, func(i, j int) bool {
+		return components[i].Name < components[j].Name
+	})
+
+	report := Report{
+		Status:     StatusOK,
+		Components: components,
+	}
+	for _, c := range components {
+		if c.Status != StatusOK {
+			report.Status = StatusNotReady
+			break
+		}
+	}
+
+	return report
+}
+
+// =============================================================================
+
+// entry is a registered check along with its last results.
+type entry struct {
+	check Check
+
+	// mu is held while the check runs, so concurrent reports wait for its
+	// result rather than running it again.
+	mu          sync.Mutex
+	last        Component
+	expires     time.Time
+	lastErr     string
+	lastErrTime time.Time
+}
+
+// run returns the cached result of the check or runs it.
+func (e *entry) run(ctx context.Context) Component {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if time.Now().Before(e.expires) {
+		c := e.last
+		c.Cached = true
+		return c
+	}
+
+	start := time.Now()
+	err := e.call(ctx)
+	now := time.Now()
+
+	c := Component{
+		Name:      e.check.Name,
+		Status:    StatusOK,
+		Latency:   now.Sub(start).String(),
+		CheckedAt: now.UTC(),
+	}
+
+	if err != nil {
+		c.Status = StatusDown
+		c.Error = err.Error()
+		e.lastErr, e.lastErrTime = c.Error, c.CheckedAt
+	}
+
+	if e.lastErr != "" {
+		at := e.lastErrTime
+		c.LastError = e.lastErr
+		c.LastErrorAt = &at
+	}
+
+	// A report cancelled by its caller says nothing about the component.
+	if ctx.Err() == nil && e.check.TTL > 0 {
+		e.last = c
+		e.expires = now.Add(e.check.TTL)
+	}
+
+	return c
+}
+
+// call runs the checker within its timeout. A checker ignoring its context
+// is left to complete on its own.
+func (e *entry) call(ctx context.Context) error {
+	if e.check.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.check.Timeout)
+		defer cancel()
+	}
+
+	ch := make(chan error, 1)
+	go func() {
+		ch <- e.check.Checker(ctx)
+	}()
+
+	select {
+	case err := <-ch:
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("%w after %v: %v", ErrTimeout, e.check.Timeout, err)
+		}
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("%w after %v", ErrTimeout, e.check.Timeout)
+		}
+		return ctx.Err()
+	}
+}
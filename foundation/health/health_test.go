@@ -0,0 +1,138 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dimashiro/service/foundation/health"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestRun(t *testing.T) {
+
+	t.Logf("\tTest:\tWhen running the checks of the registry.")
+	{
+		hr := health.New(health.Config{Timeout: 50 * time.Millisecond, TTL: -1})
+
+		slow := func(ctx context.Context) error {
+			time.Sleep(30 * time.Millisecond)
+			return nil
+		}
+		hung := func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		}
+
+		checks := []health.Check{
+			{Name: "slow-b", Checker: slow},
+			{Name: "slow-a", Checker: slow},
+			{Name: "hung", Checker: hung},
+		}
+		for _, c := range checks {
+			if err := hr.Register(c); err != nil {
+				t.Fatalf("\t%s\tTest:\tShould be able to register %s: %v", failed, c.Name, err)
+			}
+		}
+		t.Logf("\t%s\tTest:\tShould be able to register the checks.", success)
+
+		if err := hr.Register(health.Check{Name: "hung", Checker: hung}); err == nil {
+			t.Fatalf("\t%s\tTest:\tShould not be able to register a name twice.", failed)
+		}
+		t.Logf("\t%s\tTest:\tShould not be able to register a name twice.", success)
+
+		start := time.Now()
+		report := hr.Run(context.Background())
+		if d := time.Since(start); d > 500*time.Millisecond {
+			t.Fatalf("\t%s\tTest:\tShould run the checks concurrently within their timeout: took %v", failed, d)
+		}
+		t.Logf("\t%s\tTest:\tShould run the checks concurrently within their timeout.", success)
+
+		if report.Ready() {
+			t.Fatalf("\t%s\tTest:\tShould not be ready with a hung check.", failed)
+		}
+		t.Logf("\t%s\tTest:\tShould not be ready with a hung check.", success)
+
+		exp := []string{"hung", "slow-a", "slow-b"}
+		if len(report.Components) != len(exp) {
+			t.Fatalf("\t%s\tTest:\tShould report every component: got %d", failed, len(report.Components))
+		}
+		for i, c := range report.Components {
+			if c.Name != exp[i] {
+				t.Fatalf("\t%s\tTest:\tShould order the components by name: got %s, exp %s", failed, c.Name, exp[i])
+			}
+		}
+		t.Logf("\t%s\tTest:\tShould report every component ordered by name.", success)
+
+		hc := report.Components[0]
+		if hc.Status != health.StatusDown || hc.Error == "" {
+			t.Fatalf("\t%s\tTest:\tShould report the hung check as down: got %+v", failed, hc)
+		}
+		t.Logf("\t%s\tTest:\tShould report the hung check as down.", success)
+
+		for _, c := range report.Components[1:] {
+			if c.Status != health.StatusOK || c.Error != "" {
+				t.Fatalf("\t%s\tTest:\tShould report the slow checks as ok: got %+v", failed, c)
+			}
+		}
+		t.Logf("\t%s\tTest:\tShould report the slow checks as ok.", success)
+	}
+}
+
+func TestCache(t *testing.T) {
+
+	t.Logf("\tTest:\tWhen a check is run again within its TTL.")
+	{
+		hr := health.New(health.Config{Timeout: time.Second, TTL: time.Hour})
+
+		var calls int32
+		var down int32 = 1
+		checker := func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			if atomic.LoadInt32(&down) == 1 {
+				return errors.New("connection refused")
+			}
+			return nil
+		}
+
+		if err := hr.Register(health.Check{Name: "cached", Checker: checker}); err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to register the check: %v", failed, err)
+		}
+		if err := hr.Register(health.Check{Name: "uncached", Checker: checker, TTL: -1}); err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to register the check: %v", failed, err)
+		}
+
+		hr.Run(context.Background())
+		atomic.StoreInt32(&down, 0)
+		report := hr.Run(context.Background())
+
+		if n := atomic.LoadInt32(&calls); n != 3 {
+			t.Fatalf("\t%s\tTest:\tShould run the cached check once: got %d calls, exp 3", failed, n)
+		}
+		t.Logf("\t%s\tTest:\tShould run the cached check once.", success)
+
+		cached := report.Components[0]
+		if !cached.Cached || cached.Status != health.StatusDown {
+			t.Fatalf("\t%s\tTest:\tShould report the cached result: got %+v", failed, cached)
+		}
+		t.Logf("\t%s\tTest:\tShould report the cached result.", success)
+
+		uncached := report.Components[1]
+		if uncached.Cached || uncached.Status != health.StatusOK {
+			t.Fatalf("\t%s\tTest:\tShould report the recovered component: got %+v", failed, uncached)
+		}
+		t.Logf("\t%s\tTest:\tShould report the recovered component.", success)
+
+		if uncached.LastError != "connection refused" || uncached.LastErrorAt == nil {
+			t.Fatalf("\t%s\tTest:\tShould keep the last error of the recovered component: got %+v", failed, uncached)
+		}
+		t.Logf("\t%s\tTest:\tShould keep the last error of the recovered component.", success)
+	}
+}
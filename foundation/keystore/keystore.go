@@ -2,32 +2,54 @@
 package keystore
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"math/big"
 	"path"
+	"sort"
 	"strings"
 	"sync"
+)
 
-	"github.com/golang-jwt/jwt/v4"
+// Set of signing algorithms supported by the keys in the store.
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgES384 = "ES384"
+	AlgES512 = "ES512"
+	AlgEdDSA = "EdDSA"
 )
 
+// Algorithms lists every signing algorithm a key in the store can use.
+var Algorithms = []string{AlgRS256, AlgES256, AlgES384, AlgES512, AlgEdDSA}
+
+// KeyStore holds private keys by kid. RSA, ECDSA (P-256, P-384, P-521) and
+// Ed25519 keys are supported, the signing algorithm is picked from the type
+// of the key.
 type KeyStore struct {
 	mu    sync.RWMutex
-	store map[string]*rsa.PrivateKey
+	store map[string]crypto.Signer
 }
 
 // New constructs an empty KeyStore ready for use.
 func New() *KeyStore {
 	return &KeyStore{
-		store: make(map[string]*rsa.PrivateKey),
+		store: make(map[string]crypto.Signer),
 	}
 }
 
 // NewMap constructs a KeyStore with an initial set of keys.
-func NewMap(store map[string]*rsa.PrivateKey) *KeyStore {
+func NewMap(store map[string]crypto.Signer) *KeyStore {
 	return &KeyStore{
 		store: store,
 	}
@@ -38,16 +60,34 @@ func NewMap(store map[string]*rsa.PrivateKey) *KeyStore {
 // Example: keystore.NewFS(os.DirFS("/keys/"))
 // Example: /keys/54bb2165-71e1-41a6-af3e-7da4a0e1e2c1.pem
 func NewFS(fsys fs.FS) (*KeyStore, error) {
+	store, err := loadFS(fsys)
+	if err != nil {
+		return nil, err
+	}
+
 	ks := KeyStore{
-		store: make(map[string]*rsa.PrivateKey),
+		store: store,
 	}
 
+	return &ks, nil
+}
+
+// loadFS reads every PEM file rooted inside of the directory. Hidden
+// directories starting with ".." are skipped, Kubernetes uses them to swap
+// the content of secret volumes atomically and the files are reachable
+// through symlinks in the root.
+func loadFS(fsys fs.FS) (map[string]crypto.Signer, error) {
+	store := make(map[string]crypto.Signer)
+
 	fn := func(fileName string, dirEntry fs.DirEntry, err error) error {
 		if err != nil {
 			return fmt.Errorf("walkdir failure: %w", err)
 		}
 
 		if dirEntry.IsDir() {
+			if fileName != "." && strings.HasPrefix(dirEntry.Name(), "..") {
+				return fs.SkipDir
+			}
 			return nil
 		}
 
@@ -69,12 +109,12 @@ func NewFS(fsys fs.FS) (*KeyStore, error) {
 			return fmt.Errorf("reading auth private key: %w", err)
 		}
 
-		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
+		privateKey, err := ParsePrivateKeyFromPEM(privatePEM)
 		if err != nil {
-			return fmt.Errorf("parsing auth private key: %w", err)
+			return fmt.Errorf("parsing auth private key %s: %w", fileName, err)
 		}
 
-		ks.store[strings.TrimSuffix(dirEntry.Name(), ".pem")] = privateKey
+		store[strings.TrimSuffix(dirEntry.Name(), ".pem")] = privateKey
 		return nil
 	}
 
@@ -82,11 +122,69 @@ func NewFS(fsys fs.FS) (*KeyStore, error) {
 		return nil, fmt.Errorf("walking directory: %w", err)
 	}
 
-	return &ks, nil
+	return store, nil
+}
+
+// ParsePrivateKeyFromPEM parses a PKCS #1 RSA, SEC 1 EC or PKCS #8 private
+// key and checks it can be used for signing tokens.
+func ParsePrivateKeyFromPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("key must be PEM encoded")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	if _, err := Algorithm(signer.Public()); err != nil {
+		return nil, err
+	}
+
+	return signer, nil
+}
+
+// Algorithm returns the JWT signing algorithm to use with the public key.
+func Algorithm(publicKey crypto.PublicKey) (string, error) {
+	switch pk := publicKey.(type) {
+	case *rsa.PublicKey:
+		return AlgRS256, nil
+	case *ecdsa.PublicKey:
+		switch pk.Curve {
+		case elliptic.P256():
+			return AlgES256, nil
+		case elliptic.P384():
+			return AlgES384, nil
+		case elliptic.P521():
+			return AlgES512, nil
+		}
+		return "", fmt.Errorf("unsupported curve %s", pk.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return AlgEdDSA, nil
+	}
+
+	return "", fmt.Errorf("unsupported key type %T", publicKey)
 }
 
 // Add adds a private key and combination kid to the store.
-func (ks *KeyStore) Add(privateKey *rsa.PrivateKey, kid string) {
+func (ks *KeyStore) Add(privateKey crypto.Signer, kid string) {
 	ks.mu.Lock()
 	defer ks.mu.Unlock()
 
@@ -101,7 +199,7 @@ func (ks *KeyStore) Remove(kid string) {
 	delete(ks.store, kid)
 }
 
-func (ks *KeyStore) PrivateKey(kid string) (*rsa.PrivateKey, error) {
+func (ks *KeyStore) PrivateKey(kid string) (crypto.Signer, error) {
 	ks.mu.RLock()
 	defer ks.mu.RUnlock()
 
@@ -112,7 +210,7 @@ func (ks *KeyStore) PrivateKey(kid string) (*rsa.PrivateKey, error) {
 	return privateKey, nil
 }
 
-func (ks *KeyStore) PublicKey(kid string) (*rsa.PublicKey, error) {
+func (ks *KeyStore) PublicKey(kid string) (crypto.PublicKey, error) {
 	ks.mu.RLock()
 	defer ks.mu.RUnlock()
 
@@ -120,5 +218,80 @@ func (ks *KeyStore) PublicKey(kid string) (*rsa.PublicKey, error) {
 	if !found {
 		return nil, errors.New("kid lookup failed - " + kid)
 	}
-	return &privateKey.PublicKey, nil
+	return privateKey.Public(), nil
+}
+
+// JWK is the JSON Web Key representation of a public key (RFC 7517, RFC 8037).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKSet is a set of JSON Web Keys as served from a jwks.json endpoint.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public part of every key in the store, ordered by kid.
+func (ks *KeyStore) JWKS() JWKSet {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	set := JWKSet{
+		Keys: make([]JWK, 0, len(ks.store)),
+	}
+	for kid, privateKey := range ks.store {
+		jwk, err := newJWK(kid, privateKey.Public())
+		if err != nil {
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].KeyID < set.Keys[j].KeyID
+	})
+
+	return set
+}
+
+// newJWK encodes the public key as a JWK.
+func newJWK(kid string, publicKey crypto.PublicKey) (JWK, error) {
+	alg, err := Algorithm(publicKey)
+	if err != nil {
+		return JWK{}, err
+	}
+
+	jwk := JWK{
+		KeyID:     kid,
+		Algorithm: alg,
+		Use:       "sig",
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch pk := publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = b64(pk.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pk.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pk.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = pk.Curve.Params().Name
+		jwk.X = b64(pk.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(pk.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = b64(pk)
+	}
+
+	return jwk, nil
 }
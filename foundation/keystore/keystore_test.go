@@ -0,0 +1,121 @@
+package keystore_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"testing"
+
+	"github.com/dimashiro/service/foundation/keystore"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestJWKS(t *testing.T) {
+
+	t.Logf("\tTest:\tWhen publishing the keys of the store.")
+	{
+		const keyID = "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to create a private key: %v", failed, err)
+		}
+		t.Logf("\t%s\tTest:\tShould be able to create a private key.", success)
+
+		ks := keystore.NewMap(map[string]crypto.Signer{keyID: privateKey})
+
+		set := ks.JWKS()
+		if len(set.Keys) != 1 {
+			t.Fatalf("\t%s\tTest:\tShould have one key in the set: got %d", failed, len(set.Keys))
+		}
+		t.Logf("\t%s\tTest:\tShould have one key in the set.", success)
+
+		jwk := set.Keys[0]
+		if jwk.KeyID != keyID || jwk.KeyType != "RSA" || jwk.Algorithm != "RS256" || jwk.Use != "sig" {
+			t.Fatalf("\t%s\tTest:\tShould describe the key: got %+v", failed, jwk)
+		}
+		t.Logf("\t%s\tTest:\tShould describe the key.", success)
+
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to decode the modulus: %v", failed, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to decode the exponent: %v", failed, err)
+		}
+
+		pub := rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if !pub.Equal(&privateKey.PublicKey) {
+			t.Fatalf("\t%s\tTest:\tShould get back the public key.", failed)
+		}
+		t.Logf("\t%s\tTest:\tShould get back the public key.", success)
+	}
+}
+
+func TestParsePrivateKeyFromPEM(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("\t%s\tTest:\tShould be able to create a P-256 key: %v", failed, err)
+	}
+	ecDER, err := x509.MarshalECPrivateKey(p256)
+	if err != nil {
+		t.Fatalf("\t%s\tTest:\tShould be able to marshal the P-256 key: %v", failed, err)
+	}
+
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("\t%s\tTest:\tShould be able to create an Ed25519 key: %v", failed, err)
+	}
+	edDER, err := x509.MarshalPKCS8PrivateKey(ed)
+	if err != nil {
+		t.Fatalf("\t%s\tTest:\tShould be able to marshal the Ed25519 key: %v", failed, err)
+	}
+
+	tt := []struct {
+		name  string
+		block pem.Block
+		kty   string
+		alg   string
+		crv   string
+	}{
+		{name: "SEC 1 EC", block: pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}, kty: "EC", alg: keystore.AlgES256, crv: "P-256"},
+		{name: "PKCS #8 Ed25519", block: pem.Block{Type: "PRIVATE KEY", Bytes: edDER}, kty: "OKP", alg: keystore.AlgEdDSA, crv: "Ed25519"},
+	}
+
+	for _, tst := range tt {
+		t.Logf("\tTest:\tWhen parsing a %s key.", tst.name)
+		{
+			key, err := keystore.ParsePrivateKeyFromPEM(pem.EncodeToMemory(&tst.block))
+			if err != nil {
+				t.Fatalf("\t%s\tTest:\tShould be able to parse the key: %v", failed, err)
+			}
+			t.Logf("\t%s\tTest:\tShould be able to parse the key.", success)
+
+			set := keystore.NewMap(map[string]crypto.Signer{"kid": key}).JWKS()
+			if len(set.Keys) != 1 {
+				t.Fatalf("\t%s\tTest:\tShould have one key in the set: got %d", failed, len(set.Keys))
+			}
+
+			jwk := set.Keys[0]
+			if jwk.KeyType != tst.kty || jwk.Algorithm != tst.alg || jwk.Curve != tst.crv || jwk.X == "" {
+				t.Fatalf("\t%s\tTest:\tShould describe the key: got %+v", failed, jwk)
+			}
+			t.Logf("\t%s\tTest:\tShould describe the key.", success)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package keystore
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Set of event kinds reported by a Watcher.
+const (
+	EventAdded    = "added"
+	EventUpdated  = "updated"
+	EventRetiring = "retiring"
+	EventRemoved  = "removed"
+	EventRestored = "restored"
+	EventActive   = "active"
+	EventError    = "error"
+)
+
+// ActiveKIDFile is the file of the directory naming the key used to sign
+// tokens. Every replica watching the directory switches to it, so they agree
+// on the active key and keep it across restarts.
+const ActiveKIDFile = "active.kid"
+
+// Event describes a change applied to the KeyStore by a Watcher.
+type Event struct {
+	Kind string
+	KID  string
+	Err  error
+}
+
+// Watcher is a KeyStore kept in sync with a directory of PEM files. The
+// directory is rescanned periodically: new files are added, changed files
+// replace their key and keys whose file disappeared are removed after a
+// grace period, so tokens signed with them can still be verified for a while.
+type Watcher struct {
+	*KeyStore
+
+	fsys     fs.FS
+	interval time.Duration
+	grace    time.Duration
+	onChange func(Event) error
+
+	mu       sync.Mutex
+	known    map[string]crypto.Signer
+	retiring map[string]time.Time
+	active   string
+}
+
+// NewWatcher constructs a Watcher with the keys currently in the directory.
+// The onChange function is called for every change, it can be nil. When it
+// fails to apply an active event the active kid is kept, so the switch is
+// retried on the next rescan; the errors of the other events are ignored.
+// Example: keystore.NewWatcher(os.DirFS("/keys/"), time.Minute, time.Hour, nil)
+func NewWatcher(fsys fs.FS, interval time.Duration, grace time.Duration, onChange func(Event) error) (*Watcher, error) {
+	store, err := loadFS(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := readActiveKID(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]crypto.Signer, len(store))
+	ks := New()
+	for kid, privateKey := range store {
+		known[kid] = privateKey
+		ks.Add(privateKey, kid)
+	}
+
+	if onChange == nil {
+		onChange = func(Event) error { return nil }
+	}
+
+	w := Watcher{
+		KeyStore: ks,
+		fsys:     fsys,
+		interval: interval,
+		grace:    grace,
+		onChange: onChange,
+		known:    known,
+		retiring: make(map[string]time.Time),
+		active:   active,
+	}
+
+	return &w, nil
+}
+
+// Run rescans the directory every interval until the context is cancelled.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := w.Rescan(now); err != nil {
+				w.onChange(Event{Kind: EventError, Err: err})
+			}
+		}
+	}
+}
+
+// Rescan synchronizes the KeyStore with the directory. When the directory
+// can't be read the KeyStore is left untouched. A failed switch of the active
+// kid is reported after the keys are synchronized.
+func (w *Watcher) Rescan(now time.Time) error {
+	store, err := loadFS(w.fsys)
+	if err != nil {
+		return fmt.Errorf("rescanning keys: %w", err)
+	}
+
+	active, err := readActiveKID(w.fsys)
+	if err != nil {
+		return fmt.Errorf("rescanning keys: %w", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for kid, privateKey := range store {
+		old, found := w.known[kid]
+		switch {
+		case !found:
+			w.KeyStore.Add(privateKey, kid)
+			w.known[kid] = privateKey
+			w.onChange(Event{Kind: EventAdded, KID: kid})
+		case !equal(old, privateKey):
+			w.KeyStore.Add(privateKey, kid)
+			w.known[kid] = privateKey
+			w.onChange(Event{Kind: EventUpdated, KID: kid})
+		}
+
+		if _, found := w.retiring[kid]; found {
+			delete(w.retiring, kid)
+			w.onChange(Event{Kind: EventRestored, KID: kid})
+		}
+	}
+
+	for kid := range w.known {
+		if _, found := store[kid]; found {
+			continue
+		}
+
+		since, found := w.retiring[kid]
+		switch {
+		case !found:
+			w.retiring[kid] = now
+			w.onChange(Event{Kind: EventRetiring, KID: kid})
+		case now.Sub(since) >= w.grace:
+			w.KeyStore.Remove(kid)
+			delete(w.known, kid)
+			delete(w.retiring, kid)
+			w.onChange(Event{Kind: EventRemoved, KID: kid})
+		}
+	}
+
+	// The keys are synchronized first, the active key may have been added
+	// along with the file naming it. The active kid only changes once the
+	// switch is applied, a failed one is retried on the next rescan.
+	if active != w.active {
+		if active != "" {
+			if err := w.onChange(Event{Kind: EventActive, KID: active}); err != nil {
+				return fmt.Errorf("switching active kid %q: %w", active, err)
+			}
+		}
+		w.active = active
+	}
+
+	return nil
+}
+
+// ActiveKID returns the key id named by the active kid file of the directory,
+// empty when there is none.
+func (w *Watcher) ActiveKID() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.active
+}
+
+// readActiveKID returns the content of the active kid file, empty when it
+// doesn't exist.
+func readActiveKID(fsys fs.FS) (string, error) {
+	data, err := fs.ReadFile(fsys, ActiveKIDFile)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return "", nil
+	case err != nil:
+		return "", fmt.Errorf("reading active kid: %w", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// equal reports if both private keys are the same. Every key type supported
+// by the store implements Equal.
+func equal(a crypto.Signer, b crypto.Signer) bool {
+	eq, ok := a.(interface{ Equal(crypto.PrivateKey) bool })
+	if !ok {
+		return false
+	}
+	return eq.Equal(b)
+}
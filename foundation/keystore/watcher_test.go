@@ -0,0 +1,208 @@
+package keystore_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/dimashiro/service/foundation/keystore"
+)
+
+func TestWatcher(t *testing.T) {
+
+	t.Logf("\tTest:\tWhen the keys directory changes.")
+	{
+		fsys := fstest.MapFS{
+			"old.pem": &fstest.MapFile{Data: genPEM(t)},
+		}
+
+		var events []keystore.Event
+		onChange := func(e keystore.Event) error {
+			events = append(events, e)
+			return nil
+		}
+
+		w, err := keystore.NewWatcher(fsys, time.Minute, time.Hour, onChange)
+		if err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to create a watcher: %v", failed, err)
+		}
+		t.Logf("\t%s\tTest:\tShould be able to create a watcher.", success)
+
+		if _, err := w.PrivateKey("old"); err != nil {
+			t.Fatalf("\t%s\tTest:\tShould have the initial key: %v", failed, err)
+		}
+		t.Logf("\t%s\tTest:\tShould have the initial key.", success)
+
+		now := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+		// Rotate: the new key shows up and the old one goes away.
+		delete(fsys, "old.pem")
+		fsys["new.pem"] = &fstest.MapFile{Data: genPEM(t)}
+
+		if err := w.Rescan(now); err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to rescan: %v", failed, err)
+		}
+
+		if _, err := w.PrivateKey("new"); err != nil {
+			t.Fatalf("\t%s\tTest:\tShould have the added key: %v", failed, err)
+		}
+		t.Logf("\t%s\tTest:\tShould have the added key.", success)
+
+		if _, err := w.PublicKey("old"); err != nil {
+			t.Fatalf("\t%s\tTest:\tShould keep the removed key during the grace period: %v", failed, err)
+		}
+		t.Logf("\t%s\tTest:\tShould keep the removed key during the grace period.", success)
+
+		if err := w.Rescan(now.Add(time.Hour)); err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to rescan: %v", failed, err)
+		}
+
+		if _, err := w.PublicKey("old"); err == nil {
+			t.Fatalf("\t%s\tTest:\tShould drop the removed key after the grace period.", failed)
+		}
+		t.Logf("\t%s\tTest:\tShould drop the removed key after the grace period.", success)
+
+		// A broken file must not wipe out the keys we have.
+		fsys["broken.pem"] = &fstest.MapFile{Data: []byte("not a key")}
+
+		if err := w.Rescan(now.Add(2 * time.Hour)); err == nil {
+			t.Fatalf("\t%s\tTest:\tShould report a broken key file.", failed)
+		}
+		if _, err := w.PrivateKey("new"); err != nil {
+			t.Fatalf("\t%s\tTest:\tShould keep the keys on a failed rescan: %v", failed, err)
+		}
+		t.Logf("\t%s\tTest:\tShould keep the keys on a failed rescan.", success)
+
+		exp := []string{keystore.EventAdded, keystore.EventRetiring, keystore.EventRemoved}
+		if len(events) != len(exp) {
+			t.Fatalf("\t%s\tTest:\tShould report every change: got %+v", failed, events)
+		}
+		for i, e := range events {
+			if e.Kind != exp[i] {
+				t.Fatalf("\t%s\tTest:\tShould report every change: got %+v", failed, events)
+			}
+		}
+		t.Logf("\t%s\tTest:\tShould report every change.", success)
+	}
+}
+
+func TestWatcherActiveKID(t *testing.T) {
+
+	t.Logf("\tTest:\tWhen the active kid file of the directory changes.")
+	{
+		fsys := fstest.MapFS{
+			"old.pem":              &fstest.MapFile{Data: genPEM(t)},
+			keystore.ActiveKIDFile: &fstest.MapFile{Data: []byte("old\n")},
+		}
+
+		var events []keystore.Event
+		onChange := func(e keystore.Event) error {
+			events = append(events, e)
+			return nil
+		}
+
+		w, err := keystore.NewWatcher(fsys, time.Minute, time.Hour, onChange)
+		if err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to create a watcher: %v", failed, err)
+		}
+
+		if kid := w.ActiveKID(); kid != "old" {
+			t.Fatalf("\t%s\tTest:\tShould read the initial active kid: got %q", failed, kid)
+		}
+		t.Logf("\t%s\tTest:\tShould read the initial active kid.", success)
+
+		// Rotate: the new key and the file naming it show up together.
+		fsys["new.pem"] = &fstest.MapFile{Data: genPEM(t)}
+		fsys[keystore.ActiveKIDFile] = &fstest.MapFile{Data: []byte("new")}
+
+		now := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+		if err := w.Rescan(now); err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to rescan: %v", failed, err)
+		}
+
+		if kid := w.ActiveKID(); kid != "new" {
+			t.Fatalf("\t%s\tTest:\tShould switch the active kid: got %q", failed, kid)
+		}
+		t.Logf("\t%s\tTest:\tShould switch the active kid.", success)
+
+		exp := []keystore.Event{
+			{Kind: keystore.EventAdded, KID: "new"},
+			{Kind: keystore.EventActive, KID: "new"},
+		}
+		if len(events) != len(exp) || events[0] != exp[0] || events[1] != exp[1] {
+			t.Fatalf("\t%s\tTest:\tShould report the new key before activating it: got %+v", failed, events)
+		}
+		t.Logf("\t%s\tTest:\tShould report the new key before activating it.", success)
+	}
+}
+
+func TestWatcherActiveKIDRetry(t *testing.T) {
+
+	t.Logf("\tTest:\tWhen the active kid can't be switched.")
+	{
+		fsys := fstest.MapFS{
+			"old.pem":              &fstest.MapFile{Data: genPEM(t)},
+			keystore.ActiveKIDFile: &fstest.MapFile{Data: []byte("old")},
+		}
+
+		var switched []string
+		fail := true
+		onChange := func(e keystore.Event) error {
+			if e.Kind != keystore.EventActive {
+				return nil
+			}
+			if fail {
+				return errors.New("unknown kid")
+			}
+			switched = append(switched, e.KID)
+			return nil
+		}
+
+		w, err := keystore.NewWatcher(fsys, time.Minute, time.Hour, onChange)
+		if err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to create a watcher: %v", failed, err)
+		}
+
+		fsys[keystore.ActiveKIDFile] = &fstest.MapFile{Data: []byte("new")}
+
+		now := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+		if err := w.Rescan(now); err == nil {
+			t.Fatalf("\t%s\tTest:\tShould report the failed switch.", failed)
+		}
+		t.Logf("\t%s\tTest:\tShould report the failed switch.", success)
+
+		if kid := w.ActiveKID(); kid != "old" {
+			t.Fatalf("\t%s\tTest:\tShould keep the active kid: got %q", failed, kid)
+		}
+		t.Logf("\t%s\tTest:\tShould keep the active kid.", success)
+
+		fail = false
+		if err := w.Rescan(now.Add(time.Minute)); err != nil {
+			t.Fatalf("\t%s\tTest:\tShould be able to rescan: %v", failed, err)
+		}
+
+		if kid := w.ActiveKID(); kid != "new" || len(switched) != 1 || switched[0] != "new" {
+			t.Fatalf("\t%s\tTest:\tShould retry the switch on the next rescan: got %q %v", failed, kid, switched)
+		}
+		t.Logf("\t%s\tTest:\tShould retry the switch on the next rescan.", success)
+	}
+}
+
+func genPEM(t *testing.T) []byte {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("\t%s\tTest:\tShould be able to create a private key: %v", failed, err)
+	}
+
+	block := pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	}
+
+	return pem.EncodeToMemory(&block)
+}
@@ -14,6 +14,7 @@ const key ctxKey = 1
 // Values represent state for each request.
 type Values struct {
 	TraceID    string
+	Route      string
 	Now        time.Time
 	StatusCode int
 }
@@ -45,3 +46,14 @@ func SetStatusCode(ctx context.Context, statusCode int) error {
 	v.StatusCode = statusCode
 	return nil
 }
+
+// Detach returns a context carrying a copy of the request values but neither
+// the deadline nor the cancellation of the request, for work that outlives it.
+func Detach(ctx context.Context) context.Context {
+	v, ok := ctx.Value(key).(*Values)
+	if !ok {
+		return context.Background()
+	}
+	vc := *v
+	return context.WithValue(context.Background(), key, &vc)
+}
@@ -22,7 +22,10 @@ func Respond(ctx context.Context, w http.ResponseWriter, data interface{}, statu
 		return err
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	// Keep a more specific JSON content type set by the caller.
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
 
 	w.WriteHeader(statusCode)
 
@@ -50,6 +50,11 @@ func (a *App) Handle(method string, group string, path string, handler Handler,
 	// Add the application's general middleware to the handler chain.
 	handler = wrapMiddleware(a.mw, handler)
 
+	fullPath := path
+	if group != "" {
+		fullPath = "/" + group + path
+	}
+
 	h := func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
@@ -57,6 +62,7 @@ func (a *App) Handle(method string, group string, path string, handler Handler,
 		span := trace.SpanFromContext(ctx)
 		v := Values{
 			TraceID: span.SpanContext().TraceID().String(),
+			Route:   fullPath,
 			Now:     time.Now(),
 		}
 		ctx = context.WithValue(ctx, key, &v)
@@ -68,10 +74,6 @@ func (a *App) Handle(method string, group string, path string, handler Handler,
 
 	}
 
-	fullPath := path
-	if group != "" {
-		fullPath = "/" + group + path
-	}
 	a.mux.Handle(method, fullPath, h)
 }
 